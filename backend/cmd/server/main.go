@@ -2,7 +2,8 @@
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,34 +11,70 @@ import (
 	"time"
 
 	"github.com/AEX0TIC/ATIA/backend/internal/api"
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
 	"github.com/AEX0TIC/ATIA/backend/internal/config"
 	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/egress"
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+	"github.com/AEX0TIC/ATIA/backend/internal/ratelimit"
+	"github.com/AEX0TIC/ATIA/backend/internal/rpz"
 	"github.com/AEX0TIC/ATIA/backend/internal/services"
+	"github.com/AEX0TIC/ATIA/backend/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", err)
+	}
+
+	if err := logging.Setup(cfg.Logging.Level, cfg.Logging.Components); err != nil {
+		fatal("Failed to configure logging", err)
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    cfg.Tracing.OTLPEndpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to configure tracing", err)
 	}
 
 	// Initialize MongoDB
 	db, err := database.NewMongoDB(cfg.MongoURI, cfg.MongoDatabase)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		fatal("Failed to connect to MongoDB", err)
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			log.Printf("Error closing DB: %v", err)
+			slog.Error("Error closing DB", "error", err)
 		}
 	}()
 
 	// Create indexes
 	if err := db.CreateIndexes(); err != nil {
-		log.Printf("Warning: failed to create indexes: %v", err)
+		slog.Warn("Failed to create indexes", "error", err)
+	}
+	// Analyses stored before TAXII looked objects up by ID need their IDs.
+	go func() {
+		if n, err := db.BackfillSTIXIDs(context.Background()); err != nil {
+			slog.Warn("Failed to backfill STIX object IDs", "error", err)
+		} else if n > 0 {
+			slog.Info("Backfilled STIX object IDs", "threats", n)
+		}
+	}()
+
+	// URLs supplied through the API may only reach these private prefixes.
+	if err := egress.Allow(cfg.Server.EgressAllow); err != nil {
+		fatal("Invalid EGRESS_ALLOW", err)
 	}
 
 	// Initialize services
@@ -46,12 +83,98 @@ func main() {
 	abuseService := services.NewAbuseIPDBService(cfg.APIKeys.AbuseIPDB)
 
 	// Create aggregator with services and database
-	aggregator := services.NewAggregator(vtService, otxService, abuseService, db)
+	aggregator := services.NewAggregatorWithWebhook(vtService, otxService, abuseService, db, cfg.N8NWebhookURL)
+	aggregator.EnableCache(cfg.ProviderCacheTTL)
+	aggregator.EnableGraphEnrichment(cfg.GraphEnrichBudget)
+
+	// Side effects of analyses (webhooks, syslog, metrics, audit) hang off
+	// the event bus.
+	bus := events.NewBus()
+	aggregator.PublishTo(bus)
+	services.SubscribeMetrics(bus)
+	services.SubscribeAudit(bus)
+
+	if cfg.Syslog.Address != "" {
+		syslog, err := services.NewSyslogSink(services.SyslogOptions{
+			Network:  cfg.Syslog.Network,
+			Address:  cfg.Syslog.Address,
+			Format:   cfg.Syslog.Format,
+			Facility: cfg.Syslog.Facility,
+			AppName:  cfg.Syslog.AppName,
+			CAFile:   cfg.Syslog.CAFile,
+		})
+		if err != nil {
+			fatal("Invalid syslog configuration", err)
+		}
+		defer syslog.Close()
+		syslog.Subscribe(bus)
+		slog.Info("Syslog output enabled", "address", cfg.Syslog.Address, "network", cfg.Syslog.Network, "format", cfg.Syslog.Format)
+	}
+
+	webhooks := aggregator.Webhooks()
+	webhooks.Subscribe(bus)
+	webhooks.Start(services.DeliveryOptions{
+		Workers:       cfg.Webhook.Workers,
+		MaxAttempts:   cfg.Webhook.MaxAttempts,
+		RetryBase:     cfg.Webhook.RetryBase,
+		RetryMax:      cfg.Webhook.RetryMax,
+		SigningSecret: cfg.Webhook.SigningSecret,
+	})
+
+	queue := aggregator.Queue()
+	queue.Start(services.QueueOptions{Workers: cfg.Queue.Workers, Size: cfg.Queue.Size})
+
+	zones, err := rpz.NewZones(db, rpz.Options{
+		Zone:             cfg.RPZ.Zone,
+		Action:           cfg.RPZ.Action,
+		SuspiciousAction: cfg.RPZ.SuspiciousAction,
+		Sinkhole:         cfg.RPZ.Sinkhole,
+		TTL:              cfg.RPZ.TTL,
+		Wildcard:         cfg.RPZ.Wildcard,
+	})
+	if err != nil {
+		fatal("Invalid RPZ configuration", err)
+	}
+	var rpzServer *rpz.Server
+	if cfg.RPZ.Listen != "" {
+		rpzServer, err = rpz.NewServer(zones, rpz.ServerOptions{
+			Addr:          cfg.RPZ.Listen,
+			AllowTransfer: cfg.RPZ.AllowTransfer,
+			TSIGKey:       cfg.RPZ.TSIGKey,
+			TenantKeys:    cfg.RPZ.TenantTSIGKeys,
+			Notify:        cfg.RPZ.Notify,
+		})
+		if err != nil {
+			fatal("Invalid RPZ configuration", err)
+		}
+		if err := rpzServer.Start(); err != nil {
+			fatal("Failed to start RPZ listener", err)
+		}
+		rpzServer.Subscribe(bus)
+		slog.Info("RPZ zone transfers enabled", "address", cfg.RPZ.Listen, "zone", cfg.RPZ.Zone)
+	}
 
 	// Initialize Gin router and routes
-	router := gin.Default()
-	router.Use(corsMiddleware())
-	api.SetupRoutes(router, aggregator, db)
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
+	router.Use(gin.Recovery())
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+	router.Use(logging.Middleware())
+	router.Use(metrics.Middleware())
+	router.Use(corsMiddleware(cfg.Server.CORSAllowedOrigins))
+
+	authenticator := auth.NewAuthenticator(db, cfg.Auth.Enabled, cfg.Auth.BootstrapAdminKey)
+	if !cfg.Auth.Enabled {
+		slog.Warn("API authentication is disabled")
+	}
+
+	limiter, limits, err := rateLimits(cfg.RateLimit, db)
+	if err != nil {
+		fatal("Invalid rate limit configuration", err)
+	}
+	api.SetupRoutes(router, aggregator, db, authenticator, limiter, limits, cfg.Server.CORSAllowedOrigins, zones)
 
 	// HTTP server with timeouts
 	srv := &http.Server{
@@ -64,9 +187,9 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Server.Port)
+		slog.Info("Server starting", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			fatal("listen", err)
 		}
 	}()
 
@@ -74,22 +197,89 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
+	}
+	// Let running queued analyses finish so their events are published.
+	if err := queue.Shutdown(ctx); err != nil {
+		slog.Error("Queued analyses did not finish", "error", err)
+	}
+	// Hand queued events to their subscribers, then let in-flight webhook
+	// attempts finish; queued deliveries resume on restart.
+	if err := bus.Close(ctx); err != nil {
+		slog.Error("Events did not drain", "error", err)
+	}
+	if rpzServer != nil {
+		if err := rpzServer.Shutdown(ctx); err != nil {
+			slog.Error("RPZ listener did not stop", "error", err)
+		}
+	}
+	if err := webhooks.Shutdown(ctx); err != nil {
+		slog.Error("Webhook deliveries did not drain", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Server exiting")
+}
+
+func rateLimits(cfg config.RateLimitConfig, db *database.MongoDB) (*ratelimit.Limiter, ratelimit.Policies, error) {
+	var store ratelimit.Store
+	switch cfg.Backend {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "mongo":
+		store = ratelimit.NewMongoStore(db)
+	default:
+		return nil, ratelimit.Policies{}, fmt.Errorf("unknown RATE_LIMIT_BACKEND %q", cfg.Backend)
 	}
-	log.Println("Server exiting")
+
+	analyze, err := ratelimit.NewPolicy("analyze", cfg.AnalyzePerKey, cfg.AnalyzePerIP)
+	if err != nil {
+		return nil, ratelimit.Policies{}, err
+	}
+	read, err := ratelimit.NewPolicy("read", cfg.ReadPerKey, cfg.ReadPerIP)
+	if err != nil {
+		return nil, ratelimit.Policies{}, err
+	}
+
+	return ratelimit.NewLimiter(store), ratelimit.Policies{Analyze: analyze, Read: read}, nil
 }
 
-// corsMiddleware provides permissive CORS for development. Remove or restrict in production.
-func corsMiddleware() gin.HandlerFunc {
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// corsMiddleware allows browser requests from the configured origins only.
+// An origin of "*" allows any origin.
+func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		origin := c.GetHeader("Origin")
+		if origin != "" && (allowAll || allowed[origin]) {
+			if allowAll {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, traceparent, tracestate")
+			c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/bulk"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var bulkLog = logging.For("bulk")
+
+const (
+	// exportPageSize is how many analyses an export reads per query.
+	exportPageSize = 1000
+	// maxBulkImportSize caps an import upload.
+	maxBulkImportSize = 256 << 20
+)
+
+var bulkContentTypes = map[string]string{
+	bulk.FormatCSV:   "text/csv; charset=utf-8",
+	bulk.FormatJSONL: "application/x-ndjson",
+}
+
+// ExportThreats streams the analyses matching the export filter as CSV or
+// JSON Lines (format), oldest update first, with the fields listed in
+// fields. Without limit, everything matching is exported.
+func (h *Handler) ExportThreats(c *gin.Context) {
+	format, err := bulk.Format(c.DefaultQuery("format", bulk.FormatJSONL))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fields, err := bulk.ParseFields(queryList(c, "fields"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := threatFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	remaining := filter.Limit
+	if c.Query("limit") == "" {
+		remaining = -1
+	}
+
+	// Large exports outlast the server's write timeout.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	ctx := c.Request.Context()
+	tenantID := auth.TenantID(c)
+	c.Header("Content-Type", bulkContentTypes[format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="atia-%s.%s"`, time.Now().UTC().Format("20060102"), format))
+	c.Status(http.StatusOK)
+	w, err := bulk.NewWriter(c.Writer, format, fields)
+	if err != nil {
+		bulkLog.ErrorContext(ctx, "export failed", "error", err)
+		return
+	}
+
+	var after time.Time
+	var afterID primitive.ObjectID
+	exported := 0
+	for remaining != 0 {
+		filter.Limit = exportPageSize
+		if remaining > 0 && remaining < exportPageSize {
+			filter.Limit = remaining
+		}
+		threats, err := h.db.ThreatsAfter(ctx, tenantID, filter, after, afterID)
+		if err != nil {
+			// The status is already sent; a truncated body is all the
+			// client gets.
+			bulkLog.ErrorContext(ctx, "export failed", "error", err, "exported", exported)
+			break
+		}
+		for i := range threats {
+			if err := w.Write(&threats[i]); err != nil {
+				bulkLog.ErrorContext(ctx, "export failed", "error", err, "exported", exported)
+				return
+			}
+		}
+		exported += len(threats)
+		if remaining > 0 {
+			remaining -= int64(len(threats))
+		}
+		if len(threats) < int(filter.Limit) {
+			break
+		}
+		last := threats[len(threats)-1]
+		after, afterID = last.LastUpdated, last.ID
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		bulkLog.DebugContext(ctx, "export aborted", "error", err)
+	}
+}
+
+type importRowError struct {
+	Line      int    `json:"line"`
+	Indicator string `json:"indicator,omitempty"`
+	Error     string `json:"error"`
+}
+
+type bulkImportResult struct {
+	Rows        int              `json:"rows"`
+	Created     int              `json:"created"`
+	Duplicates  int              `json:"duplicates"`
+	Queued      int              `json:"queued"`
+	RateLimited int              `json:"rate_limited"`
+	Failed      int              `json:"failed"`
+	Errors      []importRowError `json:"errors"`
+}
+
+// ImportThreats reads CSV or JSON Lines, as written by ExportThreats or
+// mapped onto its fields with map=column:field, and stores the indicators
+// the tenant doesn't have yet. Rows with a reputation or sources are
+// restored as they are; bare indicators are stored unscored with their
+// tags kept across analysis. With analyze=true, new indicators are queued
+// for analysis. Bad rows are reported and skipped.
+func (h *Handler) ImportThreats(c *gin.Context) {
+	format, err := bulk.Format(c.DefaultQuery("format", c.ContentType()))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mapping, err := bulk.ParseMapping(queryList(c, "map"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	analyze := c.Query("analyze") == "true"
+
+	// Rows are stored as they are read, so large imports outlast the
+	// server's read and write timeouts.
+	clearDeadlines(c)
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkImportSize)
+	r, err := bulk.NewReader(body, format, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantID := auth.TenantID(c)
+	result := bulkImportResult{Errors: []importRowError{}}
+	fail := func(line int, indicator string, err error) {
+		result.Failed++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, importRowError{Line: line, Indicator: indicator, Error: err.Error()})
+		}
+	}
+	budget := h.analysisBudget(c)
+	seen := map[string]bool{}
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *bulk.RowError
+		if errors.As(err, &rowErr) {
+			result.Rows++
+			fail(rowErr.Line, rowErr.Indicator, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, gin.H{"error": err.Error(), "result": result})
+			return
+		}
+		result.Rows++
+
+		threat := &rec.Threat
+		if seen[threat.Indicator] {
+			result.Duplicates++
+			continue
+		}
+		seen[threat.Indicator] = true
+
+		threat.TenantID = tenantID
+		now := time.Now()
+		if threat.FirstSeen.IsZero() {
+			threat.FirstSeen = now
+		}
+		if threat.LastUpdated.IsZero() {
+			threat.LastUpdated = now
+		}
+		if threat.Metadata == nil {
+			threat.Metadata = map[string]interface{}{}
+		}
+		if !rec.Analyzed {
+			threat.Reputation = "unknown"
+			threat.RiskScore = 0
+			if len(threat.Tags) > 0 {
+				threat.Metadata[models.MetadataImportedTags] = threat.Tags
+			}
+		}
+
+		created, err := h.db.InsertThreat(ctx, threat)
+		if err != nil {
+			fail(rec.Line, threat.Indicator, err)
+			continue
+		}
+		if !created {
+			result.Duplicates++
+			continue
+		}
+		result.Created++
+		if analyze && budget.queue(tenantID, threat.Indicator, threat.Type) {
+			result.Queued++
+		}
+	}
+
+	result.RateLimited = budget.Limited
+	c.JSON(http.StatusOK, result)
+}
+
+// clearDeadlines lifts the server's read and write timeouts for a request
+// that reads a large body or works for long before it responds.
+func clearDeadlines(c *gin.Context) {
+	rc := http.NewResponseController(c.Writer)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+}
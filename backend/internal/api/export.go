@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultExportLimit = 1000
+	maxExportLimit     = 10000
+)
+
+// queryList reads a parameter given either repeated or comma-separated.
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, v := range c.QueryArray(name) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+// threatFilter reads the filter shared by exports and feeds: type,
+// reputation, tag, min_score, since (RFC 3339) and limit.
+func threatFilter(c *gin.Context) (models.ThreatFilter, error) {
+	filter := models.ThreatFilter{
+		Types:       queryList(c, "type"),
+		Reputations: queryList(c, "reputation"),
+		Tags:        queryList(c, "tag"),
+		Limit:       defaultExportLimit,
+	}
+	if s := c.Query("min_score"); s != "" {
+		score, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_score %q", s)
+		}
+		filter.MinScore = score
+	}
+	if s := c.Query("since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since %q, want RFC 3339", s)
+		}
+		filter.UpdatedSince = since
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || limit <= 0 || limit > maxExportLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxExportLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// GetThreatSTIX returns one analysis as a STIX 2.1 bundle.
+func (h *Handler) GetThreatSTIX(c *gin.Context) {
+	threat, err := h.db.GetThreat(c.Request.Context(), auth.TenantID(c), c.Param("indicator"))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Threat not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	builder := stix.NewBuilder()
+	if err := builder.Add(threat); err != nil {
+		if errors.Is(err, stix.ErrUnsupported) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", stix.MediaType)
+	c.JSON(http.StatusOK, builder.Bundle())
+}
+
+// ExportSTIX returns the analyses matching the query as one STIX 2.1
+// bundle. Indicators that have no STIX pattern are skipped and counted in
+// X-ATIA-Skipped.
+func (h *Handler) ExportSTIX(c *gin.Context) {
+	filter, err := threatFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	threats, err := h.db.FindThreats(c.Request.Context(), auth.TenantID(c), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	builder := stix.NewBuilder()
+	skipped := 0
+	for i := range threats {
+		if err := builder.Add(&threats[i]); err != nil {
+			if errors.Is(err, stix.ErrUnsupported) {
+				skipped++
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Header("Content-Type", stix.MediaType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="atia-%s.stix.json"`, time.Now().UTC().Format("20060102")))
+	c.Header("X-ATIA-Skipped", strconv.Itoa(skipped))
+	c.JSON(http.StatusOK, builder.Bundle())
+}
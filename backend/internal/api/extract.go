@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxExtractSize caps a submitted document.
+const maxExtractSize = 16 << 20
+
+type extractRequest struct {
+	Text   string `json:"text"`
+	Format string `json:"format"`
+}
+
+type extractResponse struct {
+	Indicators  []ioc.Match    `json:"indicators"`
+	Counts      map[string]int `json:"counts"`
+	Filtered    int            `json:"filtered"`
+	Queued      *int           `json:"queued,omitempty"`
+	RateLimited *int           `json:"rate_limited,omitempty"`
+}
+
+// ExtractIndicators pulls IPs, domains, URLs, hashes and email addresses out
+// of a report. The body is the document itself, or {"text": ..., "format":
+// ...} as JSON. format (text, html or eml) is read from the query or the
+// Content-Type, and guessed otherwise. Defanged indicators are refanged.
+// Private addresses, well-known benign domains, file names and allowlisted
+// indicators are dropped unless filter=false. analyze=true queues the
+// analyzable results for analysis. types restricts the result.
+func (h *Handler) ExtractIndicators(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxExtractSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	switch c.ContentType() {
+	case "application/json":
+		var req extractRequest
+		if err := json.Unmarshal(body, &req); err != nil || req.Text == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "want {\"text\": ..., \"format\": ...}"})
+			return
+		}
+		body = []byte(req.Text)
+		if format == "" {
+			format = req.Format
+		}
+	case "text/html", "message/rfc822":
+		if format == "" {
+			format = c.ContentType()
+		}
+	}
+	if format == "" || format == "auto" {
+		format = ioc.DetectFormat(body)
+	} else if format, err = ioc.ParseFormat(format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	text, err := ioc.Text(bytes.NewReader(body), format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := c.DefaultQuery("filter", "true") != "false"
+	extraction := ioc.Extract(text, ioc.ExtractOptions{KeepFalsePositives: !filter})
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	tenantID := auth.TenantID(c)
+	var allow *feeds.Allowlist
+	if filter {
+		entries, err := h.db.ListAllowlist(ctx, tenantID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		allow = feeds.NewAllowlist(entries)
+	}
+
+	types := queryList(c, "types")
+	resp := extractResponse{Indicators: []ioc.Match{}, Counts: map[string]int{}, Filtered: extraction.Filtered}
+	for _, m := range extraction.Matches {
+		if len(types) > 0 && !slices.Contains(types, m.Type) {
+			continue
+		}
+		if allow != nil && m.Type != ioc.TypeEmail && allow.Allows(m.Type, m.Value) {
+			resp.Filtered++
+			continue
+		}
+		resp.Indicators = append(resp.Indicators, m)
+		resp.Counts[m.Type]++
+	}
+
+	if c.Query("analyze") == "true" {
+		budget := h.analysisBudget(c)
+		queued := 0
+		for _, m := range resp.Indicators {
+			if m.Type != ioc.TypeEmail && budget.queue(tenantID, m.Value, m.Type) {
+				queued++
+			}
+		}
+		resp.Queued, resp.RateLimited = &queued, &budget.Limited
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
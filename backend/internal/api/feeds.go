@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxFeedEntries caps a feed; PAN-OS lists top out around 150k entries.
+const maxFeedEntries = 150000
+
+var feedFiles = map[string]string{
+	"ip.txt":     "ip",
+	"domain.txt": "domain",
+	"url.txt":    "url",
+	"hashes.txt": "hash",
+}
+
+// Feed serves a plaintext blocklist of one indicator type, filtered by
+// reputation (malicious unless reputation or min_score is given), tag,
+// min_score and max_age_days, minus the tenant's allowlist. Conditional
+// requests are answered with 304 when the feed hasn't changed.
+func (h *Handler) Feed(c *gin.Context) {
+	indicatorType, ok := feedFiles[c.Param("file")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		return
+	}
+
+	filter := models.ThreatFilter{
+		Types:       []string{indicatorType},
+		Reputations: queryList(c, "reputation"),
+		Tags:        queryList(c, "tag"),
+		Limit:       maxFeedEntries,
+	}
+	if s := c.Query("min_score"); s != "" {
+		score, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_score"})
+			return
+		}
+		filter.MinScore = score
+	}
+	if s := c.Query("max_age_days"); s != "" {
+		days, err := strconv.Atoi(s)
+		if err != nil || days <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_age_days must be a positive integer"})
+			return
+		}
+		filter.UpdatedSince = time.Now().AddDate(0, 0, -days)
+	}
+	if len(filter.Reputations) == 0 && filter.MinScore == 0 {
+		filter.Reputations = []string{"malicious"}
+	}
+	opts := feeds.Options{
+		Format:    c.DefaultQuery("format", feeds.FormatPlain),
+		Aggregate: c.Query("aggregate") != "false",
+	}
+
+	ctx := c.Request.Context()
+	tenantID := auth.TenantID(c)
+	threats, err := h.db.FindThreats(ctx, tenantID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	allowlist, err := h.db.ListAllowlist(ctx, tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries := feeds.Entries(indicatorType, threats, feeds.NewAllowlist(allowlist), opts)
+	body, err := feeds.Render(indicatorType, entries, opts.Format)
+	if err != nil {
+		if errors.Is(err, feeds.ErrFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.serveVersioned(c, tenantID, "text/plain; charset=utf-8", body)
+}
+
+// serveVersioned serves generated content with an ETag and Last-Modified so
+// pollers can make conditional requests.
+func (h *Handler) serveVersioned(c *gin.Context, tenantID, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	modified := h.feedVersions.Modified(tenantID+" "+c.Request.URL.RequestURI(), etag, time.Now())
+
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	http.ServeContent(c.Writer, c.Request, "", modified, bytes.NewReader(body))
+}
+
+func (h *Handler) ListAllowlist(c *gin.Context) {
+	entries, err := h.db.ListAllowlist(c.Request.Context(), auth.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *Handler) CreateAllowlistEntry(c *gin.Context) {
+	var req models.AllowlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entryType, value, err := feeds.AllowlistType(req.Value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry := &models.AllowlistEntry{
+		TenantID: auth.TenantID(c),
+		Value:    value,
+		Type:     entryType,
+		Comment:  req.Comment,
+	}
+	if err := h.db.CreateAllowlistEntry(c.Request.Context(), entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "value is already allowlisted"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *Handler) DeleteAllowlistEntry(c *gin.Context) {
+	if err := h.db.DeleteAllowlistEntry(c.Request.Context(), auth.TenantID(c), c.Param("id")); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Allowlist entry not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Allowlist entry deleted successfully"})
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/graph"
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxGraphDepth = 3
+	// maxGraphNodes bounds a graph; nodes beyond it are left out.
+	maxGraphNodes = 500
+)
+
+// GetThreatGraph returns the relationships around an indicator up to depth
+// hops (1 by default, at most 3), following edges in both directions.
+func (h *Handler) GetThreatGraph(c *gin.Context) {
+	depth, err := graphDepth(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	g, err := h.buildGraph(c.Request.Context(), auth.TenantID(c), []string{c.Param("indicator")}, depth)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threat not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+func graphDepth(c *gin.Context) (int, error) {
+	depth, err := strconv.Atoi(c.DefaultQuery("depth", "1"))
+	if err != nil || depth < 1 || depth > maxGraphDepth {
+		return 0, fmt.Errorf("depth must be between 1 and %d", maxGraphDepth)
+	}
+	return depth, nil
+}
+
+// buildGraph walks the stored edges breadth-first from the roots. It
+// returns mongo.ErrNoDocuments when no root is analyzed or in any edge.
+func (h *Handler) buildGraph(ctx context.Context, tenantID string, roots []string, depth int) (*models.Graph, error) {
+	g := &models.Graph{Roots: roots, Depth: depth, Nodes: []models.GraphNode{}, Edges: []models.GraphEdge{}}
+	index := map[string]int{}
+	for _, root := range roots {
+		if _, ok := index[root]; !ok {
+			index[root] = len(g.Nodes)
+			g.Nodes = append(g.Nodes, models.GraphNode{ID: root, Type: ioc.Detect(root)})
+		}
+	}
+	seen := map[string]bool{}
+
+	// addNode adds a node unless it is already there, reporting whether it
+	// is new.
+	addNode := func(id, nodeType, label string, d int) bool {
+		if i, ok := index[id]; ok {
+			if label != "" {
+				g.Nodes[i].Label = label
+			}
+			return false
+		}
+		index[id] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, models.GraphNode{ID: id, Type: nodeType, Label: label, Depth: d})
+		return true
+	}
+
+	frontier := slices.Clone(roots)
+	for d := 1; d <= depth && len(frontier) > 0; d++ {
+		limit := int64(maxGraphNodes * 4)
+		edges, err := h.db.GraphEdgesOf(ctx, tenantID, frontier, limit)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(edges)) == limit {
+			g.Truncated = true
+		}
+		frontier = nil
+		for _, e := range edges {
+			key := e.From + "\x00" + e.Relation + "\x00" + e.To
+			if seen[key] {
+				continue
+			}
+			added := 0
+			for _, id := range []string{e.From, e.To} {
+				if _, ok := index[id]; !ok {
+					added++
+				}
+			}
+			if len(g.Nodes)+added > maxGraphNodes {
+				g.Truncated = true
+				continue
+			}
+			seen[key] = true
+			g.Edges = append(g.Edges, e)
+			if addNode(e.From, e.FromType, "", d) {
+				frontier = append(frontier, e.From)
+			}
+			if addNode(e.To, e.ToType, e.ToLabel, d) {
+				frontier = append(frontier, e.To)
+			}
+		}
+	}
+
+	// Analyzed indicators carry their verdict.
+	values := make([]string, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		if n.Type != models.NodePulse {
+			values = append(values, n.ID)
+		}
+	}
+	threats, err := h.db.FindKnownThreats(ctx, tenantID, values, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range threats {
+		n := &g.Nodes[index[t.Indicator]]
+		n.Type, n.Reputation = t.Type, t.Reputation
+		score := t.RiskScore
+		n.RiskScore = &score
+	}
+
+	if len(g.Edges) == 0 && len(threats) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return g, nil
+}
+
+// maxGraphSeeds bounds the indicators one graph export starts from.
+const maxGraphSeeds = 50
+
+// ExportGraph writes the relationships around one or more indicators
+// (indicator, repeated or comma-separated) up to depth hops as GraphML or
+// GEXF for Gephi, or as CSV for Maltego's table import (format).
+func (h *Handler) ExportGraph(c *gin.Context) {
+	format, err := graph.Format(c.DefaultQuery("format", graph.FormatGraphML))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	depth, err := graphDepth(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seeds := queryList(c, "indicator")
+	if len(seeds) == 0 || len(seeds) > maxGraphSeeds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("give between 1 and %d indicators", maxGraphSeeds)})
+		return
+	}
+
+	g, err := h.buildGraph(c.Request.Context(), auth.TenantID(c), seeds, depth)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threat not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := graph.Write(&buf, format, g); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="atia-graph-%s.%s"`, time.Now().UTC().Format("20060102"), graph.Extensions[format]))
+	c.Data(http.StatusOK, graph.ContentTypes[format], buf.Bytes())
+}
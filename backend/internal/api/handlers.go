@@ -1,27 +1,71 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
 	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
 	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/ratelimit"
+	"github.com/AEX0TIC/ATIA/backend/internal/rpz"
 	"github.com/AEX0TIC/ATIA/backend/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Handler struct {
 	aggregator *services.Aggregator
 	db         *database.MongoDB
+	// allowedOrigins are the CORS origins, also applied to WebSocket
+	// handshakes.
+	allowedOrigins []string
+	feedVersions   *feeds.Versions
+	zones          *rpz.Zones
+	// limiter and analyzeLimit charge the analyses a request queues, such
+	// as an import's, against the caller's analyze budget.
+	limiter      *ratelimit.Limiter
+	analyzeLimit ratelimit.Policy
 }
 
 func NewHandler(aggregator *services.Aggregator, db *database.MongoDB) *Handler {
 	return &Handler{
-		aggregator: aggregator,
-		db:         db,
+		aggregator:   aggregator,
+		db:           db,
+		feedVersions: feeds.NewVersions(),
 	}
 }
 
+// analysisBudget queues the analyses one request asks for, each counted
+// against the caller's analyze rate limit like a direct analysis. Once the
+// budget runs out the rest are skipped.
+type analysisBudget struct {
+	h         *Handler
+	c         *gin.Context
+	exhausted bool
+	// Limited counts the analyses skipped for the rate limit.
+	Limited int
+}
+
+func (h *Handler) analysisBudget(c *gin.Context) *analysisBudget {
+	return &analysisBudget{h: h, c: c}
+}
+
+// queue queues an analysis if the budget allows, reporting whether it was
+// queued.
+func (b *analysisBudget) queue(tenantID, indicator, indicatorType string) bool {
+	if !b.exhausted && b.h.limiter != nil && !b.h.limiter.Allow(b.c, b.h.analyzeLimit) {
+		b.exhausted = true
+	}
+	if b.exhausted {
+		b.Limited++
+		return false
+	}
+	return b.h.aggregator.Queue().Enqueue(tenantID, indicator, indicatorType)
+}
+
 func (h *Handler) AnalyzeIndicator(c *gin.Context) {
 	var req models.AnalysisRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -32,7 +76,7 @@ func (h *Handler) AnalyzeIndicator(c *gin.Context) {
 		return
 	}
 
-	threat, err := h.aggregator.AnalyzeIndicator(req.Indicator, req.Type)
+	threat, err := h.aggregator.AnalyzeIndicator(c.Request.Context(), auth.TenantID(c), req.Indicator, req.Type)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.AnalysisResponse{
 			Success: false,
@@ -50,7 +94,7 @@ func (h *Handler) AnalyzeIndicator(c *gin.Context) {
 func (h *Handler) GetThreat(c *gin.Context) {
 	indicator := c.Param("indicator")
 
-	threat, err := h.db.GetThreat(indicator)
+	threat, err := h.db.GetThreat(c.Request.Context(), auth.TenantID(c), indicator)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Threat not found"})
 		return
@@ -59,8 +103,16 @@ func (h *Handler) GetThreat(c *gin.Context) {
 	c.JSON(http.StatusOK, threat)
 }
 
+// GetAllThreats lists the latest analyses, or with sort=last_sighted the
+// most recently sighted indicators.
 func (h *Handler) GetAllThreats(c *gin.Context) {
-	threats, err := h.db.GetAllThreats(100)
+	sortBy := c.DefaultQuery("sort", database.SortLastUpdated)
+	if sortBy != database.SortLastUpdated && sortBy != database.SortLastSighted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be last_updated or last_sighted"})
+		return
+	}
+
+	threats, err := h.db.GetAllThreats(c.Request.Context(), auth.TenantID(c), 100, sortBy)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -72,7 +124,7 @@ func (h *Handler) GetAllThreats(c *gin.Context) {
 func (h *Handler) GetThreatHistory(c *gin.Context) {
 	indicator := c.Param("indicator")
 
-	history, err := h.db.GetThreatHistory(indicator)
+	history, err := h.db.GetThreatHistory(c.Request.Context(), auth.TenantID(c), indicator)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -87,7 +139,11 @@ func (h *Handler) GetThreatHistory(c *gin.Context) {
 func (h *Handler) DeleteThreat(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := h.db.DeleteThreat(id); err != nil {
+	if err := h.aggregator.DeleteThreat(c.Request.Context(), auth.TenantID(c), id); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Threat not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -95,6 +151,27 @@ func (h *Handler) DeleteThreat(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Threat deleted successfully"})
 }
 
+func (h *Handler) UpdateThreatNotes(c *gin.Context) {
+	indicator := c.Param("indicator")
+
+	var req models.NotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.UpdateThreatNotes(c.Request.Context(), auth.TenantID(c), indicator, req.Notes); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Threat not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notes updated"})
+}
+
 func (h *Handler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "healthy",
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal := auth.PrincipalFrom(c)
+	tenantID := principal.TenantID
+	if req.TenantID != "" && req.TenantID != tenantID {
+		if !principal.IsOperator() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only operators may issue keys for other tenants"})
+			return
+		}
+		if _, err := h.db.GetTenant(c.Request.Context(), req.TenantID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown tenant: " + req.TenantID})
+			return
+		}
+		tenantID = req.TenantID
+	}
+
+	plaintext, err := auth.GenerateKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := &models.APIKey{
+		TenantID: tenantID,
+		Name:     req.Name,
+		Prefix:   plaintext[:12],
+		Hash:     auth.HashKey(plaintext),
+		Scopes:   req.Scopes,
+	}
+	if err := h.db.CreateAPIKey(c.Request.Context(), key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{
+		Key:    plaintext,
+		APIKey: key,
+	})
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.db.ListAPIKeys(c.Request.Context(), keyScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.db.RevokeAPIKey(c.Request.Context(), keyScope(c), id); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// keyScope limits key management to the caller's tenant; operators see all
+// tenants.
+func keyScope(c *gin.Context) string {
+	if p := auth.PrincipalFrom(c); p.IsOperator() {
+		return ""
+	}
+	return auth.TenantID(c)
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/logs"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+var logsLog = logging.For("logs")
+
+const (
+	// maxLogUploadSize caps a log upload.
+	maxLogUploadSize = 256 << 20
+	// maxLogLine is the longest line read; longer ones fail the upload.
+	maxLogLine = 1 << 20
+	// logBatchSize is how many distinct values are matched per lookup.
+	logBatchSize = 5000
+	// maxLogEnrich caps the unseen indicators one upload queues.
+	maxLogEnrich = 100
+)
+
+type logIngestResult struct {
+	Lines        int              `json:"lines"`
+	Observations int              `json:"observations"`
+	Matches      int              `json:"matches"`
+	Sightings    int              `json:"sightings"`
+	Queued       int              `json:"queued"`
+	RateLimited  int              `json:"rate_limited"`
+	Failed       int              `json:"failed"`
+	Errors       []importRowError `json:"errors"`
+}
+
+type sightingKey struct {
+	indicator, host, source string
+}
+
+// IngestLogs reads Zeek (TSV or JSON), Suricata eve.json or syslog lines
+// (format, auto-detected by default) and matches the IPs, domains, URLs
+// and hashes in them against the tenant's malicious and suspicious
+// indicators. Matches are stored as sightings, one per indicator, host
+// and log, and raised as indicator_sighted events. source and host
+// override the log's own source and fill in a missing host. With
+// enrich=true, public indicators the tenant has never analyzed are queued
+// for analysis.
+func (h *Handler) IngestLogs(c *gin.Context) {
+	parser, err := logs.NewParser(c.DefaultQuery("format", logs.FormatAuto))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	source, host := c.Query("source"), c.Query("host")
+	enrich := c.Query("enrich") == "true"
+
+	// Uploads are matched as they are read, so large ones outlast the
+	// server's read and write timeouts.
+	clearDeadlines(c)
+
+	ctx := c.Request.Context()
+	tenantID := auth.TenantID(c)
+	result := logIngestResult{Errors: []importRowError{}}
+	matched := map[string]bool{}
+	queued := map[string]bool{}
+	budget := h.analysisBudget(c)
+
+	values := map[string]string{}
+	batch := map[sightingKey]*models.Sighting{}
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		// A stored domain covers its subdomains, as in the RPZ and rule
+		// exports, so observed names are also looked up by their parents.
+		lookup := map[string]bool{}
+		parents := map[string][]string{}
+		for v, indicatorType := range values {
+			lookup[v] = true
+			if indicatorType == ioc.TypeDomain {
+				parents[v] = ioc.ParentDomains(v)
+				for _, p := range parents[v] {
+					lookup[p] = true
+				}
+			}
+		}
+		indicators := make([]string, 0, len(lookup))
+		for v := range lookup {
+			indicators = append(indicators, v)
+		}
+		known, err := h.db.FindKnownThreats(ctx, tenantID, indicators, nil)
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]bool, len(known))
+		threats := make(map[string]*models.ThreatIndicator, len(known))
+		for i := range known {
+			stored[known[i].Indicator] = true
+			if slices.Contains(services.SightedReputations, known[i].Reputation) {
+				threats[known[i].Indicator] = &known[i]
+			}
+		}
+
+		// The sighting is recorded against the nearest stored match.
+		covered := map[string]bool{}
+		var sightings []models.Sighting
+		for _, s := range batch {
+			t := threats[s.Indicator]
+			for i := 0; t == nil && i < len(parents[s.Indicator]); i++ {
+				t = threats[parents[s.Indicator][i]]
+			}
+			if t == nil {
+				continue
+			}
+			covered[s.Indicator] = true
+			sighting := *s
+			sighting.Indicator, sighting.IndicatorType = t.Indicator, t.Type
+			sightings = append(sightings, sighting)
+			matched[t.Indicator] = true
+		}
+		if err := h.aggregator.RecordSightings(ctx, sightings); err != nil {
+			return err
+		}
+		result.Sightings += len(sightings)
+
+		if enrich {
+			for v, indicatorType := range values {
+				if len(queued) >= maxLogEnrich {
+					break
+				}
+				if stored[v] || covered[v] || queued[v] || !ioc.Public(indicatorType, v) {
+					continue
+				}
+				queued[v] = true
+				if budget.queue(tenantID, v, indicatorType) {
+					result.Queued++
+				}
+			}
+		}
+
+		clear(values)
+		clear(batch)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(http.MaxBytesReader(c.Writer, c.Request.Body, maxLogUploadSize))
+	scanner.Buffer(make([]byte, 64<<10), maxLogLine)
+	for scanner.Scan() {
+		result.Lines++
+		obs, err := parser.Line(scanner.Text())
+		if err != nil {
+			result.Failed++
+			if len(result.Errors) < maxImportErrors {
+				result.Errors = append(result.Errors, importRowError{Line: result.Lines, Error: err.Error()})
+			}
+			continue
+		}
+
+		for _, ob := range obs {
+			result.Observations++
+			if source != "" {
+				ob.Source = source
+			}
+			if ob.Host == "" {
+				ob.Host = host
+			}
+			// Log clocks can run ahead; sightings are never dated later
+			// than now.
+			if now := time.Now(); ob.Time.IsZero() || ob.Time.After(now) {
+				ob.Time = now
+			}
+
+			key := sightingKey{ob.Value, ob.Host, ob.Source}
+			if s := batch[key]; s != nil {
+				s.Count++
+				if ob.Time.After(s.ObservedAt) {
+					s.ObservedAt = ob.Time
+				}
+				continue
+			}
+			values[ob.Value] = ob.Type
+			batch[key] = &models.Sighting{
+				TenantID:      tenantID,
+				Indicator:     ob.Value,
+				IndicatorType: ob.Type,
+				ObservedAt:    ob.Time,
+				Source:        ob.Source,
+				Host:          ob.Host,
+				Count:         1,
+				Context:       ob.Context,
+			}
+		}
+
+		if len(values) >= logBatchSize {
+			if err := flush(); err != nil {
+				logsLog.ErrorContext(ctx, "log ingestion failed", "error", err, "lines", result.Lines)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record sightings", "result": result})
+				return
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if err := flush(); err != nil {
+		logsLog.ErrorContext(ctx, "log ingestion failed", "error", err, "lines", result.Lines)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record sightings", "result": result})
+		return
+	}
+
+	result.Matches = len(matched)
+	result.RateLimited = budget.Limited
+	logsLog.InfoContext(ctx, "logs ingested", "lines", result.Lines, "sightings", result.Sightings, "queued", result.Queued)
+	c.JSON(http.StatusOK, result)
+}
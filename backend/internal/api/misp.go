@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/egress"
+	"github.com/AEX0TIC/ATIA/backend/internal/misp"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxMISPImportSize caps an uploaded event document.
+	maxMISPImportSize = 32 << 20
+	// maxMISPFeedEvents caps the events read from one feed per import.
+	maxMISPFeedEvents = 500
+	// maxImportErrors caps the errors listed in an import response.
+	maxImportErrors = 100
+)
+
+// mispFeedClient fetches caller-supplied feed URLs, so it may only reach
+// public addresses.
+var mispFeedClient = &http.Client{Timeout: 30 * time.Second, Transport: egress.Transport()}
+
+// ExportMISP returns the indicators given, or else the analyses matching
+// the export filter, as one MISP event. info names the event, tlp sets its
+// TLP tag (amber by default) and distribution its MISP distribution (0,
+// your organisation only, by default).
+func (h *Handler) ExportMISP(c *gin.Context) {
+	filter, err := threatFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts := misp.ExportOptions{
+		TenantID:     auth.TenantID(c),
+		Info:         c.DefaultQuery("info", "ATIA threat intelligence export"),
+		TLP:          c.DefaultQuery("tlp", "amber"),
+		Distribution: misp.DistributionOrganisation,
+	}
+	if s := c.Query("distribution"); s != "" {
+		d, err := strconv.Atoi(s)
+		if err != nil || d < misp.DistributionOrganisation || d > misp.DistributionAll {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "distribution must be between 0 and 3"})
+			return
+		}
+		opts.Distribution = d
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var threats []models.ThreatIndicator
+	indicators := queryList(c, "indicator")
+	if len(indicators) == 0 {
+		threats, err = h.db.FindThreats(ctx, opts.TenantID, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for _, indicator := range indicators {
+		threat, err := h.db.GetThreat(ctx, opts.TenantID, indicator)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Threat %q not found", indicator)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		threats = append(threats, *threat)
+	}
+
+	event, err := misp.Export(threats, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+event.Event.UUID+`.json"`)
+	c.JSON(http.StatusOK, event)
+}
+
+type mispFeedRequest struct {
+	FeedURL   string     `json:"feed_url"`
+	Since     *time.Time `json:"since"`
+	MaxEvents int        `json:"max_events"`
+}
+
+type mispImportResult struct {
+	Events      int      `json:"events"`
+	Created     int      `json:"created"`
+	Existing    int      `json:"existing"`
+	Queued      int      `json:"queued"`
+	RateLimited int      `json:"rate_limited"`
+	Skipped     int      `json:"skipped"`
+	Errors      []string `json:"errors"`
+}
+
+// ImportMISP stores the indicators of MISP events posted as event JSON, or
+// read from a feed when the body is {"feed_url": ...}. Event and attribute
+// tags become indicator tags. New and existing indicators are queued for
+// analysis unless analyze=false.
+func (h *Handler) ImportMISP(c *gin.Context) {
+	// Feeds are fetched event by event, which outlasts the server's read
+	// and write timeouts.
+	clearDeadlines(c)
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxMISPImportSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	analyze := c.DefaultQuery("analyze", "true") != "false"
+
+	var feed mispFeedRequest
+	var events []misp.Event
+	var failed []error
+	if json.Unmarshal(body, &feed) == nil && feed.FeedURL != "" {
+		if err := egress.CheckURL(c.Request.Context(), feed.FeedURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "feed_url " + err.Error()})
+			return
+		}
+		opts := misp.FetchOptions{MaxEvents: maxMISPFeedEvents}
+		if feed.Since != nil {
+			opts.Since = *feed.Since
+		}
+		if feed.MaxEvents > 0 && feed.MaxEvents < maxMISPFeedEvents {
+			opts.MaxEvents = feed.MaxEvents
+		}
+		if events, failed, err = misp.FetchFeed(c.Request.Context(), mispFeedClient, feed.FeedURL, opts); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+	} else if events, err = misp.Parse(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := auth.TenantID(c)
+	result := mispImportResult{Events: len(events), Errors: []string{}}
+	for _, err := range failed {
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+	budget := h.analysisBudget(c)
+	for _, event := range events {
+		indicators, skipped := misp.Indicators(event)
+		result.Skipped += skipped
+		for _, ind := range indicators {
+			threat := &models.ThreatIndicator{
+				TenantID:  tenantID,
+				Indicator: ind.Value,
+				Type:      ind.Type,
+				Tags:      ind.Tags,
+				Metadata: map[string]interface{}{
+					"misp_event_uuid": event.UUID,
+					"misp_event_info": event.Info,
+					"misp_category":   ind.Category,
+				},
+			}
+			created, err := h.db.ImportThreat(c.Request.Context(), threat)
+			if err != nil {
+				if len(result.Errors) < maxImportErrors {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", ind.Value, err))
+				}
+				continue
+			}
+			if created {
+				result.Created++
+			} else {
+				result.Existing++
+			}
+			if analyze && budget.queue(tenantID, ind.Value, ind.Type) {
+				result.Queued++
+			}
+		}
+	}
+
+	result.RateLimited = budget.Limited
+	c.JSON(http.StatusOK, result)
+}
@@ -1,28 +1,121 @@
 package api
 
 import (
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
 	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/ratelimit"
+	"github.com/AEX0TIC/ATIA/backend/internal/rpz"
 	"github.com/AEX0TIC/ATIA/backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, aggregator *services.Aggregator, db *database.MongoDB) {
+func SetupRoutes(router *gin.Engine, aggregator *services.Aggregator, db *database.MongoDB, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, limits ratelimit.Policies, allowedOrigins []string, zones *rpz.Zones) {
 	handler := NewHandler(aggregator, db)
+	handler.allowedOrigins = allowedOrigins
+	handler.zones = zones
+	handler.limiter, handler.analyzeLimit = limiter, limits.Analyze
 
 	// Health check
 	router.GET("/health", handler.HealthCheck)
 
+	// Prometheus metrics
+	router.GET("/metrics", metrics.Handler())
+
 	// API v1
+	// Per-IP budgets are enforced before authentication, so requests with
+	// bad keys are counted, and per-key budgets after it.
 	v1 := router.Group("/api/v1")
+
+	// Analysis endpoints
+	analyze := v1.Group("", limiter.IPMiddleware(limits.Analyze), authenticator.Middleware(), auth.RequireScope(models.ScopeAnalyze), limiter.KeyMiddleware(limits.Analyze))
+	{
+		analyze.POST("/analyze", handler.AnalyzeIndicator)
+		analyze.GET("/analyze/stream", handler.StreamAnalysis)
+		analyze.POST("/extract", handler.ExtractIndicators)
+		analyze.POST("/logs", handler.IngestLogs)
+		analyze.POST("/sightings", handler.CreateSightings)
+		analyze.PUT("/threats/:indicator/notes", handler.UpdateThreatNotes)
+	}
+
+	// Threat endpoints
+	read := v1.Group("", limiter.IPMiddleware(limits.Read), authenticator.Middleware(), auth.RequireScope(models.ScopeRead), limiter.KeyMiddleware(limits.Read))
+	{
+		read.GET("/threats", handler.GetAllThreats)
+		read.GET("/threats/:indicator", handler.GetThreat)
+		read.GET("/threats/:indicator/history", handler.GetThreatHistory)
+		read.GET("/threats/:indicator/sightings", handler.ListSightings)
+		read.GET("/threats/:indicator/graph", handler.GetThreatGraph)
+		read.GET("/threats/:indicator/stix", handler.GetThreatSTIX)
+		read.GET("/export/stix", handler.ExportSTIX)
+		read.GET("/export/rpz", handler.ExportRPZ)
+		read.GET("/export/rules/:file", handler.ExportRules)
+		read.GET("/export/misp", handler.ExportMISP)
+		read.GET("/export/graph", handler.ExportGraph)
+		read.GET("/export", handler.ExportThreats)
+		read.GET("/shared/:indicator", handler.GetSharedVerdict)
+		read.GET("/live/threats", handler.ThreatFeed)
+	}
+
+	// Admin endpoints
+	admin := v1.Group("", limiter.IPMiddleware(limits.Read), authenticator.Middleware(), auth.RequireScope(models.ScopeAdmin), limiter.KeyMiddleware(limits.Read))
+	{
+		admin.DELETE("/threats/:id", handler.DeleteThreat)
+
+		admin.GET("/admin/keys", handler.ListAPIKeys)
+		admin.POST("/admin/keys", handler.CreateAPIKey)
+		admin.DELETE("/admin/keys/:id", handler.RevokeAPIKey)
+
+		admin.GET("/webhooks", handler.ListWebhooks)
+		admin.POST("/webhooks", handler.CreateWebhook)
+		admin.GET("/webhooks/:id", handler.GetWebhook)
+		admin.PUT("/webhooks/:id", handler.UpdateWebhook)
+		admin.DELETE("/webhooks/:id", handler.DeleteWebhook)
+		admin.POST("/webhooks/:id/secret", handler.RotateWebhookSecret)
+		admin.GET("/webhooks/:id/deliveries", handler.ListWebhookDeliveries)
+		admin.GET("/webhooks/deliveries", handler.ListWebhookDeliveries)
+		admin.POST("/webhooks/deliveries/:id/replay", handler.ReplayWebhookDelivery)
+
+		admin.GET("/allowlist", handler.ListAllowlist)
+		admin.POST("/allowlist", handler.CreateAllowlistEntry)
+		admin.DELETE("/allowlist/:id", handler.DeleteAllowlistEntry)
+
+		admin.GET("/taxii/collections", handler.ListTAXIICollections)
+		admin.POST("/taxii/collections", handler.CreateTAXIICollection)
+		admin.PUT("/taxii/collections/:id", handler.UpdateTAXIICollection)
+		admin.DELETE("/taxii/collections/:id", handler.DeleteTAXIICollection)
+
+		admin.POST("/misp/import", handler.ImportMISP)
+		admin.POST("/import", handler.ImportThreats)
+	}
+
+	// Blocklist feeds for firewalls and proxies
+	feedGroup := router.Group("/feeds", limiter.IPMiddleware(limits.Read), authenticator.Middleware(), auth.RequireScope(models.ScopeRead), limiter.KeyMiddleware(limits.Read))
+	{
+		feedGroup.GET("/:file", handler.Feed)
+		feedGroup.HEAD("/:file", handler.Feed)
+	}
+
+	// TAXII 2.1, read-only, over the caller's tenant's collections
+	taxii := router.Group("/taxii2", limiter.IPMiddleware(limits.Read), authenticator.Middleware(), auth.RequireScope(models.ScopeRead), limiter.KeyMiddleware(limits.Read), TAXIIAccept())
+	{
+		taxii.GET("/", handler.TAXIIDiscovery)
+		taxii.GET("/api/", handler.TAXIIAPIRoot)
+		taxii.GET("/api/collections/", handler.TAXIICollections)
+		taxii.GET("/api/collections/:id/", handler.TAXIICollection)
+		taxii.GET("/api/collections/:id/objects/", handler.TAXIIObjects)
+		taxii.GET("/api/collections/:id/objects/:object_id/", handler.TAXIIObject)
+		taxii.GET("/api/collections/:id/objects/:object_id/versions/", handler.TAXIIObjectVersions)
+		taxii.GET("/api/collections/:id/manifest/", handler.TAXIIManifest)
+	}
+
+	// Operator endpoints (admins of the default tenant)
+	operator := admin.Group("/admin/tenants", auth.RequireOperator())
 	{
-		// Analysis endpoints
-		v1.POST("/analyze", handler.AnalyzeIndicator)
-
-		// Threat endpoints
-		v1.GET("/threats", handler.GetAllThreats)
-		v1.GET("/threats/:indicator", handler.GetThreat)
-		v1.GET("/threats/:indicator/history", handler.GetThreatHistory)
-		v1.DELETE("/threats/:id", handler.DeleteThreat)
+		operator.GET("", handler.ListTenants)
+		operator.POST("", handler.CreateTenant)
+		operator.PUT("/:id", handler.UpdateTenant)
 	}
 }
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportRPZ serves the tenant's response policy zone as a master file. The
+// ETag is the zone serial, so unchanged zones are answered with 304.
+func (h *Handler) ExportRPZ(c *gin.Context) {
+	zone, err := h.zones.Current(c.Request.Context(), auth.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := strings.TrimSuffix(zone.Origin, ".") + ".zone"
+	c.Header("Content-Type", "text/dns; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("ETag", `"`+strconv.FormatUint(uint64(zone.Serial), 10)+`"`)
+	c.Header("Cache-Control", "no-cache")
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(zone.File()))
+}
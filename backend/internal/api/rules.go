@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/rules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ruleFiles maps downloadable files to the rule flavor they belong to.
+var ruleFiles = map[string]string{
+	"atia.yar":                rules.FlavorYARA,
+	"sigma.yml":               rules.FlavorSigma,
+	"suricata.rules":          rules.FlavorSuricata,
+	"snort.rules":             rules.FlavorSnort,
+	rules.IPRepFile:           rules.FlavorSuricata,
+	rules.IPRepCategoriesFile: rules.FlavorSuricata,
+	rules.MD5File:             rules.FlavorSuricata,
+	rules.SHA1File:            rules.FlavorSuricata,
+	rules.SHA256File:          rules.FlavorSuricata,
+}
+
+// ExportRules serves a Suricata, Snort, YARA or Sigma rule file, or one of
+// the lists the Suricata rules reference, built from the analyses matching
+// the export filter. Like feeds, only malicious verdicts are included unless
+// reputation or min_score is given.
+func (h *Handler) ExportRules(c *gin.Context) {
+	file := c.Param("file")
+	flavor, ok := ruleFiles[file]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule file not found"})
+		return
+	}
+
+	filter, err := threatFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("limit") == "" {
+		filter.Limit = maxFeedEntries
+	}
+	if len(filter.Reputations) == 0 && filter.MinScore == 0 {
+		filter.Reputations = []string{"malicious"}
+	}
+
+	ctx := c.Request.Context()
+	tenantID := auth.TenantID(c)
+	threats, err := h.db.FindThreats(ctx, tenantID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	allowlist, err := h.db.ListAllowlist(ctx, tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	allow := feeds.NewAllowlist(allowlist)
+	var body []byte
+	switch flavor {
+	case rules.FlavorYARA:
+		body = rules.YARA(threats, allow)
+	case rules.FlavorSigma:
+		body = rules.Sigma(threats, allow)
+	default:
+		nids, err := rules.BuildNIDS(flavor, threats, allow)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var ok bool
+		if body, ok = nids.File(file); !ok {
+			body = nids.Rules()
+		}
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+file+`"`)
+	h.serveVersioned(c, tenantID, "text/plain; charset=utf-8", body)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	// maxSightingsPerRequest caps one POST /sightings.
+	maxSightingsPerRequest = 1000
+	// maxSightingContext is the most raw context kept per sighting.
+	maxSightingContext = 4096
+	maxSightingsLimit  = 1000
+	// maxClockSkew is how far past now an observed_at may be. Later dates
+	// are refused: stored last-sighted times only move forward, so one
+	// would keep the full sighting bonus until that date.
+	maxClockSkew = 5 * time.Minute
+)
+
+// CreateSightings records one sighting, or a JSON array of them, reported
+// by an outside system such as an EDR or proxy. Sightings of analyzed
+// indicators update their sighting count and score.
+func (h *Handler) CreateSightings(c *gin.Context) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var reqs []models.SightingRequest
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		err = binding.JSON.BindBody(raw, &reqs)
+	} else {
+		var req models.SightingRequest
+		err = binding.JSON.BindBody(raw, &req)
+		reqs = append(reqs, req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxSightingsPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("send between 1 and %d sightings", maxSightingsPerRequest)})
+		return
+	}
+
+	tenantID := auth.TenantID(c)
+	now := time.Now()
+	sightings := make([]models.Sighting, 0, len(reqs))
+	for i, req := range reqs {
+		indicator := strings.TrimSpace(req.Indicator)
+		indicatorType := req.Type
+		if indicatorType == "" {
+			indicatorType = ioc.Detect(indicator)
+		}
+		if !ioc.Valid(indicatorType, indicator) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("sighting %d: %q is not a valid indicator", i, req.Indicator)})
+			return
+		}
+		observedAt := req.ObservedAt
+		if observedAt.IsZero() {
+			observedAt = now
+		}
+		if observedAt.After(now.Add(maxClockSkew)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("sighting %d: observed_at %s is in the future", i, observedAt.Format(time.RFC3339))})
+			return
+		}
+		if observedAt.After(now) {
+			observedAt = now
+		}
+		count := req.Count
+		if count == 0 {
+			count = 1
+		}
+		context := req.Context
+		if len(context) > maxSightingContext {
+			context = strings.ToValidUTF8(context[:maxSightingContext], "")
+		}
+
+		sightings = append(sightings, models.Sighting{
+			TenantID:      tenantID,
+			Indicator:     indicator,
+			IndicatorType: indicatorType,
+			ObservedAt:    observedAt,
+			Source:        req.Source,
+			Host:          req.Host,
+			Count:         count,
+			Context:       context,
+		})
+	}
+
+	if err := h.aggregator.RecordSightings(c.Request.Context(), sightings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record sightings"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"sightings": sightings})
+}
+
+// ListSightings returns an indicator's sightings, most recent first,
+// optionally since a time (RFC 3339), up to limit.
+func (h *Handler) ListSightings(c *gin.Context) {
+	indicator := c.Param("indicator")
+
+	var since time.Time
+	if s := c.Query("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid since %q, want RFC 3339", s)})
+			return
+		}
+	}
+	limit := int64(100)
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 || n > maxSightingsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSightingsLimit)})
+			return
+		}
+		limit = n
+	}
+
+	sightings, err := h.db.ListSightings(c.Request.Context(), auth.TenantID(c), indicator, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"indicator": indicator, "sightings": sightings})
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var streamLog = logging.For("stream")
+
+const (
+	livePingInterval = 30 * time.Second
+	liveWriteTimeout = 10 * time.Second
+)
+
+type sourceUpdate struct {
+	Name   string             `json:"name"`
+	Source *models.SourceData `json:"source,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+type liveMessage struct {
+	Type   string                  `json:"type"`
+	Threat *models.ThreatIndicator `json:"threat"`
+}
+
+// StreamAnalysis runs an analysis and reports it over Server-Sent Events: a
+// "started" event, one "source" event per provider as it answers, then
+// "result" (an AnalysisResponse) or "error".
+func (h *Handler) StreamAnalysis(c *gin.Context) {
+	var req models.AnalysisRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.AnalysisResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Providers may take longer than the server's write timeout.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	ctx := c.Request.Context()
+	updates := make(chan events.Event)
+	type result struct {
+		threat *models.ThreatIndicator
+		err    error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		threat, err := h.aggregator.AnalyzeIndicatorWithProgress(ctx, auth.TenantID(c), req.Indicator, req.Type, func(e events.Event) {
+			select {
+			case updates <- e:
+			case <-ctx.Done():
+			}
+		})
+		done <- result{threat, err}
+	}()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e := <-updates:
+			if e.Type == events.AnalysisStarted {
+				c.SSEvent("started", gin.H{"indicator": e.Indicator, "type": e.IndicatorType})
+			} else {
+				c.SSEvent("source", sourceUpdate{Name: e.SourceName, Source: e.Source, Error: e.SourceError})
+			}
+			return true
+		case r := <-done:
+			if r.err != nil {
+				c.SSEvent("error", models.AnalysisResponse{Success: false, Error: r.err.Error()})
+			} else {
+				c.SSEvent("result", models.AnalysisResponse{Success: true, Data: r.threat})
+			}
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// ThreatFeed upgrades to a WebSocket that pushes the tenant's newly
+// analyzed and deleted threats as they happen. A client that can't keep up
+// misses messages rather than slowing anyone down.
+func (h *Handler) ThreatFeed(c *gin.Context) {
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already replied with an error status.
+		return
+	}
+	defer conn.Close()
+
+	tenantID := auth.TenantID(c)
+	unsubscribe := h.aggregator.Events().Subscribe("live_feed", events.SubscribeOptions{
+		Types:        []string{events.AnalysisCompleted, events.IndicatorDeleted},
+		Buffer:       64,
+		DropWhenFull: true,
+	}, func(e events.Event) {
+		if e.TenantID != tenantID {
+			return
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		if err := conn.WriteJSON(liveMessage{Type: e.Type, Threat: e.Threat}); err != nil {
+			conn.Close()
+		}
+	})
+	defer unsubscribe()
+
+	// The read loop only handles control frames; it ends when the client
+	// goes away or a write fails.
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * livePingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * livePingInterval))
+	})
+	go func() {
+		ticker := time.NewTicker(livePingInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			streamLog.DebugContext(c.Request.Context(), "live feed closed", "error", err)
+			return
+		}
+	}
+}
+
+// checkOrigin applies the CORS origin list to WebSocket handshakes, which
+// browsers don't subject to CORS. Same-host and non-browser clients are
+// always allowed.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
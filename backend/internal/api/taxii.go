@@ -0,0 +1,490 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	taxiiMediaType      = "application/taxii+json;version=2.1"
+	taxiiAPIRoot        = "/taxii2/api/"
+	defaultTAXIILimit   = 100
+	maxTAXIILimit       = 1000
+	taxiiMaxContentSize = 10 << 20
+)
+
+// taxiiTime formats timestamps the way STIX objects carry them, so clients
+// can feed X-TAXII-Date-Added-Last back as added_after.
+func taxiiTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func taxiiError(c *gin.Context, status int, title, description string) {
+	c.Header("Content-Type", taxiiMediaType)
+	c.AbortWithStatusJSON(status, gin.H{
+		"title":       title,
+		"description": description,
+		"http_status": strconv.Itoa(status),
+	})
+}
+
+func taxiiJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", taxiiMediaType)
+	c.JSON(status, body)
+}
+
+// TAXIIAccept rejects requests that can't take TAXII 2.1 responses. Plain
+// JSON is allowed so the endpoints can be explored with curl.
+func TAXIIAccept() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accept := c.GetHeader("Accept")
+		if accept != "" && !strings.Contains(accept, "application/taxii+json") &&
+			!strings.Contains(accept, "application/json") && !strings.Contains(accept, "*/*") {
+			taxiiError(c, http.StatusNotAcceptable, "Not Acceptable", "this server only serves "+taxiiMediaType)
+			return
+		}
+		c.Next()
+	}
+}
+
+// TAXIIDiscovery lists the single API root.
+func (h *Handler) TAXIIDiscovery(c *gin.Context) {
+	root := requestOrigin(c) + taxiiAPIRoot
+	taxiiJSON(c, http.StatusOK, gin.H{
+		"title":       "ATIA TAXII Server",
+		"description": "Threat intelligence analyzed by ATIA",
+		"default":     root,
+		"api_roots":   []string{root},
+	})
+}
+
+// TAXIIAPIRoot describes the API root. Its collections are the caller's
+// tenant's.
+func (h *Handler) TAXIIAPIRoot(c *gin.Context) {
+	taxiiJSON(c, http.StatusOK, gin.H{
+		"title":              "ATIA",
+		"description":        "Collections defined by saved filters over analyzed indicators",
+		"versions":           []string{taxiiMediaType},
+		"max_content_length": taxiiMaxContentSize,
+	})
+}
+
+type taxiiCollectionResource struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	CanRead     bool     `json:"can_read"`
+	CanWrite    bool     `json:"can_write"`
+	MediaTypes  []string `json:"media_types"`
+}
+
+func newTAXIICollectionResource(coll *models.TAXIICollection) taxiiCollectionResource {
+	return taxiiCollectionResource{
+		ID:          coll.ID,
+		Title:       coll.Title,
+		Description: coll.Description,
+		CanRead:     true,
+		MediaTypes:  []string{stix.MediaType},
+	}
+}
+
+func (h *Handler) TAXIICollections(c *gin.Context) {
+	colls, err := h.db.ListTAXIICollections(c.Request.Context(), auth.TenantID(c))
+	if err != nil {
+		taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+		return
+	}
+
+	resources := make([]taxiiCollectionResource, 0, len(colls))
+	for i := range colls {
+		resources = append(resources, newTAXIICollectionResource(&colls[i]))
+	}
+	body := gin.H{}
+	if len(resources) > 0 {
+		body["collections"] = resources
+	}
+	taxiiJSON(c, http.StatusOK, body)
+}
+
+func (h *Handler) TAXIICollection(c *gin.Context) {
+	coll, ok := h.taxiiCollection(c)
+	if !ok {
+		return
+	}
+	taxiiJSON(c, http.StatusOK, newTAXIICollectionResource(coll))
+}
+
+// TAXIIObjects serves a page of the collection's objects, oldest first.
+func (h *Handler) TAXIIObjects(c *gin.Context) {
+	coll, ok := h.taxiiCollection(c)
+	if !ok {
+		return
+	}
+	page, ok := h.taxiiPage(c, coll)
+	if !ok {
+		return
+	}
+
+	objects := make([]json.RawMessage, 0, len(page.records))
+	for _, r := range page.records {
+		objects = append(objects, r.raw)
+	}
+	page.setHeaders(c)
+	taxiiJSON(c, http.StatusOK, taxiiEnvelope{More: page.more, Next: page.next, Objects: objects})
+}
+
+// TAXIIManifest serves the same page as TAXIIObjects, describing the
+// objects instead of returning them.
+func (h *Handler) TAXIIManifest(c *gin.Context) {
+	coll, ok := h.taxiiCollection(c)
+	if !ok {
+		return
+	}
+	page, ok := h.taxiiPage(c, coll)
+	if !ok {
+		return
+	}
+
+	manifest := make([]taxiiManifestRecord, 0, len(page.records))
+	for _, r := range page.records {
+		manifest = append(manifest, taxiiManifestRecord{
+			ID:        r.id,
+			DateAdded: taxiiTime(r.dateAdded),
+			Version:   r.version,
+			MediaType: stix.MediaType,
+		})
+	}
+	page.setHeaders(c)
+	taxiiJSON(c, http.StatusOK, taxiiManifest{More: page.more, Next: page.next, Objects: manifest})
+}
+
+// TAXIIObject returns one object. ATIA keeps a single version of each.
+func (h *Handler) TAXIIObject(c *gin.Context) {
+	coll, ok := h.taxiiCollection(c)
+	if !ok {
+		return
+	}
+	record, ok := h.findTAXIIObject(c, coll, c.Param("object_id"))
+	if !ok {
+		return
+	}
+	taxiiJSON(c, http.StatusOK, taxiiEnvelope{Objects: []json.RawMessage{record.raw}})
+}
+
+func (h *Handler) TAXIIObjectVersions(c *gin.Context) {
+	coll, ok := h.taxiiCollection(c)
+	if !ok {
+		return
+	}
+	record, ok := h.findTAXIIObject(c, coll, c.Param("object_id"))
+	if !ok {
+		return
+	}
+	taxiiJSON(c, http.StatusOK, gin.H{"more": false, "versions": []string{record.version}})
+}
+
+type taxiiEnvelope struct {
+	More    bool              `json:"more"`
+	Next    string            `json:"next,omitempty"`
+	Objects []json.RawMessage `json:"objects,omitempty"`
+}
+
+type taxiiManifest struct {
+	More    bool                  `json:"more"`
+	Next    string                `json:"next,omitempty"`
+	Objects []taxiiManifestRecord `json:"objects,omitempty"`
+}
+
+type taxiiManifestRecord struct {
+	ID        string `json:"id"`
+	DateAdded string `json:"date_added"`
+	Version   string `json:"version"`
+	MediaType string `json:"media_type"`
+}
+
+// taxiiRecord is one object in a collection. An analysis' objects are added
+// to the collection when it is (re-)analyzed.
+type taxiiRecord struct {
+	id        string
+	raw       json.RawMessage
+	dateAdded time.Time
+	version   string
+}
+
+type taxiiPageResult struct {
+	records []taxiiRecord
+	more    bool
+	next    string
+}
+
+func (p *taxiiPageResult) setHeaders(c *gin.Context) {
+	if len(p.records) == 0 {
+		return
+	}
+	first, last := p.records[0].dateAdded, p.records[0].dateAdded
+	for _, r := range p.records[1:] {
+		if r.dateAdded.Before(first) {
+			first = r.dateAdded
+		}
+		if r.dateAdded.After(last) {
+			last = r.dateAdded
+		}
+	}
+	c.Header("X-TAXII-Date-Added-First", taxiiTime(first))
+	c.Header("X-TAXII-Date-Added-Last", taxiiTime(last))
+}
+
+func (h *Handler) taxiiCollection(c *gin.Context) (*models.TAXIICollection, bool) {
+	coll, err := h.db.GetTAXIICollection(c.Request.Context(), auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			taxiiError(c, http.StatusNotFound, "Collection Not Found", "no collection with ID "+c.Param("id"))
+			return nil, false
+		}
+		taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+		return nil, false
+	}
+	return coll, true
+}
+
+// taxiiMatch holds the match[...] query filters.
+type taxiiMatch struct {
+	types    []string
+	ids      []string
+	versions []string
+}
+
+func (m *taxiiMatch) matches(r *taxiiRecord) bool {
+	if len(m.types) > 0 {
+		objectType, _, _ := strings.Cut(r.id, "--")
+		if !slices.Contains(m.types, objectType) {
+			return false
+		}
+	}
+	if len(m.ids) > 0 && !slices.Contains(m.ids, r.id) {
+		return false
+	}
+	// With one version per object, first, last and all all match it.
+	for _, v := range m.versions {
+		if v == "first" || v == "last" || v == "all" || v == r.version {
+			return true
+		}
+	}
+	return len(m.versions) == 0
+}
+
+// taxiiPage reads the added_after, limit, next and match[...] parameters and
+// loads the page. Pages hold whole analyses, so a page can be cut short to
+// keep an analysis' objects together.
+func (h *Handler) taxiiPage(c *gin.Context, coll *models.TAXIICollection) (*taxiiPageResult, bool) {
+	limit := defaultTAXIILimit
+	if s := c.Query("limit"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil || parsed <= 0 {
+			taxiiError(c, http.StatusBadRequest, "Invalid Limit", "limit must be a positive integer")
+			return nil, false
+		}
+		limit = min(parsed, maxTAXIILimit)
+	}
+
+	var after time.Time
+	var afterID primitive.ObjectID
+	if s := c.Query("added_after"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			taxiiError(c, http.StatusBadRequest, "Invalid added_after", "added_after must be an RFC 3339 timestamp")
+			return nil, false
+		}
+		after = t
+	}
+	if s := c.Query("next"); s != "" {
+		t, id, err := decodeTAXIICursor(s)
+		if err != nil {
+			taxiiError(c, http.StatusBadRequest, "Invalid next", err.Error())
+			return nil, false
+		}
+		after, afterID = t, id
+	}
+
+	match := taxiiMatch{
+		types:    queryList(c, "match[type]"),
+		ids:      queryList(c, "match[id]"),
+		versions: queryList(c, "match[version]"),
+	}
+	if specs := queryList(c, "match[spec_version]"); len(specs) > 0 && !slices.Contains(specs, stix.SpecVersion) {
+		return &taxiiPageResult{records: []taxiiRecord{}}, true
+	}
+
+	filter := coll.Filter.ThreatFilter(time.Now())
+	filter.Limit = int64(limit)
+	threats, err := h.db.ThreatsAfter(c.Request.Context(), coll.TenantID, filter, after, afterID)
+	if err != nil {
+		taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+		return nil, false
+	}
+
+	page := &taxiiPageResult{records: []taxiiRecord{}}
+	// The identity the objects reference opens the collection.
+	if afterID.IsZero() && after.Before(stix.ATIAIdentity().Created.Time()) {
+		identity, err := newTAXIIRecord(stix.ATIAIdentity(), stix.ATIAIdentity().Created.Time())
+		if err != nil {
+			taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+			return nil, false
+		}
+		if match.matches(identity) {
+			page.records = append(page.records, *identity)
+		}
+	}
+
+	// The identity doesn't count against the limit.
+	base := len(page.records)
+	var last *models.ThreatIndicator
+	for i := range threats {
+		t := &threats[i]
+		records, err := threatRecords(t)
+		if err != nil {
+			if errors.Is(err, stix.ErrUnsupported) {
+				last = t
+				continue
+			}
+			taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+			return nil, false
+		}
+		matched := records[:0]
+		for j := range records {
+			if match.matches(&records[j]) {
+				matched = append(matched, records[j])
+			}
+		}
+		if len(page.records) > base && len(page.records)-base+len(matched) > limit {
+			page.more = true
+			break
+		}
+		page.records = append(page.records, matched...)
+		last = t
+	}
+	if len(threats) == limit {
+		page.more = true
+	}
+	if page.more {
+		page.next = encodeTAXIICursor(last.LastUpdated, last.ID)
+	}
+	return page, true
+}
+
+// findTAXIIObject looks an object up by ID in the collection, through the
+// object IDs stored with each analysis.
+func (h *Handler) findTAXIIObject(c *gin.Context, coll *models.TAXIICollection, objectID string) (*taxiiRecord, bool) {
+	if objectID == stix.IdentityID {
+		record, err := newTAXIIRecord(stix.ATIAIdentity(), stix.ATIAIdentity().Created.Time())
+		if err != nil {
+			taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+			return nil, false
+		}
+		return record, true
+	}
+
+	t, err := h.db.FindThreatBySTIXID(c.Request.Context(), coll.TenantID, coll.Filter.ThreatFilter(time.Now()), objectID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		taxiiError(c, http.StatusInternalServerError, "Internal Error", err.Error())
+		return nil, false
+	}
+	if err == nil {
+		// The analyst note's ID is stored whether or not there are notes.
+		records, _ := threatRecords(t)
+		for j := range records {
+			if records[j].id == objectID {
+				return &records[j], true
+			}
+		}
+	}
+	taxiiError(c, http.StatusNotFound, "Object Not Found", "no object with ID "+objectID+" in this collection")
+	return nil, false
+}
+
+func threatRecords(t *models.ThreatIndicator) ([]taxiiRecord, error) {
+	objects, err := stix.Objects(t)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]taxiiRecord, 0, len(objects))
+	for _, o := range objects {
+		record, err := newTAXIIRecord(o, t.LastUpdated)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+	return records, nil
+}
+
+func newTAXIIRecord(object interface{}, dateAdded time.Time) (*taxiiRecord, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		ID       string `json:"id"`
+		Modified string `json:"modified"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, err
+	}
+	// Observables have no modified time; their version is when they were
+	// added.
+	version := header.Modified
+	if version == "" {
+		version = taxiiTime(dateAdded)
+	}
+	return &taxiiRecord{id: header.ID, raw: raw, dateAdded: dateAdded, version: version}, nil
+}
+
+// The next cursor is the update time and ID of the last analysis served.
+func encodeTAXIICursor(t time.Time, id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.UnixNano(), 10) + "." + id.Hex()))
+}
+
+func decodeTAXIICursor(s string) (time.Time, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, primitive.ObjectID{}, errors.New("malformed next cursor")
+	}
+	nanos, hexID, ok := strings.Cut(string(raw), ".")
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil {
+		return time.Time{}, primitive.ObjectID{}, errors.New("malformed next cursor")
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return time.Time{}, primitive.ObjectID{}, errors.New("malformed next cursor")
+	}
+	return time.Unix(0, n), id, nil
+}
+
+// requestOrigin is the scheme and host the client used, honouring a TLS
+// terminating proxy.
+func requestOrigin(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (h *Handler) ListTAXIICollections(c *gin.Context) {
+	colls, err := h.db.ListTAXIICollections(c.Request.Context(), auth.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, colls)
+}
+
+func (h *Handler) CreateTAXIICollection(c *gin.Context) {
+	var req models.TAXIICollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coll := &models.TAXIICollection{TenantID: auth.TenantID(c)}
+	applyTAXIICollectionRequest(coll, &req)
+	if err := h.db.CreateTAXIICollection(c.Request.Context(), coll); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, coll)
+}
+
+func (h *Handler) UpdateTAXIICollection(c *gin.Context) {
+	var req models.TAXIICollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	coll, err := h.db.GetTAXIICollection(ctx, auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	applyTAXIICollectionRequest(coll, &req)
+
+	if err := h.db.UpdateTAXIICollection(ctx, coll); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coll)
+}
+
+func (h *Handler) DeleteTAXIICollection(c *gin.Context) {
+	if err := h.db.DeleteTAXIICollection(c.Request.Context(), auth.TenantID(c), c.Param("id")); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted successfully"})
+}
+
+func applyTAXIICollectionRequest(coll *models.TAXIICollection, req *models.TAXIICollectionRequest) {
+	coll.Title = req.Title
+	coll.Description = req.Description
+	coll.Filter = req.Filter
+	coll.Filter.Types = nonNil(coll.Filter.Types)
+	coll.Filter.Reputations = nonNil(coll.Filter.Reputations)
+	coll.Filter.Tags = nonNil(coll.Filter.Tags)
+}
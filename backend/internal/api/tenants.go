@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/egress"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+func tenantResponse(t *models.Tenant) models.TenantResponse {
+	return models.TenantResponse{Tenant: t, ProviderKeys: t.ConfiguredProviders()}
+}
+
+// checkTenantWebhook rejects a webhook URL that reaches a non-public
+// address, answering 400 itself.
+func checkTenantWebhook(c *gin.Context, req *models.TenantRequest) bool {
+	if req.WebhookURL == "" {
+		return true
+	}
+	if err := egress.CheckURL(c.Request.Context(), req.WebhookURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) CreateTenant(c *gin.Context) {
+	var req models.TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !tenantIDPattern.MatchString(req.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be lowercase letters, digits, '-' or '_'"})
+		return
+	}
+	if !checkTenantWebhook(c, &req) {
+		return
+	}
+
+	tenant := &models.Tenant{
+		ID:            req.ID,
+		Name:          req.Name,
+		WebhookURL:    req.WebhookURL,
+		ShareVerdicts: req.ShareVerdicts,
+	}
+	if req.ProviderKeys != nil {
+		tenant.ProviderKeys = *req.ProviderKeys
+	}
+
+	if err := h.db.CreateTenant(c.Request.Context(), tenant); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "tenant already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tenantResponse(tenant))
+}
+
+func (h *Handler) ListTenants(c *gin.Context) {
+	tenants, err := h.db.ListTenants(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]models.TenantResponse, 0, len(tenants))
+	for i := range tenants {
+		resp = append(resp, tenantResponse(&tenants[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UpdateTenant(c *gin.Context) {
+	var req models.TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !checkTenantWebhook(c, &req) {
+		return
+	}
+
+	tenant := &models.Tenant{
+		ID:            c.Param("id"),
+		Name:          req.Name,
+		WebhookURL:    req.WebhookURL,
+		ShareVerdicts: req.ShareVerdicts,
+	}
+	if err := h.db.UpdateTenant(c.Request.Context(), tenant, req.ProviderKeys); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.db.GetTenant(c.Request.Context(), tenant.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tenantResponse(updated))
+}
+
+// GetSharedVerdict returns the cross-tenant verdict for an indicator. Only
+// tenants that share their own verdicts may read the pool.
+func (h *Handler) GetSharedVerdict(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tenant, err := h.db.GetTenant(ctx, auth.TenantID(c))
+	if err != nil || !tenant.ShareVerdicts {
+		c.JSON(http.StatusForbidden, gin.H{"error": "verdict sharing is not enabled for this tenant"})
+		return
+	}
+
+	verdict, err := h.db.GetSharedVerdict(ctx, c.Param("indicator"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No shared verdict"})
+		return
+	}
+
+	c.JSON(http.StatusOK, verdict)
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/egress"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	subs, err := h.db.ListWebhooks(c.Request.Context(), auth.TenantID(c), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]models.WebhookSubscription, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, sub.Redacted())
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetWebhook(c *gin.Context) {
+	sub, err := h.db.GetWebhook(c.Request.Context(), auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub.Redacted())
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sub := &models.WebhookSubscription{TenantID: auth.TenantID(c), Secret: secret}
+	applyWebhookRequest(sub, &req)
+	if err := checkWebhook(c, sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.CreateWebhook(c.Request.Context(), sub); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The secret is shown once so the receiver can verify signatures.
+	resp := sub.Redacted()
+	resp.Secret = sub.Secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RotateWebhookSecret replaces the signing secret and returns the new one.
+func (h *Handler) RotateWebhookSecret(c *gin.Context) {
+	ctx := c.Request.Context()
+	sub, err := h.db.GetWebhook(ctx, auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if sub.Secret, err = newWebhookSecret(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.UpdateWebhook(ctx, sub); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := sub.Redacted()
+	resp.Secret = sub.Secret
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	sub, err := h.db.GetWebhook(ctx, auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	applyWebhookRequest(sub, &req)
+	if err := checkWebhook(c, sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.UpdateWebhook(ctx, sub); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub.Redacted())
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	if err := h.db.DeleteWebhook(c.Request.Context(), auth.TenantID(c), c.Param("id")); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListWebhookDeliveries returns delivery records with their attempt logs,
+// for one subscription (/webhooks/:id/deliveries) or for the whole tenant,
+// optionally filtered by ?status=.
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	deliveries, err := h.db.ListWebhookDeliveries(c.Request.Context(), auth.TenantID(c), c.Param("id"), c.Query("status"), limit)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ReplayWebhookDelivery queues a delivery again, e.g. a dead letter after
+// the receiver was fixed.
+func (h *Handler) ReplayWebhookDelivery(c *gin.Context) {
+	delivery, err := h.db.ReplayWebhookDelivery(c.Request.Context(), auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found or currently being sent"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// checkWebhook rejects subscriptions whose URL reaches a non-public
+// address, which would let the delivery log probe the internal network.
+func checkWebhook(c *gin.Context, sub *models.WebhookSubscription) error {
+	if err := egress.CheckURL(c.Request.Context(), sub.URL); err != nil {
+		return fmt.Errorf("url %w", err)
+	}
+	return services.CheckWebhookFormat(sub)
+}
+
+func applyWebhookRequest(sub *models.WebhookSubscription, req *models.WebhookRequest) {
+	sub.Name = req.Name
+	sub.URL = req.URL
+	sub.EventTypes = nonNil(req.EventTypes)
+	sub.MinSeverity = req.MinSeverity
+	sub.IndicatorTypes = nonNil(req.IndicatorTypes)
+	sub.Tags = nonNil(req.Tags)
+	if req.Headers != nil {
+		sub.Headers = req.Headers
+	}
+	sub.Format = req.Format
+	sub.Template = req.Template
+	sub.ContentType = req.ContentType
+	if req.RoutingKey != "" {
+		sub.RoutingKey = req.RoutingKey
+	}
+	sub.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
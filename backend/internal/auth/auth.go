@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// APIKeyHeader carries a key directly; "Authorization: Bearer <key>" is
+	// accepted as well, and so is HTTP Basic with the key as the password
+	// for TAXII clients, which usually only support Basic.
+	APIKeyHeader = "X-API-Key"
+	// APIKeyQueryParam carries the key on EventSource and WebSocket requests,
+	// where browsers can't set headers. Other requests must use a header.
+	APIKeyQueryParam = "api_key"
+
+	keyPrefix       = "atia_"
+	principalCtxKey = "auth.principal"
+)
+
+var authLog = logging.For("auth")
+
+// Principal is the authenticated caller attached to each request.
+type Principal struct {
+	KeyID    string
+	TenantID string
+	Name     string
+	Scopes   []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	key := models.APIKey{Scopes: p.Scopes}
+	return key.HasScope(scope)
+}
+
+// IsOperator reports whether the principal administers the whole deployment:
+// an admin of the default tenant.
+func (p *Principal) IsOperator() bool {
+	return p.TenantID == models.DefaultTenant && p.HasScope(models.ScopeAdmin)
+}
+
+type Authenticator struct {
+	db           *database.MongoDB
+	enabled      bool
+	bootstrapKey []byte
+}
+
+// NewAuthenticator validates keys against the api_keys collection. When
+// bootstrapKey is set it is accepted as an admin key, so the first real keys
+// can be created. With enabled false every request is treated as admin.
+func NewAuthenticator(db *database.MongoDB, enabled bool, bootstrapKey string) *Authenticator {
+	a := &Authenticator{db: db, enabled: enabled}
+	if bootstrapKey != "" {
+		a.bootstrapKey = []byte(bootstrapKey)
+	}
+	return a
+}
+
+// GenerateKey returns a new random plaintext key.
+func GenerateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+// HashKey returns the stored form of a plaintext key.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Middleware authenticates the request and stores the Principal in the gin
+// context. Requests without a valid key are rejected with 401.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.enabled {
+			c.Set(principalCtxKey, &Principal{TenantID: models.DefaultTenant, Name: "anonymous", Scopes: []string{models.ScopeAdmin}})
+			c.Next()
+			return
+		}
+
+		key := extractKey(c.Request)
+		if key == "" {
+			abortUnauthorized(c, "missing API key")
+			return
+		}
+
+		if a.bootstrapKey != nil && subtle.ConstantTimeCompare([]byte(key), a.bootstrapKey) == 1 {
+			c.Set(principalCtxKey, &Principal{TenantID: models.DefaultTenant, Name: "bootstrap", Scopes: []string{models.ScopeAdmin}})
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		apiKey, err := a.db.UseAPIKey(ctx, HashKey(key))
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				authLog.ErrorContext(ctx, "API key lookup failed", "error", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "authentication unavailable"})
+				return
+			}
+			abortUnauthorized(c, "invalid API key")
+			return
+		}
+
+		c.Set(principalCtxKey, &Principal{
+			KeyID:    apiKey.ID.Hex(),
+			TenantID: apiKey.TenantID,
+			Name:     apiKey.Name,
+			Scopes:   apiKey.Scopes,
+		})
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose principal lacks scope with 403.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := PrincipalFrom(c)
+		if p == nil || !p.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks required scope: " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireOperator rejects requests not made by a default-tenant admin.
+func RequireOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := PrincipalFrom(c)
+		if p == nil || !p.IsOperator() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "operator access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TenantID returns the authenticated principal's tenant. It must only be
+// called behind Middleware.
+func TenantID(c *gin.Context) string {
+	if p := PrincipalFrom(c); p != nil {
+		return p.TenantID
+	}
+	return ""
+}
+
+// PrincipalFrom returns the authenticated principal, or nil.
+func PrincipalFrom(c *gin.Context) *Principal {
+	v, ok := c.Get(principalCtxKey)
+	if !ok {
+		return nil
+	}
+	p, _ := v.(*Principal)
+	return p
+}
+
+func extractKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if user, password, ok := r.BasicAuth(); ok {
+		if password != "" {
+			return password
+		}
+		return user
+	}
+	if isStreamRequest(r) {
+		return r.URL.Query().Get(APIKeyQueryParam)
+	}
+	return ""
+}
+
+func isStreamRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func abortUnauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", `Bearer realm="atia"`)
+	c.Writer.Header().Add("WWW-Authenticate", `Basic realm="atia"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+}
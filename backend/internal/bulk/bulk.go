@@ -0,0 +1,83 @@
+// Package bulk writes analyses as CSV or JSON Lines and reads them back, for
+// backups, migrations and seeding.
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Formats.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Fields, in their default order. In CSV, tags are separated by
+// TagSeparator and sources and metadata are JSON.
+const (
+	FieldIndicator   = "indicator"
+	FieldType        = "type"
+	FieldReputation  = "reputation"
+	FieldRiskScore   = "risk_score"
+	FieldTags        = "tags"
+	FieldNotes       = "notes"
+	FieldFirstSeen   = "first_seen"
+	FieldLastUpdated = "last_updated"
+	FieldSources     = "sources"
+	FieldMetadata    = "metadata"
+)
+
+var Fields = []string{
+	FieldIndicator, FieldType, FieldReputation, FieldRiskScore, FieldTags,
+	FieldNotes, FieldFirstSeen, FieldLastUpdated, FieldSources, FieldMetadata,
+}
+
+const TagSeparator = ";"
+
+var ErrFormat = errors.New("bulk: unknown format")
+
+// Format maps a format name or media type onto a format.
+func Format(s string) (string, error) {
+	s, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(s)), ";")
+	switch strings.TrimSpace(s) {
+	case FormatCSV, "text/csv", "application/csv":
+		return FormatCSV, nil
+	case FormatJSONL, "ndjson", "application/x-ndjson", "application/jsonl", "application/x-jsonlines", "application/json":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("%w %q, want csv or jsonl", ErrFormat, s)
+}
+
+// ParseFields checks a field selection, defaulting to all fields.
+func ParseFields(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return Fields, nil
+	}
+	for _, f := range fields {
+		if !slices.Contains(Fields, f) {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return fields, nil
+}
+
+// ParseMapping reads source:field pairs renaming input columns or keys to
+// fields.
+func ParseMapping(pairs []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, ":")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid mapping %q, want column:field", pair)
+		}
+		if !slices.Contains(Fields, to) {
+			return nil, fmt.Errorf("invalid mapping %q: unknown field %q", pair, to)
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
@@ -0,0 +1,268 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// maxLineSize caps one JSON Lines record.
+const maxLineSize = 1 << 20
+
+var reputations = map[string]bool{"malicious": true, "suspicious": true, "unknown": true, "clean": true}
+
+// Record is one row read from an import.
+type Record struct {
+	Line   int
+	Threat models.ThreatIndicator
+	// Analyzed is set when the row carries a reputation or sources, i.e. it
+	// is an exported analysis rather than a bare indicator.
+	Analyzed bool
+}
+
+// RowError is a row that could not be read. Reading continues after it.
+type RowError struct {
+	Line      int
+	Indicator string
+	Err       error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads records from CSV with a header row, or from JSON Lines.
+// Columns and keys are renamed by the mapping; others that aren't fields
+// are ignored.
+type Reader struct {
+	mapping map[string]string
+	csv     *csv.Reader
+	header  []string
+	lines   *bufio.Scanner
+	line    int
+}
+
+func NewReader(r io.Reader, format string, mapping map[string]string) (*Reader, error) {
+	br := &Reader{mapping: mapping}
+	switch format {
+	case FormatCSV:
+		br.csv = csv.NewReader(r)
+		br.csv.FieldsPerRecord = -1
+		br.csv.TrimLeadingSpace = true
+		header, err := br.csv.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("empty CSV, want a header row")
+			}
+			return nil, err
+		}
+		for i, col := range header {
+			col = strings.TrimSpace(strings.TrimPrefix(col, "\ufeff"))
+			header[i] = br.field(col)
+		}
+		br.header = header
+	case FormatJSONL:
+		br.lines = bufio.NewScanner(r)
+		br.lines.Buffer(make([]byte, 64*1024), maxLineSize)
+	default:
+		return nil, ErrFormat
+	}
+	return br, nil
+}
+
+func (r *Reader) field(name string) string {
+	if f, ok := r.mapping[name]; ok {
+		return f
+	}
+	return name
+}
+
+// Next returns the next record, a *RowError for a bad row, or io.EOF.
+// Other errors end the import.
+func (r *Reader) Next() (*Record, error) {
+	if r.csv != nil {
+		return r.nextCSV()
+	}
+	return r.nextJSONL()
+}
+
+func (r *Reader) nextCSV() (*Record, error) {
+	for {
+		row, err := r.csv.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &RowError{Line: parseErr.Line, Err: parseErr.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.csv.FieldPos(0)
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+
+		values := map[string]string{}
+		for i, v := range row {
+			if i < len(r.header) {
+				values[r.header[i]] = strings.TrimSpace(v)
+			}
+		}
+		rec := &Record{Line: line}
+		if err := rec.fromStrings(values); err != nil {
+			return nil, &RowError{Line: line, Indicator: values[FieldIndicator], Err: err}
+		}
+		return rec, nil
+	}
+}
+
+func (r *Reader) nextJSONL() (*Record, error) {
+	for r.lines.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, &RowError{Line: r.line, Err: err}
+		}
+		obj := make(map[string]json.RawMessage, len(raw))
+		for k, v := range raw {
+			obj[r.field(k)] = v
+		}
+		rec := &Record{Line: r.line}
+		if err := rec.fromJSON(obj); err != nil {
+			var indicator string
+			_ = json.Unmarshal(obj[FieldIndicator], &indicator)
+			return nil, &RowError{Line: r.line, Indicator: indicator, Err: err}
+		}
+		return rec, nil
+	}
+	if err := r.lines.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (rec *Record) fromStrings(values map[string]string) error {
+	t := &rec.Threat
+	t.Indicator = values[FieldIndicator]
+	t.Type = strings.ToLower(values[FieldType])
+	t.Reputation = strings.ToLower(values[FieldReputation])
+	t.Notes = values[FieldNotes]
+	if s := values[FieldRiskScore]; s != "" {
+		score, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid risk_score %q", s)
+		}
+		t.RiskScore = score
+	}
+	for _, tag := range strings.Split(values[FieldTags], TagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			t.Tags = append(t.Tags, tag)
+		}
+	}
+	var err error
+	if t.FirstSeen, err = parseTime(FieldFirstSeen, values[FieldFirstSeen]); err != nil {
+		return err
+	}
+	if t.LastUpdated, err = parseTime(FieldLastUpdated, values[FieldLastUpdated]); err != nil {
+		return err
+	}
+	if s := values[FieldSources]; s != "" {
+		if err := json.Unmarshal([]byte(s), &t.Sources); err != nil {
+			return fmt.Errorf("invalid sources: %w", err)
+		}
+	}
+	if s := values[FieldMetadata]; s != "" {
+		if err := json.Unmarshal([]byte(s), &t.Metadata); err != nil {
+			return fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	return rec.validate()
+}
+
+func (rec *Record) fromJSON(obj map[string]json.RawMessage) error {
+	var row struct {
+		Indicator   string                 `json:"indicator"`
+		Type        string                 `json:"type"`
+		Reputation  string                 `json:"reputation"`
+		RiskScore   float64                `json:"risk_score"`
+		Tags        []string               `json:"tags"`
+		Notes       string                 `json:"notes"`
+		FirstSeen   time.Time              `json:"first_seen"`
+		LastUpdated time.Time              `json:"last_updated"`
+		Sources     []models.SourceData    `json:"sources"`
+		Metadata    map[string]interface{} `json:"metadata"`
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &row); err != nil {
+		return err
+	}
+	rec.Threat = models.ThreatIndicator{
+		Indicator:   strings.TrimSpace(row.Indicator),
+		Type:        strings.ToLower(row.Type),
+		Reputation:  strings.ToLower(row.Reputation),
+		RiskScore:   row.RiskScore,
+		Tags:        row.Tags,
+		Notes:       row.Notes,
+		FirstSeen:   row.FirstSeen,
+		LastUpdated: row.LastUpdated,
+		Sources:     row.Sources,
+		Metadata:    row.Metadata,
+	}
+	return rec.validate()
+}
+
+// validate checks the indicator against its type, detecting the type when
+// none is given.
+func (rec *Record) validate() error {
+	t := &rec.Threat
+	if t.Indicator == "" {
+		return errors.New("missing indicator")
+	}
+	if t.Type == "" {
+		if t.Type = ioc.Detect(t.Indicator); t.Type == "" {
+			return fmt.Errorf("cannot tell the type of %q", t.Indicator)
+		}
+	} else if !ioc.Valid(t.Type, t.Indicator) {
+		return fmt.Errorf("%q is not a valid %s", t.Indicator, t.Type)
+	}
+	if t.Reputation != "" && !reputations[t.Reputation] {
+		return fmt.Errorf("invalid reputation %q", t.Reputation)
+	}
+	if t.RiskScore < 0 || t.RiskScore > 100 {
+		return fmt.Errorf("risk_score %v out of range 0-100", t.RiskScore)
+	}
+	rec.Analyzed = t.Reputation != "" || len(t.Sources) > 0
+	return nil
+}
+
+func parseTime(field, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, want RFC 3339", field, s)
+	}
+	return t, nil
+}
@@ -0,0 +1,132 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// Writer writes analyses with the selected fields.
+type Writer struct {
+	format string
+	fields []string
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+// NewWriter starts an export; a CSV export begins with a header row.
+func NewWriter(w io.Writer, format string, fields []string) (*Writer, error) {
+	bw := &Writer{format: format, fields: fields, buf: bufio.NewWriter(w)}
+	switch format {
+	case FormatCSV:
+		bw.csv = csv.NewWriter(bw.buf)
+		if err := bw.csv.Write(fields); err != nil {
+			return nil, err
+		}
+	case FormatJSONL:
+		bw.json = json.NewEncoder(bw.buf)
+		bw.json.SetEscapeHTML(false)
+	default:
+		return nil, ErrFormat
+	}
+	return bw, nil
+}
+
+func (w *Writer) Write(t *models.ThreatIndicator) error {
+	if w.csv != nil {
+		row := make([]string, len(w.fields))
+		for i, f := range w.fields {
+			v, err := csvValue(t, f)
+			if err != nil {
+				return err
+			}
+			row[i] = v
+		}
+		return w.csv.Write(row)
+	}
+
+	obj := make(map[string]interface{}, len(w.fields))
+	for _, f := range w.fields {
+		obj[f] = jsonValue(t, f)
+	}
+	return w.json.Encode(obj)
+}
+
+// Flush writes out buffered rows.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+func jsonValue(t *models.ThreatIndicator, field string) interface{} {
+	switch field {
+	case FieldIndicator:
+		return t.Indicator
+	case FieldType:
+		return t.Type
+	case FieldReputation:
+		return t.Reputation
+	case FieldRiskScore:
+		return t.RiskScore
+	case FieldTags:
+		return nonNil(t.Tags)
+	case FieldNotes:
+		return t.Notes
+	case FieldFirstSeen:
+		return t.FirstSeen.UTC()
+	case FieldLastUpdated:
+		return t.LastUpdated.UTC()
+	case FieldSources:
+		return nonNil(t.Sources)
+	case FieldMetadata:
+		if t.Metadata == nil {
+			return map[string]interface{}{}
+		}
+		return t.Metadata
+	}
+	return nil
+}
+
+func csvValue(t *models.ThreatIndicator, field string) (string, error) {
+	switch field {
+	case FieldRiskScore:
+		return strconv.FormatFloat(t.RiskScore, 'f', -1, 64), nil
+	case FieldTags:
+		return strings.Join(t.Tags, TagSeparator), nil
+	case FieldFirstSeen:
+		return csvTime(t.FirstSeen), nil
+	case FieldLastUpdated:
+		return csvTime(t.LastUpdated), nil
+	case FieldSources, FieldMetadata:
+		b, err := json.Marshal(jsonValue(t, field))
+		return string(b), err
+	}
+	s, _ := jsonValue(t, field).(string)
+	return s, nil
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
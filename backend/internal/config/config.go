@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +15,23 @@ type Config struct {
 	MongoDatabase string
 	APIKeys       APIKeys
 	Server        ServerConfig
+	// N8NWebhookURL receives every event for tenants without their own
+	// webhook URL, in addition to stored subscriptions.
+	N8NWebhookURL string
+	Webhook       WebhookConfig
+	Queue         QueueConfig
+	Syslog        SyslogConfig
+	Logging       LoggingConfig
+	Tracing       TracingConfig
+	Auth          AuthConfig
+	RateLimit     RateLimitConfig
+	RPZ           RPZConfig
+	// ProviderCacheTTL is how long raw provider responses are reused. Zero
+	// disables the cache.
+	ProviderCacheTTL time.Duration
+	// GraphEnrichBudget is how many unanalyzed graph neighbours each
+	// analysis queues for analysis. Zero disables enrichment.
+	GraphEnrichBudget int
 }
 
 type APIKeys struct {
@@ -22,6 +43,90 @@ type APIKeys struct {
 type ServerConfig struct {
 	Port string
 	Host string
+	// CORSAllowedOrigins lists browser origins allowed to call the API; "*"
+	// allows any origin.
+	CORSAllowedOrigins []string
+	// TrustedProxies may set X-Forwarded-For; with none, the client IP used
+	// for rate limiting is always the connection's remote address.
+	TrustedProxies []string
+	// EgressAllow lists private prefixes that URLs supplied through the API
+	// (webhooks, MISP feeds) may still reach; other private addresses are
+	// refused.
+	EgressAllow []string
+}
+
+type AuthConfig struct {
+	Enabled bool
+	// BootstrapAdminKey is accepted as an admin key so the first stored keys
+	// can be created. Leave it unset once real keys exist.
+	BootstrapAdminKey string
+}
+
+// WebhookConfig tunes the webhook delivery queue.
+type WebhookConfig struct {
+	Workers     int
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+	// SigningSecret signs deliveries to N8N_WEBHOOK_URL, tenant webhook URLs
+	// and subscriptions without their own secret.
+	SigningSecret string
+}
+
+// QueueConfig tunes the background analysis queue used by imports.
+type QueueConfig struct {
+	Workers int
+	Size    int
+}
+
+// SyslogConfig describes the SIEM syslog output; an empty Address disables it.
+type SyslogConfig struct {
+	Address  string
+	Network  string // udp, tcp or tls
+	Format   string // cef or leef
+	Facility int
+	AppName  string
+	CAFile   string
+}
+
+type LoggingConfig struct {
+	Level string
+	// Components overrides Level per component, e.g. "providers=debug,http=warn".
+	Components string
+}
+
+// RateLimitConfig holds budgets as "N/window" strings (e.g. "30/1m"); "0"
+// disables a budget.
+type RateLimitConfig struct {
+	// Backend is "memory" (per replica) or "mongo" (shared across replicas).
+	Backend       string
+	AnalyzePerKey string
+	AnalyzePerIP  string
+	ReadPerKey    string
+	ReadPerIP     string
+}
+
+// RPZConfig describes the DNS response policy zones; an empty Listen disables
+// the zone transfer listener but the zones can still be downloaded.
+type RPZConfig struct {
+	Zone             string
+	Action           string // nxdomain, nodata or sinkhole
+	SuspiciousAction string // as Action, or none
+	Sinkhole         string
+	TTL              uint32
+	Wildcard         bool
+	Listen           string
+	AllowTransfer    []string
+	TSIGKey          string   // name:base64secret
+	TenantTSIGKeys   []string // tenant=name:base64secret
+	Notify           []string
+}
+
+type TracingConfig struct {
+	// OTLPEndpoint is the OTLP/HTTP collector URL; empty disables export.
+	OTLPEndpoint string
+	ServiceName  string
+	SampleRatio  float64
 }
 
 func Load() (*Config, error) {
@@ -35,12 +140,110 @@ func Load() (*Config, error) {
 			OTX:        os.Getenv("OTX_API_KEY"),
 			AbuseIPDB:  os.Getenv("ABUSEIPDB_API_KEY"),
 		},
+		N8NWebhookURL: os.Getenv("N8N_WEBHOOK_URL"),
+		Webhook: WebhookConfig{
+			SigningSecret: os.Getenv("WEBHOOK_SIGNING_SECRET"),
+		},
 		Server: ServerConfig{
-			Port: getEnvOrDefault("SERVER_PORT", "8080"),
-			Host: getEnvOrDefault("SERVER_HOST", "0.0.0.0"),
+			Port:               getEnvOrDefault("SERVER_PORT", "8080"),
+			Host:               getEnvOrDefault("SERVER_HOST", "0.0.0.0"),
+			CORSAllowedOrigins: splitList(getEnvOrDefault("CORS_ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:3001")),
+			TrustedProxies:     splitList(os.Getenv("TRUSTED_PROXIES")),
+			EgressAllow:        splitList(os.Getenv("EGRESS_ALLOW")),
+		},
+		Syslog: SyslogConfig{
+			Address: os.Getenv("SYSLOG_ADDRESS"),
+			Network: getEnvOrDefault("SYSLOG_NETWORK", "udp"),
+			Format:  getEnvOrDefault("SYSLOG_FORMAT", "cef"),
+			AppName: getEnvOrDefault("SYSLOG_APP_NAME", "atia"),
+			CAFile:  os.Getenv("SYSLOG_TLS_CA_FILE"),
+		},
+		Auth: AuthConfig{
+			BootstrapAdminKey: os.Getenv("ATIA_BOOTSTRAP_ADMIN_KEY"),
+		},
+		Logging: LoggingConfig{
+			Level:      getEnvOrDefault("LOG_LEVEL", "info"),
+			Components: os.Getenv("LOG_COMPONENT_LEVELS"),
+		},
+		RateLimit: RateLimitConfig{
+			Backend:       getEnvOrDefault("RATE_LIMIT_BACKEND", "memory"),
+			AnalyzePerKey: getEnvOrDefault("RATE_LIMIT_ANALYZE_PER_KEY", "30/1m"),
+			AnalyzePerIP:  getEnvOrDefault("RATE_LIMIT_ANALYZE_PER_IP", "60/1m"),
+			ReadPerKey:    getEnvOrDefault("RATE_LIMIT_READ_PER_KEY", "600/1m"),
+			ReadPerIP:     getEnvOrDefault("RATE_LIMIT_READ_PER_IP", "1200/1m"),
+		},
+		RPZ: RPZConfig{
+			Zone:           getEnvOrDefault("RPZ_ZONE", "rpz.atia.local"),
+			Action:         getEnvOrDefault("RPZ_ACTION", "nxdomain"),
+			Sinkhole:       os.Getenv("RPZ_SINKHOLE"),
+			Listen:         os.Getenv("RPZ_LISTEN"),
+			AllowTransfer:  splitList(getEnvOrDefault("RPZ_ALLOW_TRANSFER", "127.0.0.0/8,::1/128")),
+			TSIGKey:        os.Getenv("RPZ_TSIG_KEY"),
+			TenantTSIGKeys: splitList(os.Getenv("RPZ_TENANT_TSIG_KEYS")),
+			Notify:         splitList(os.Getenv("RPZ_NOTIFY")),
+		},
+		Tracing: TracingConfig{
+			OTLPEndpoint: os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			ServiceName:  getEnvOrDefault("OTEL_SERVICE_NAME", "atia-backend"),
 		},
 	}
 
+	authEnabled, err := strconv.ParseBool(getEnvOrDefault("AUTH_ENABLED", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_ENABLED: %w", err)
+	}
+	cfg.Auth.Enabled = authEnabled
+
+	sampleRatio, err := strconv.ParseFloat(getEnvOrDefault("OTEL_TRACES_SAMPLE_RATIO", "1"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTEL_TRACES_SAMPLE_RATIO: %w", err)
+	}
+	cfg.Tracing.SampleRatio = sampleRatio
+
+	cacheTTL, err := time.ParseDuration(getEnvOrDefault("PROVIDER_CACHE_TTL", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_CACHE_TTL: %w", err)
+	}
+	cfg.ProviderCacheTTL = cacheTTL
+
+	if cfg.GraphEnrichBudget, err = strconv.Atoi(getEnvOrDefault("GRAPH_ENRICH_BUDGET", "0")); err != nil {
+		return nil, fmt.Errorf("invalid GRAPH_ENRICH_BUDGET: %w", err)
+	}
+
+	if cfg.Syslog.Facility, err = strconv.Atoi(getEnvOrDefault("SYSLOG_FACILITY", "16")); err != nil {
+		return nil, fmt.Errorf("invalid SYSLOG_FACILITY: %w", err)
+	}
+
+	if cfg.Webhook.Workers, err = strconv.Atoi(getEnvOrDefault("WEBHOOK_WORKERS", "4")); err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_WORKERS: %w", err)
+	}
+	if cfg.Webhook.MaxAttempts, err = strconv.Atoi(getEnvOrDefault("WEBHOOK_MAX_ATTEMPTS", "8")); err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %w", err)
+	}
+	if cfg.Webhook.RetryBase, err = time.ParseDuration(getEnvOrDefault("WEBHOOK_RETRY_BASE", "30s")); err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE: %w", err)
+	}
+	if cfg.Webhook.RetryMax, err = time.ParseDuration(getEnvOrDefault("WEBHOOK_RETRY_MAX", "1h")); err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_MAX: %w", err)
+	}
+
+	if cfg.Queue.Workers, err = strconv.Atoi(getEnvOrDefault("ANALYSIS_QUEUE_WORKERS", "2")); err != nil {
+		return nil, fmt.Errorf("invalid ANALYSIS_QUEUE_WORKERS: %w", err)
+	}
+	if cfg.Queue.Size, err = strconv.Atoi(getEnvOrDefault("ANALYSIS_QUEUE_SIZE", "10000")); err != nil {
+		return nil, fmt.Errorf("invalid ANALYSIS_QUEUE_SIZE: %w", err)
+	}
+
+	cfg.RPZ.SuspiciousAction = getEnvOrDefault("RPZ_SUSPICIOUS_ACTION", cfg.RPZ.Action)
+	ttl, err := strconv.ParseUint(getEnvOrDefault("RPZ_TTL", "300"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RPZ_TTL: %w", err)
+	}
+	cfg.RPZ.TTL = uint32(ttl)
+	if cfg.RPZ.Wildcard, err = strconv.ParseBool(getEnvOrDefault("RPZ_WILDCARD", "true")); err != nil {
+		return nil, fmt.Errorf("invalid RPZ_WILDCARD: %w", err)
+	}
+
 	return cfg, nil
 }
 
@@ -50,3 +253,13 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoDB) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	if key.TenantID == "" {
+		return ErrNoTenant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	key.CreatedAt = time.Now()
+	res, err := m.apiKeys.InsertOne(ctx, key)
+	if err != nil {
+		return err
+	}
+	key.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// UseAPIKey looks up an active key by hash and, in the same round trip,
+// increments its usage counter. It returns mongo.ErrNoDocuments for unknown
+// or revoked keys.
+func (m *MongoDB) UseAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"hash": hash, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{
+		"$inc": bson.M{"usage_count": 1},
+		"$set": bson.M{"last_used_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var key models.APIKey
+	if err := m.apiKeys.FindOneAndUpdate(ctx, filter, update, opts).Decode(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns the tenant's keys, or every key when tenantID is "".
+func (m *MongoDB) ListAPIKeys(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if tenantID != "" {
+		filter["tenant_id"] = tenantID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := m.apiKeys.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key of the tenant, or any key when tenantID is "".
+func (m *MongoDB) RevokeAPIKey(ctx context.Context, tenantID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": objID, "revoked_at": bson.M{"$exists": false}}
+	if tenantID != "" {
+		filter["tenant_id"] = tenantID
+	}
+
+	res, err := m.apiKeys.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
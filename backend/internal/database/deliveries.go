@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// deliveryLogSize caps the attempt log kept on each delivery.
+const deliveryLogSize = 20
+
+func (m *MongoDB) EnqueueWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	docs := make([]interface{}, 0, len(deliveries))
+	for i := range deliveries {
+		if deliveries[i].TenantID == "" {
+			return ErrNoTenant
+		}
+		docs = append(docs, deliveries[i])
+	}
+	_, err := m.deliveries.InsertMany(ctx, docs)
+	return err
+}
+
+// ClaimWebhookDelivery leases the next due delivery to the caller until
+// now+lease. Deliveries whose lease expired (a worker died mid-delivery) are
+// due again. It returns mongo.ErrNoDocuments when nothing is due.
+func (m *MongoDB) ClaimWebhookDelivery(ctx context.Context, now time.Time, lease time.Duration) (*models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"status": models.DeliveryInProgress, "locked_until": bson.M{"$lte": now}},
+	}}
+	update := bson.M{"$set": bson.M{
+		"status":       models.DeliveryInProgress,
+		"locked_until": now.Add(lease),
+		"updated_at":   now,
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	if err := m.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// RecordWebhookAttempt stores the outcome of one attempt and moves the
+// delivery to status, due again at next when status is pending.
+func (m *MongoDB) RecordWebhookAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string, next time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"status":          status,
+		"next_attempt_at": next,
+		"last_error":      attempt.Error,
+		"updated_at":      attempt.At,
+	}
+	if status == models.DeliveryDelivered {
+		set["delivered_at"] = attempt.At
+	}
+	update := bson.M{
+		"$set":   set,
+		"$unset": bson.M{"locked_until": ""},
+		"$inc":   bson.M{"attempts": 1},
+		"$push":  bson.M{"log": bson.M{"$each": bson.A{attempt}, "$slice": -deliveryLogSize}},
+	}
+
+	_, err := m.deliveries.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// ListWebhookDeliveries returns the tenant's deliveries, newest first. An
+// empty subscriptionID or status matches all.
+func (m *MongoDB) ListWebhookDeliveries(ctx context.Context, tenantID, subscriptionID, status string, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"tenant_id": tenantID}
+	if subscriptionID != "" {
+		objID, err := primitive.ObjectIDFromHex(subscriptionID)
+		if err != nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		filter["subscription_id"] = objID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := m.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayWebhookDelivery queues a delivery again with a fresh attempt budget.
+// Deliveries currently being sent can't be replayed and report
+// mongo.ErrNoDocuments, as do unknown ones.
+func (m *MongoDB) ReplayWebhookDelivery(ctx context.Context, tenantID, id string) (*models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id":       objID,
+		"tenant_id": tenantID,
+		"status":    bson.M{"$ne": models.DeliveryInProgress},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"updated_at":      now,
+		},
+		"$unset": bson.M{"delivered_at": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	if err := m.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveGraphEdges records relationships. An edge seen before keeps its
+// first sighting and gains any new sources.
+func (m *MongoDB) SaveGraphEdges(ctx context.Context, edges []models.GraphEdge) error {
+	if len(edges) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	writes := make([]mongo.WriteModel, 0, len(edges))
+	for _, e := range edges {
+		if e.TenantID == "" {
+			return ErrNoTenant
+		}
+		filter := bson.M{"tenant_id": e.TenantID, "from": e.From, "relation": e.Relation, "to": e.To}
+		set := bson.M{"from_type": e.FromType, "to_type": e.ToType, "last_seen": e.LastSeen}
+		if e.ToLabel != "" {
+			set["to_label"] = e.ToLabel
+		}
+		update := bson.M{
+			"$set":         set,
+			"$setOnInsert": bson.M{"first_seen": e.FirstSeen},
+			"$addToSet":    bson.M{"sources": bson.M{"$each": e.Sources}},
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+	_, err := m.graphEdges.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// GraphEdgesOf returns up to limit of the tenant's edges that start or
+// end at any of the nodes.
+func (m *MongoDB) GraphEdgesOf(ctx context.Context, tenantID string, nodes []string, limit int64) ([]models.GraphEdge, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := bson.M{
+		"tenant_id": tenantID,
+		"$or": bson.A{
+			bson.M{"from": bson.M{"$in": nodes}},
+			bson.M{"to": bson.M{"$in": nodes}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen", Value: -1}}).SetLimit(limit)
+	cursor, err := m.graphEdges.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	edges := []models.GraphEdge{}
+	if err := cursor.All(ctx, &edges); err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
@@ -2,26 +2,51 @@ package database
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
 	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 type MongoDB struct {
 	client     *mongo.Client
 	database   *mongo.Database
 	collection *mongo.Collection
+	apiKeys    *mongo.Collection
+	tenants    *mongo.Collection
+	shared     *mongo.Collection
+	rateLimits *mongo.Collection
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+	// taxiiCollections holds TAXII collection definitions.
+	taxiiCollections *mongo.Collection
+	allowlist        *mongo.Collection
+	rpzZones         *mongo.Collection
+	rpzChanges       *mongo.Collection
+	sightings        *mongo.Collection
+	graphEdges       *mongo.Collection
+}
+
+// threatIndex makes an indicator unique within a tenant.
+var threatIndex = mongo.IndexModel{
+	Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "indicator", Value: 1}},
+	Options: options.Index().SetUnique(true),
 }
 
 func NewMongoDB(uri, dbName string) (*MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	monitor := chainMonitors(metrics.MongoMonitor(), otelmongo.NewMonitor())
+	clientOpts := options.Client().ApplyURI(uri).SetMonitor(monitor)
+	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -35,11 +60,7 @@ func NewMongoDB(uri, dbName string) (*MongoDB, error) {
 	collection := db.Collection("threats")
 
 	// Create indexes
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "indicator", Value: 1}},
-		Options: options.Index().SetUnique(true),
-	}
-	_, err = collection.Indexes().CreateOne(ctx, indexModel)
+	_, err = collection.Indexes().CreateOne(ctx, threatIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -48,26 +69,150 @@ func NewMongoDB(uri, dbName string) (*MongoDB, error) {
 		client:     client,
 		database:   db,
 		collection: collection,
+		apiKeys:    db.Collection("api_keys"),
+		tenants:    db.Collection("tenants"),
+		shared:     db.Collection("shared_verdicts"),
+		rateLimits: db.Collection("rate_limits"),
+		webhooks:   db.Collection("webhooks"),
+		deliveries: db.Collection("webhook_deliveries"),
+
+		taxiiCollections: db.Collection("taxii_collections"),
+		allowlist:        db.Collection("allowlist"),
+		rpzZones:         db.Collection("rpz_zones"),
+		rpzChanges:       db.Collection("rpz_changes"),
+		sightings:        db.Collection("sightings"),
+		graphEdges:       db.Collection("graph_edges"),
 	}, nil
 }
 
 // CreateIndexes ensures the required indexes exist for the collection.
+// It also moves records written before tenancy existed into the default
+// tenant and drops the old globally-unique indicator index.
 // It's safe to call multiple times.
 func (m *MongoDB) CreateIndexes() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "indicator", Value: 1}},
+	legacy := bson.M{"tenant_id": bson.M{"$exists": false}}
+	assign := bson.M{"$set": bson.M{"tenant_id": models.DefaultTenant}}
+	if _, err := m.collection.UpdateMany(ctx, legacy, assign); err != nil {
+		return err
+	}
+	if _, err := m.apiKeys.UpdateMany(ctx, legacy, assign); err != nil {
+		return err
+	}
+
+	// Ignore the error: the index is absent on fresh databases.
+	_, _ = m.collection.Indexes().DropOne(ctx, "indicator_1")
+
+	if _, err := m.collection.Indexes().CreateOne(ctx, threatIndex); err != nil {
+		return err
+	}
+
+	if _, err := m.apiKeys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
 		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+
+	if _, err := m.rateLimits.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return err
+	}
+
+	if _, err := m.webhooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "enabled", Value: 1}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := m.taxiiCollections.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := m.allowlist.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "value", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+
+	// Zone changes are kept for IXFR for 7 days; older secondaries get
+	// a full transfer.
+	if _, err := m.rpzChanges.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "to_serial", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(7 * 24 * 60 * 60),
+		},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := m.sightings.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "indicator", Value: 1}, {Key: "observed_at", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	// An edge is unique per relation; the graph is walked both ways.
+	if _, err := m.graphEdges.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "from", Value: 1}, {Key: "relation", Value: 1}, {Key: "to", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "to", Value: 1}}},
+	}); err != nil {
+		return err
 	}
 
-	_, err := m.collection.Indexes().CreateOne(ctx, indexModel)
+	// The threats listing can order by last sighting.
+	if _, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "last_sighted", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	// TAXII looks objects up by ID.
+	if _, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "stix_ids", Value: 1}},
+	}); err != nil {
+		return err
+	}
+
+	// TAXII pages through analyses in update order.
+	if _, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "last_updated", Value: 1}, {Key: "_id", Value: 1}},
+	}); err != nil {
+		return err
+	}
+
+	// Delivery records are kept for 30 days, dead letters included.
+	_, err := m.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(30 * 24 * 60 * 60),
+		},
+	})
 	return err
 }
 
-func (m *MongoDB) SaveThreat(threat *models.ThreatIndicator) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ErrNoTenant is returned when a record is written without a tenant.
+var ErrNoTenant = errors.New("database: record has no tenant")
+
+func (m *MongoDB) SaveThreat(ctx context.Context, threat *models.ThreatIndicator) error {
+	if threat.TenantID == "" {
+		return ErrNoTenant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	threat.LastUpdated = time.Now()
@@ -75,20 +220,112 @@ func (m *MongoDB) SaveThreat(threat *models.ThreatIndicator) error {
 		threat.FirstSeen = time.Now()
 	}
 
-	filter := bson.M{"indicator": threat.Indicator}
+	threat.STIXIDs = stix.ObjectIDs(threat)
+
+	filter := bson.M{"tenant_id": threat.TenantID, "indicator": threat.Indicator}
 	update := bson.M{"$set": threat}
-	opts := options.Update().SetUpsert(true)
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"_id": 1})
+
+	// The stored ID goes back on the threat for events and responses.
+	var saved struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := m.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&saved); err != nil {
+		return err
+	}
+	threat.ID = saved.ID
+	return nil
+}
 
-	_, err := m.collection.UpdateOne(ctx, filter, update, opts)
-	return err
+// ImportThreat records an indicator from an external source. A new one is
+// stored unscored until it is analyzed; either way its tags are added and
+// the metadata keys overwritten. It reports whether the indicator was new.
+func (m *MongoDB) ImportThreat(ctx context.Context, threat *models.ThreatIndicator) (bool, error) {
+	if threat.TenantID == "" {
+		return false, ErrNoTenant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{}
+	for k, v := range threat.Metadata {
+		if k != models.MetadataImportedTags {
+			set["metadata."+k] = v
+		}
+	}
+	tags := nonNilStrings(threat.Tags)
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"tenant_id":    threat.TenantID,
+			"indicator":    threat.Indicator,
+			"type":         threat.Type,
+			"risk_score":   0.0,
+			"reputation":   "unknown",
+			"sources":      []models.SourceData{},
+			"first_seen":   now,
+			"last_updated": now,
+			"stix_ids":     stix.ObjectIDs(&models.ThreatIndicator{TenantID: threat.TenantID, Indicator: threat.Indicator, Type: threat.Type}),
+		},
+		"$addToSet": bson.M{
+			"tags": bson.M{"$each": tags},
+			"metadata." + models.MetadataImportedTags: bson.M{"$each": tags},
+		},
+	}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	filter := bson.M{"tenant_id": threat.TenantID, "indicator": threat.Indicator}
+	res, err := m.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+// InsertThreat stores an analysis unless the tenant already has one for the
+// indicator, which is left untouched. It reports whether it was stored.
+func (m *MongoDB) InsertThreat(ctx context.Context, threat *models.ThreatIndicator) (bool, error) {
+	if threat.TenantID == "" {
+		return false, ErrNoTenant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	doc := *threat
+	doc.ID = primitive.NilObjectID
+	doc.Tags = nonNilStrings(doc.Tags)
+	if doc.Sources == nil {
+		doc.Sources = []models.SourceData{}
+	}
+	doc.STIXIDs = stix.ObjectIDs(&doc)
+	filter := bson.M{"tenant_id": threat.TenantID, "indicator": threat.Indicator}
+	res, err := m.collection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
 }
 
-func (m *MongoDB) GetThreat(indicator string) (*models.ThreatIndicator, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func (m *MongoDB) GetThreat(ctx context.Context, tenantID, indicator string) (*models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var threat models.ThreatIndicator
-	err := m.collection.FindOne(ctx, bson.M{"indicator": indicator}).Decode(&threat)
+	err := m.collection.FindOne(ctx, bson.M{"tenant_id": tenantID, "indicator": indicator}).Decode(&threat)
 	if err != nil {
 		return nil, err
 	}
@@ -96,12 +333,25 @@ func (m *MongoDB) GetThreat(indicator string) (*models.ThreatIndicator, error) {
 	return &threat, nil
 }
 
-func (m *MongoDB) GetAllThreats(limit int64) ([]models.ThreatIndicator, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Orders for GetAllThreats.
+const (
+	SortLastUpdated = "last_updated"
+	// SortLastSighted lists only sighted indicators, most recent first.
+	SortLastSighted = "last_sighted"
+)
+
+func (m *MongoDB) GetAllThreats(ctx context.Context, tenantID string, limit int64, sortBy string) ([]models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	opts := options.Find().SetLimit(limit).SetSort(bson.D{{Key: "last_updated", Value: -1}})
-	cursor, err := m.collection.Find(ctx, bson.M{}, opts)
+	query := bson.M{"tenant_id": tenantID}
+	if sortBy == SortLastSighted {
+		query["last_sighted"] = bson.M{"$exists": true}
+	} else {
+		sortBy = SortLastUpdated
+	}
+	opts := options.Find().SetLimit(limit).SetSort(bson.D{{Key: sortBy, Value: -1}})
+	cursor, err := m.collection.Find(ctx, query, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -115,11 +365,155 @@ func (m *MongoDB) GetAllThreats(limit int64) ([]models.ThreatIndicator, error) {
 	return threats, nil
 }
 
-func (m *MongoDB) GetThreatHistory(indicator string) ([]models.ThreatIndicator, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// FindThreats returns the tenant's analyses matching the filter, most
+// recently updated first.
+func (m *MongoDB) FindThreats(ctx context.Context, tenantID string, filter models.ThreatFilter) ([]models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_updated", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	return m.findThreats(ctx, threatQuery(tenantID, filter), opts)
+}
+
+// ThreatsAfter pages through the tenant's analyses matching the filter in
+// update order, oldest first, starting after the given update time. afterID
+// breaks ties between analyses updated at the same time; when it is zero,
+// analyses updated exactly at after are skipped.
+func (m *MongoDB) ThreatsAfter(ctx context.Context, tenantID string, filter models.ThreatFilter, after time.Time, afterID primitive.ObjectID) ([]models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := threatQuery(tenantID, filter)
+	if !after.IsZero() {
+		updated, _ := query["last_updated"].(bson.M)
+		if updated == nil {
+			updated = bson.M{}
+		}
+		if afterID.IsZero() {
+			updated["$gt"] = after
+		} else {
+			updated["$gte"] = maxTime(after, filter.UpdatedSince)
+			query["$or"] = bson.A{
+				bson.M{"last_updated": bson.M{"$gt": after}},
+				bson.M{"last_updated": after, "_id": bson.M{"$gt": afterID}},
+			}
+		}
+		query["last_updated"] = updated
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_updated", Value: 1}, {Key: "_id", Value: 1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	return m.findThreats(ctx, query, opts)
+}
+
+func (m *MongoDB) findThreats(ctx context.Context, query bson.M, opts *options.FindOptions) ([]models.ThreatIndicator, error) {
+	cursor, err := m.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	threats := []models.ThreatIndicator{}
+	if err := cursor.All(ctx, &threats); err != nil {
+		return nil, err
+	}
+	return threats, nil
+}
+
+func threatQuery(tenantID string, filter models.ThreatFilter) bson.M {
+	query := bson.M{"tenant_id": tenantID}
+	if len(filter.Types) > 0 {
+		query["type"] = bson.M{"$in": filter.Types}
+	}
+	if len(filter.Reputations) > 0 {
+		query["reputation"] = bson.M{"$in": filter.Reputations}
+	}
+	if len(filter.Tags) > 0 {
+		query["tags"] = bson.M{"$in": filter.Tags}
+	}
+	if filter.MinScore > 0 {
+		query["risk_score"] = bson.M{"$gte": filter.MinScore}
+	}
+	if !filter.UpdatedSince.IsZero() {
+		query["last_updated"] = bson.M{"$gte": filter.UpdatedSince}
+	}
+	return query
+}
+
+// FindThreatBySTIXID returns the analysis matching filter that has the STIX
+// object id, or mongo.ErrNoDocuments.
+func (m *MongoDB) FindThreatBySTIXID(ctx context.Context, tenantID string, filter models.ThreatFilter, id string) (*models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	cursor, err := m.collection.Find(ctx, bson.M{"indicator": indicator})
+	query := threatQuery(tenantID, filter)
+	query["stix_ids"] = id
+	var threat models.ThreatIndicator
+	if err := m.collection.FindOne(ctx, query).Decode(&threat); err != nil {
+		return nil, err
+	}
+	return &threat, nil
+}
+
+// BackfillSTIXIDs stores the STIX object IDs of analyses saved before they
+// were kept, returning how many it updated.
+func (m *MongoDB) BackfillSTIXIDs(ctx context.Context) (int, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"stix_ids": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	var batch []mongo.WriteModel
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := m.collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+		if res != nil {
+			updated += int(res.ModifiedCount)
+		}
+		batch = batch[:0]
+		return err
+	}
+	for cursor.Next(ctx) {
+		var threat models.ThreatIndicator
+		if err := cursor.Decode(&threat); err != nil {
+			return updated, err
+		}
+		batch = append(batch, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": threat.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"stix_ids": stix.ObjectIDs(&threat)}}))
+		if len(batch) == 500 {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, err
+	}
+	return updated, flush()
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (m *MongoDB) GetThreatHistory(ctx context.Context, tenantID, indicator string) ([]models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := m.collection.Find(ctx, bson.M{"tenant_id": tenantID, "indicator": indicator})
 	if err != nil {
 		return nil, err
 	}
@@ -133,17 +527,38 @@ func (m *MongoDB) GetThreatHistory(indicator string) ([]models.ThreatIndicator,
 	return history, nil
 }
 
-func (m *MongoDB) DeleteThreat(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// DeleteThreat removes the record and returns it.
+func (m *MongoDB) DeleteThreat(ctx context.Context, tenantID, id string) (*models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = m.collection.DeleteOne(ctx, bson.M{"_id": objID})
-	return err
+	var threat models.ThreatIndicator
+	if err := m.collection.FindOneAndDelete(ctx, bson.M{"_id": objID, "tenant_id": tenantID}).Decode(&threat); err != nil {
+		return nil, err
+	}
+	return &threat, nil
+}
+
+func (m *MongoDB) UpdateThreatNotes(ctx context.Context, tenantID, indicator, notes string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.UpdateOne(ctx,
+		bson.M{"tenant_id": tenantID, "indicator": indicator},
+		bson.M{"$set": bson.M{"notes": notes}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
 }
 
 func (m *MongoDB) Disconnect() error {
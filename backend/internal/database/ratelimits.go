@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IncrementRateLimit counts one request against key in the fixed window
+// starting at windowStart and returns the window's total. Counter documents
+// expire through a TTL index once the window is over.
+func (m *MongoDB) IncrementRateLimit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	id := key + "@" + strconv.FormatInt(windowStart.Unix(), 10)
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expires_at": windowStart.Add(window)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var doc struct {
+		Count int64 `bson:"count"`
+	}
+	err := m.rateLimits.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&doc)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another replica inserted the window concurrently; the retry updates it.
+		err = m.rateLimits.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&doc)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return doc.Count, nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrStale is returned when a record changed between reading and writing it.
+var ErrStale = errors.New("database: record changed concurrently")
+
+func (m *MongoDB) GetRPZState(ctx context.Context, tenantID string) (*models.RPZState, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var state models.RPZState
+	if err := m.rpzZones.FindOne(ctx, bson.M{"_id": tenantID}).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveRPZVersion replaces the zone version with serial prevSerial (zero for
+// a tenant without one) by state and journals change. It returns ErrStale
+// if another writer got there first.
+func (m *MongoDB) SaveRPZVersion(ctx context.Context, prevSerial int64, state *models.RPZState, change *models.RPZChange) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	state.UpdatedAt = time.Now()
+	if prevSerial == 0 {
+		if _, err := m.rpzZones.InsertOne(ctx, state); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return ErrStale
+			}
+			return err
+		}
+	} else {
+		res, err := m.rpzZones.ReplaceOne(ctx, bson.M{"_id": state.TenantID, "serial": prevSerial}, state)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return ErrStale
+		}
+	}
+
+	if change == nil {
+		return nil
+	}
+	change.CreatedAt = state.UpdatedAt
+	_, err := m.rpzChanges.InsertOne(ctx, change)
+	return err
+}
+
+// ListRPZChanges returns the journaled changes after serial, oldest first.
+func (m *MongoDB) ListRPZChanges(ctx context.Context, tenantID string, serial int64) ([]models.RPZChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "to_serial", Value: 1}}).SetLimit(1000)
+	cursor, err := m.rpzChanges.Find(ctx, bson.M{"tenant_id": tenantID, "to_serial": bson.M{"$gt": serial}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []models.RPZChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
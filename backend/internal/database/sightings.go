@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxIndicatorLookup bounds one $in query in FindKnownThreats.
+const maxIndicatorLookup = 1000
+
+// CreateSightings stores sightings, assigning their IDs and creation time.
+func (m *MongoDB) CreateSightings(ctx context.Context, sightings []models.Sighting) error {
+	if len(sightings) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	docs := make([]interface{}, len(sightings))
+	for i := range sightings {
+		if sightings[i].TenantID == "" {
+			return ErrNoTenant
+		}
+		sightings[i].ID = primitive.NewObjectID()
+		sightings[i].CreatedAt = now
+		docs[i] = sightings[i]
+	}
+	_, err := m.sightings.InsertMany(ctx, docs)
+	return err
+}
+
+// FindKnownThreats returns the tenant's stored analyses for the given
+// indicators, restricted to the reputations when any are given.
+func (m *MongoDB) FindKnownThreats(ctx context.Context, tenantID string, indicators []string, reputations []string) ([]models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	threats := []models.ThreatIndicator{}
+	for start := 0; start < len(indicators); start += maxIndicatorLookup {
+		end := min(start+maxIndicatorLookup, len(indicators))
+		query := bson.M{"tenant_id": tenantID, "indicator": bson.M{"$in": indicators[start:end]}}
+		if len(reputations) > 0 {
+			query["reputation"] = bson.M{"$in": reputations}
+		}
+		found, err := m.findThreats(ctx, query, options.Find())
+		if err != nil {
+			return nil, err
+		}
+		threats = append(threats, found...)
+	}
+	return threats, nil
+}
+
+// AddThreatSightings adds to a stored analysis' sighting count and moves
+// its last sighting forward, returning the updated record.
+func (m *MongoDB) AddThreatSightings(ctx context.Context, tenantID, indicator string, count int, lastSighted time.Time) (*models.ThreatIndicator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"tenant_id": tenantID, "indicator": indicator}
+	update := bson.M{
+		"$inc": bson.M{"sighting_count": count},
+		"$max": bson.M{"last_sighted": lastSighted},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var threat models.ThreatIndicator
+	if err := m.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&threat); err != nil {
+		return nil, err
+	}
+	return &threat, nil
+}
+
+// UpdateThreatScore stores a new risk score and reputation for an analysis.
+func (m *MongoDB) UpdateThreatScore(ctx context.Context, tenantID, indicator string, riskScore float64, reputation string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"tenant_id": tenantID, "indicator": indicator}
+	update := bson.M{"$set": bson.M{"risk_score": riskScore, "reputation": reputation, "last_updated": time.Now()}}
+	_, err := m.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// ListSightings returns an indicator's sightings, most recent first,
+// optionally only those observed at or after since.
+func (m *MongoDB) ListSightings(ctx context.Context, tenantID, indicator string, since time.Time, limit int64) ([]models.Sighting, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := bson.M{"tenant_id": tenantID, "indicator": indicator}
+	if !since.IsZero() {
+		query["observed_at"] = bson.M{"$gte": since}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "observed_at", Value: -1}}).SetLimit(limit)
+	cursor, err := m.sightings.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sightings := []models.Sighting{}
+	if err := cursor.All(ctx, &sightings); err != nil {
+		return nil, err
+	}
+	return sightings, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoDB) CreateTAXIICollection(ctx context.Context, coll *models.TAXIICollection) error {
+	if coll.TenantID == "" {
+		return ErrNoTenant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll.ID = uuid.NewString()
+	coll.CreatedAt = time.Now()
+	coll.UpdatedAt = coll.CreatedAt
+	_, err := m.taxiiCollections.InsertOne(ctx, coll)
+	return err
+}
+
+func (m *MongoDB) GetTAXIICollection(ctx context.Context, tenantID, id string) (*models.TAXIICollection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var coll models.TAXIICollection
+	if err := m.taxiiCollections.FindOne(ctx, bson.M{"_id": id, "tenant_id": tenantID}).Decode(&coll); err != nil {
+		return nil, err
+	}
+	return &coll, nil
+}
+
+func (m *MongoDB) ListTAXIICollections(ctx context.Context, tenantID string) ([]models.TAXIICollection, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := m.taxiiCollections.Find(ctx, bson.M{"tenant_id": tenantID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	colls := []models.TAXIICollection{}
+	if err := cursor.All(ctx, &colls); err != nil {
+		return nil, err
+	}
+	return colls, nil
+}
+
+func (m *MongoDB) UpdateTAXIICollection(ctx context.Context, coll *models.TAXIICollection) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll.UpdatedAt = time.Now()
+	res, err := m.taxiiCollections.ReplaceOne(ctx, bson.M{"_id": coll.ID, "tenant_id": coll.TenantID}, coll)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (m *MongoDB) DeleteTAXIICollection(ctx context.Context, tenantID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := m.taxiiCollections.DeleteOne(ctx, bson.M{"_id": id, "tenant_id": tenantID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
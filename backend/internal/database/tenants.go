@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoDB) CreateTenant(ctx context.Context, tenant *models.Tenant) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tenant.CreatedAt = time.Now()
+	tenant.UpdatedAt = tenant.CreatedAt
+	_, err := m.tenants.InsertOne(ctx, tenant)
+	return err
+}
+
+func (m *MongoDB) GetTenant(ctx context.Context, id string) (*models.Tenant, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var tenant models.Tenant
+	if err := m.tenants.FindOne(ctx, bson.M{"_id": id}).Decode(&tenant); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (m *MongoDB) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := m.tenants.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tenants := []models.Tenant{}
+	if err := cursor.All(ctx, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// UpdateTenant replaces the tenant's settings. Provider keys are only
+// replaced when keys is non-nil.
+func (m *MongoDB) UpdateTenant(ctx context.Context, tenant *models.Tenant, keys *models.ProviderKeys) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tenant.UpdatedAt = time.Now()
+	set := bson.M{
+		"name":           tenant.Name,
+		"webhook_url":    tenant.WebhookURL,
+		"share_verdicts": tenant.ShareVerdicts,
+		"updated_at":     tenant.UpdatedAt,
+	}
+	if keys != nil {
+		set["provider_keys"] = keys
+	}
+
+	res, err := m.tenants.UpdateOne(ctx, bson.M{"_id": tenant.ID}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SaveSharedVerdict publishes a tenant's verdict to the cross-tenant pool.
+// The newest verdict wins; Reports counts how many times it was published.
+func (m *MongoDB) SaveSharedVerdict(ctx context.Context, verdict *models.SharedVerdict) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"type":         verdict.Type,
+			"risk_score":   verdict.RiskScore,
+			"reputation":   verdict.Reputation,
+			"sources":      verdict.Sources,
+			"last_updated": verdict.LastUpdated,
+		},
+		"$inc": bson.M{"reports": 1},
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := m.shared.UpdateOne(ctx, bson.M{"_id": verdict.Indicator}, update, opts)
+	return err
+}
+
+func (m *MongoDB) GetSharedVerdict(ctx context.Context, indicator string) (*models.SharedVerdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var verdict models.SharedVerdict
+	if err := m.shared.FindOne(ctx, bson.M{"_id": indicator}).Decode(&verdict); err != nil {
+		return nil, err
+	}
+	return &verdict, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoDB) CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) error {
+	if sub.TenantID == "" {
+		return ErrNoTenant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+	res, err := m.webhooks.InsertOne(ctx, sub)
+	if err != nil {
+		return err
+	}
+	sub.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *MongoDB) GetWebhook(ctx context.Context, tenantID, id string) (*models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var sub models.WebhookSubscription
+	if err := m.webhooks.FindOne(ctx, bson.M{"_id": objID, "tenant_id": tenantID}).Decode(&sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListWebhooks returns the tenant's subscriptions, optionally only enabled ones.
+func (m *MongoDB) ListWebhooks(ctx context.Context, tenantID string, enabledOnly bool) ([]models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"tenant_id": tenantID}
+	if enabledOnly {
+		filter["enabled"] = true
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := m.webhooks.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []models.WebhookSubscription{}
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (m *MongoDB) UpdateWebhook(ctx context.Context, sub *models.WebhookSubscription) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	sub.UpdatedAt = time.Now()
+	res, err := m.webhooks.ReplaceOne(ctx, bson.M{"_id": sub.ID, "tenant_id": sub.TenantID}, sub)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (m *MongoDB) DeleteWebhook(ctx context.Context, tenantID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	res, err := m.webhooks.DeleteOne(ctx, bson.M{"_id": objID, "tenant_id": tenantID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
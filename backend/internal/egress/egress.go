@@ -0,0 +1,112 @@
+// Package egress guards outbound requests to URLs that API callers supply,
+// such as webhook receivers and MISP feeds, so they can't be pointed at the
+// backend's own network.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrBlocked is returned for destinations that aren't on the public
+// internet.
+var ErrBlocked = errors.New("destination is not a public address")
+
+// sharedAddressSpace (RFC 6598) is carrier NAT space, also used for some
+// cloud metadata services.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+var (
+	mu    sync.RWMutex
+	allow []netip.Prefix
+)
+
+// Allow lets caller-supplied URLs reach these prefixes even though they are
+// private, e.g. an internal MISP instance.
+func Allow(cidrs []string) error {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return fmt.Errorf("invalid egress prefix %q: %w", cidr, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	mu.Lock()
+	allow = prefixes
+	mu.Unlock()
+	return nil
+}
+
+// Permitted reports whether requests may be sent to the address: public
+// unicast addresses, and any prefix passed to Allow.
+func Permitted(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, prefix := range allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return ip.IsValid() && !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// CheckURL validates an http(s) URL when it is saved, rejecting hosts that
+// resolve to any address Permitted refuses. Requests are checked again when
+// they connect, since DNS answers can change.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("must be an http(s) URL")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !Permitted(addr) {
+			return fmt.Errorf("%s resolves to %s: %w", u.Hostname(), addr.Unmap(), ErrBlocked)
+		}
+	}
+	return nil
+}
+
+// control refuses connections to addresses Permitted refuses. It runs after
+// name resolution, for every address dialled, so it also covers redirects
+// and DNS rebinding.
+func control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !Permitted(addrPort.Addr()) {
+		return fmt.Errorf("connect to %s: %w", addrPort.Addr(), ErrBlocked)
+	}
+	return nil
+}
+
+// Transport is an http.Transport whose connections may only reach
+// permitted addresses. It ignores proxy settings, which would otherwise do
+// the dialling.
+func Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}).DialContext
+	return t
+}
@@ -0,0 +1,221 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+)
+
+var busLog = logging.For("events")
+
+// SubscribeOptions controls what a subscriber receives and what happens
+// when it falls behind.
+type SubscribeOptions struct {
+	// Types limits delivery to these event types; empty means all.
+	Types []string
+	// Buffer is the number of events queued for the subscriber (default 256).
+	Buffer int
+	// DropWhenFull discards events for a full subscriber instead of making
+	// the publisher wait. Use it for lossy consumers such as live streams.
+	DropWhenFull bool
+}
+
+type subscriber struct {
+	name    string
+	types   map[string]bool
+	drop    bool
+	ch      chan Event
+	handler func(Event)
+	// quit is closed when the subscriber is removed. ch is never closed, so
+	// a publisher that raced with the removal can't panic sending on it.
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func (s *subscriber) wants(eventType string) bool {
+	return len(s.types) == 0 || s.types[eventType]
+}
+
+// Bus fans events out to subscribers, each with its own bounded queue and
+// goroutine so a slow subscriber only delays itself, or, when it blocks, the
+// publishers. A nil *Bus discards everything.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*subscriber]struct{})}
+}
+
+// Subscribe runs handler for every matching event until the returned
+// function is called or the bus is closed. Handlers of one subscriber run
+// sequentially, in publish order.
+func (b *Bus) Subscribe(name string, opts SubscribeOptions, handler func(Event)) (unsubscribe func()) {
+	if b == nil {
+		return func() {}
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 256
+	}
+	s := &subscriber{
+		name:    name,
+		drop:    opts.DropWhenFull,
+		ch:      make(chan Event, opts.Buffer),
+		handler: handler,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if len(opts.Types) > 0 {
+		s.types = make(map[string]bool, len(opts.Types))
+		for _, t := range opts.Types {
+			s.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(s.done)
+		return func() {}
+	}
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	go s.run()
+	return func() { b.remove(s) }
+}
+
+// run handles events until the subscriber is stopped, then whatever is
+// still queued.
+func (s *subscriber) run() {
+	defer close(s.done)
+	for {
+		select {
+		case e := <-s.ch:
+			s.handle(e)
+		case <-s.quit:
+			for {
+				select {
+				case e := <-s.ch:
+					s.handle(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *subscriber) stop() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+func (s *subscriber) handle(e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			busLog.Error("event subscriber panicked", "subscriber", s.name, "event", e.Type, "panic", r)
+		}
+	}()
+	s.handler(e)
+}
+
+func (b *Bus) remove(s *subscriber) {
+	b.mu.Lock()
+	_, ok := b.subs[s]
+	delete(b.subs, s)
+	b.mu.Unlock()
+	if ok {
+		s.stop()
+	}
+}
+
+// Publish hands the event to every interested subscriber. For blocking
+// subscribers with a full queue it waits until there is room, the subscriber
+// is removed or ctx is done, in which case that subscriber misses the event.
+// The bus isn't locked while it waits, so subscribing, unsubscribing and
+// Close never wait on a slow subscriber.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.ctx = context.WithoutCancel(ctx)
+	metrics.RecordEventPublished(e.Type)
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return
+	}
+	subs := make([]*subscriber, 0, len(b.subs))
+	for s := range b.subs {
+		if s.wants(e.Type) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		select {
+		case <-s.quit:
+			continue
+		default:
+		}
+		select {
+		case s.ch <- e:
+			continue
+		default:
+		}
+
+		if s.drop {
+			metrics.RecordEventDropped(s.name)
+			continue
+		}
+		metrics.RecordEventBackpressure(s.name)
+		select {
+		case s.ch <- e:
+		case <-s.quit:
+		case <-ctx.Done():
+			metrics.RecordEventDropped(s.name)
+			busLog.WarnContext(ctx, "event dropped, subscriber is full", "subscriber", s.name, "event", e.Type)
+		}
+	}
+}
+
+// Close stops accepting events and waits until subscribers have handled
+// what is queued, or ctx is done.
+func (b *Bus) Close(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*subscriber, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.subs = make(map[*subscriber]struct{})
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.stop()
+	}
+
+	for _, s := range subs {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// Event types.
+const (
+	AnalysisStarted   = "analysis_started"
+	SourceCompleted   = "source_completed"
+	AnalysisCompleted = "analysis_completed"
+	ReputationChanged = "reputation_changed"
+	IndicatorDeleted  = "indicator_deleted"
+	ListMatched       = "list_matched"
+	IndicatorSighted  = "indicator_sighted"
+)
+
+// ListAllowlist is the List of a list_matched event for an analyzed
+// indicator on the tenant's allowlist.
+const ListAllowlist = "allowlist"
+
+// Event is one step in an indicator's lifecycle. Which fields are set
+// depends on Type.
+type Event struct {
+	Type          string
+	Time          time.Time
+	TenantID      string
+	Indicator     string
+	IndicatorType string
+
+	// Threat is the stored result for analysis_completed and
+	// reputation_changed, and the removed record for indicator_deleted.
+	Threat *models.ThreatIndicator
+	// Source is the provider result for source_completed; SourceError is
+	// set instead when the provider failed.
+	Source      *models.SourceData
+	SourceName  string
+	SourceError string
+	// PreviousReputation is set for reputation_changed.
+	PreviousReputation string
+	// List names the list an indicator matched, for list_matched.
+	List string
+	// Sighting is set for indicator_sighted, with Threat the stored
+	// analysis of the sighted indicator.
+	Sighting *models.Sighting
+
+	ctx context.Context
+}
+
+// Context returns the publisher's context without its cancellation, so
+// subscribers keep the request ID and trace.
+func (e Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
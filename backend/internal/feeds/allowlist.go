@@ -0,0 +1,123 @@
+package feeds
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+)
+
+// AllowlistType classifies an allowlist value and returns it normalized.
+func AllowlistType(value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return "ip", prefix.Masked().String(), nil
+	}
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return "ip", addr.String(), nil
+	}
+	if strings.Contains(value, "://") {
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			return "url", value, nil
+		}
+		return "", "", fmt.Errorf("invalid URL %q", value)
+	}
+	if _, ok := stix.HashAlgorithm(strings.ToLower(value)); ok {
+		return "hash", strings.ToLower(value), nil
+	}
+	if domain := NormalizeDomain(value); strings.Contains(domain, ".") && !strings.ContainsAny(domain, " /:") {
+		return "domain", domain, nil
+	}
+	return "", "", fmt.Errorf("%q is not an IP, CIDR, domain, URL or hash", value)
+}
+
+// NormalizeDomain lowercases a domain and drops a trailing dot.
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Allowlist answers whether an indicator is allowlisted.
+type Allowlist struct {
+	prefixes []netip.Prefix
+	domains  map[string]bool
+	urls     map[string]bool
+	hashes   map[string]bool
+}
+
+func NewAllowlist(entries []models.AllowlistEntry) *Allowlist {
+	a := &Allowlist{domains: map[string]bool{}, urls: map[string]bool{}, hashes: map[string]bool{}}
+	for _, e := range entries {
+		switch e.Type {
+		case "ip":
+			if prefix, err := netip.ParsePrefix(e.Value); err == nil {
+				a.prefixes = append(a.prefixes, prefix)
+			} else if addr, err := netip.ParseAddr(e.Value); err == nil {
+				a.prefixes = append(a.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			}
+		case "domain":
+			a.domains[NormalizeDomain(e.Value)] = true
+		case "url":
+			a.urls[e.Value] = true
+		case "hash":
+			a.hashes[strings.ToLower(e.Value)] = true
+		}
+	}
+	return a
+}
+
+// Allows reports whether the indicator is allowlisted. Domains cover their
+// subdomains, and URLs are allowlisted when their host is.
+func (a *Allowlist) Allows(indicatorType, value string) bool {
+	if a == nil {
+		return false
+	}
+	switch indicatorType {
+	case "ip":
+		addr, err := netip.ParseAddr(value)
+		return err == nil && a.allowsAddr(addr)
+	case "domain":
+		return a.allowsDomain(NormalizeDomain(value))
+	case "url":
+		if a.urls[value] {
+			return true
+		}
+		u, err := url.Parse(value)
+		if err != nil {
+			return false
+		}
+		if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+			return a.allowsAddr(addr)
+		}
+		return a.allowsDomain(NormalizeDomain(u.Hostname()))
+	case "hash":
+		return a.hashes[strings.ToLower(value)]
+	}
+	return false
+}
+
+func (a *Allowlist) allowsAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range a.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Allowlist) allowsDomain(domain string) bool {
+	for domain != "" {
+		if a.domains[domain] {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return false
+		}
+		domain = parent
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package feeds
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// Aggregate collapses addresses into the fewest CIDR prefixes covering
+// exactly those addresses.
+func Aggregate(addrs []netip.Addr) []netip.Prefix {
+	addrs = slices.Clone(addrs)
+	slices.SortFunc(addrs, netip.Addr.Compare)
+	addrs = slices.Compact(addrs)
+
+	var prefixes []netip.Prefix
+	for i := 0; i < len(addrs); {
+		start, end := addrs[i], addrs[i]
+		for i++; i < len(addrs) && addrs[i] == end.Next() && addrs[i].Is4() == start.Is4(); i++ {
+			end = addrs[i]
+		}
+		prefixes = append(prefixes, rangePrefixes(start, end)...)
+	}
+	return prefixes
+}
+
+// rangePrefixes splits start-end into the largest aligned prefixes.
+func rangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		bits := start.BitLen()
+		for bits > 0 {
+			wider := netip.PrefixFrom(start, bits-1)
+			if wider.Masked().Addr() != start || lastAddr(wider).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, prefix)
+
+		last := lastAddr(prefix)
+		if last == end {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,107 @@
+// Package feeds renders stored verdicts as plaintext blocklists for
+// firewalls, proxies and DNS filters.
+package feeds
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// Feed formats.
+const (
+	FormatPlain       = "plain"       // one entry per line
+	FormatPaloAlto    = "paloalto"    // PAN-OS external dynamic list
+	FormatPfBlockerNG = "pfblockerng" // pfSense pfBlockerNG IP and DNSBL lists
+	FormatHosts       = "hosts"       // hosts file, domains only
+)
+
+var ErrFormat = errors.New("feeds: unsupported format")
+
+type Options struct {
+	Format string
+	// Aggregate collapses IP feeds into CIDR prefixes.
+	Aggregate bool
+}
+
+// Entries returns the feed's sorted, de-duplicated entries for one indicator
+// type, leaving out allowlisted values.
+func Entries(indicatorType string, threats []models.ThreatIndicator, allow *Allowlist, opts Options) []string {
+	var entries []string
+	var addrs []netip.Addr
+	for _, t := range threats {
+		if t.Type != indicatorType || allow.Allows(t.Type, t.Indicator) {
+			continue
+		}
+		switch t.Type {
+		case "ip":
+			if addr, err := netip.ParseAddr(t.Indicator); err == nil {
+				addrs = append(addrs, addr.Unmap())
+			}
+		case "domain":
+			entries = append(entries, NormalizeDomain(t.Indicator))
+		case "url":
+			entry := t.Indicator
+			if opts.Format == FormatPaloAlto {
+				// PAN-OS URL lists match without the scheme.
+				_, entry, _ = strings.Cut(entry, "://")
+			}
+			entries = append(entries, entry)
+		case "hash":
+			entries = append(entries, strings.ToLower(t.Indicator))
+		}
+	}
+
+	if indicatorType == "ip" {
+		if opts.Aggregate {
+			for _, p := range Aggregate(addrs) {
+				if p.IsSingleIP() {
+					entries = append(entries, p.Addr().String())
+				} else {
+					entries = append(entries, p.String())
+				}
+			}
+			return entries
+		}
+		slices.SortFunc(addrs, netip.Addr.Compare)
+		for _, addr := range slices.Compact(addrs) {
+			entries = append(entries, addr.String())
+		}
+		return entries
+	}
+
+	slices.Sort(entries)
+	return slices.Compact(entries)
+}
+
+// Render writes the entries in the requested format. The output depends only
+// on the entries, so it can be hashed for an ETag.
+func Render(indicatorType string, entries []string, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "", FormatPlain, FormatPaloAlto:
+	case FormatPfBlockerNG:
+		fmt.Fprintf(&buf, "# ATIA %s blocklist\n# %d entries\n", indicatorType, len(entries))
+	case FormatHosts:
+		if indicatorType != "domain" {
+			return nil, fmt.Errorf("%w: hosts only applies to domains", ErrFormat)
+		}
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "0.0.0.0 %s\n", e)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrFormat, format)
+	}
+
+	for _, e := range entries {
+		buf.WriteString(e)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,44 @@
+package feeds
+
+import (
+	"sync"
+	"time"
+)
+
+// maxVersions bounds how many distinct feed URLs are tracked.
+const maxVersions = 1024
+
+// Versions remembers when each feed last changed, for Last-Modified. A
+// feed's content can shrink (entries age out or get allowlisted) without any
+// stored record changing, so the time is when its current ETag first
+// appeared rather than a record's update time.
+type Versions struct {
+	mu   sync.Mutex
+	seen map[string]version
+}
+
+type version struct {
+	etag  string
+	since time.Time
+}
+
+func NewVersions() *Versions {
+	return &Versions{seen: make(map[string]version)}
+}
+
+// Modified returns when the feed at key took on etag.
+func (v *Versions) Modified(key, etag string, now time.Time) time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if prev, ok := v.seen[key]; ok && prev.etag == etag {
+		return prev.since
+	}
+	if len(v.seen) >= maxVersions {
+		clear(v.seen)
+	}
+	// Truncated to whole seconds, the precision of HTTP dates.
+	now = now.Truncate(time.Second)
+	v.seen[key] = version{etag: etag, since: now}
+	return now
+}
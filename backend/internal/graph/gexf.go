@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	LastModified string `xml:"lastmodifieddate,attr"`
+	Creator      string `xml:"creator"`
+	Description  string `xml:"description,omitempty"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Mode            string           `xml:"mode,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID     string          `xml:"id,attr"`
+	Label  string          `xml:"label,attr"`
+	Values []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string          `xml:"id,attr"`
+	Source string          `xml:"source,attr"`
+	Target string          `xml:"target,attr"`
+	Label  string          `xml:"label,attr"`
+	Kind   string          `xml:"kind,attr"`
+	Values []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttrValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+var (
+	gexfNodeAttributes = gexfAttributes{Class: "node", Attributes: []gexfAttribute{
+		{ID: "type", Title: "type", Type: "string"},
+		{ID: "risk_score", Title: "risk_score", Type: "double"},
+		{ID: "reputation", Title: "reputation", Type: "string"},
+		{ID: "depth", Title: "depth", Type: "integer"},
+	}}
+	gexfEdgeAttributes = gexfAttributes{Class: "edge", Attributes: []gexfAttribute{
+		{ID: "sources", Title: "sources", Type: "string"},
+	}}
+)
+
+func writeGEXF(w io.Writer, g *models.Graph) error {
+	doc := gexf{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta: gexfMeta{
+			LastModified: time.Now().UTC().Format("2006-01-02"),
+			Creator:      "ATIA",
+			Description:  "Relationships of " + strings.Join(g.Roots, ", "),
+		},
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Mode:            "static",
+			Attributes:      []gexfAttributes{gexfNodeAttributes, gexfEdgeAttributes},
+			Nodes:           []gexfNode{},
+			Edges:           []gexfEdge{},
+		},
+	}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		values := []gexfAttrValue{
+			{For: "type", Value: n.Type},
+			{For: "depth", Value: strconv.Itoa(n.Depth)},
+		}
+		if score := riskScore(n); score != "" {
+			values = append(values, gexfAttrValue{For: "risk_score", Value: score})
+		}
+		if n.Reputation != "" {
+			values = append(values, gexfAttrValue{For: "reputation", Value: n.Reputation})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{ID: n.ID, Label: label(n), Values: values})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: e.From,
+			Target: e.To,
+			Label:  e.Relation,
+			Kind:   e.Relation,
+			Values: []gexfAttrValue{{For: "sources", Value: strings.Join(e.Sources, ",")}},
+		})
+	}
+	return writeXML(w, doc)
+}
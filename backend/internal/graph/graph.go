@@ -0,0 +1,83 @@
+// Package graph writes relationship graphs for Gephi (GraphML, GEXF) and
+// Maltego (CSV).
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// Formats.
+const (
+	FormatGraphML = "graphml"
+	FormatGEXF    = "gexf"
+	FormatMaltego = "maltego"
+)
+
+var ErrFormat = errors.New("graph: unknown format")
+
+// ContentTypes and Extensions describe each format's files.
+var (
+	ContentTypes = map[string]string{
+		FormatGraphML: "application/graphml+xml",
+		FormatGEXF:    "application/gexf+xml",
+		FormatMaltego: "text/csv; charset=utf-8",
+	}
+	Extensions = map[string]string{
+		FormatGraphML: "graphml",
+		FormatGEXF:    "gexf",
+		FormatMaltego: "csv",
+	}
+)
+
+// Format checks a format name.
+func Format(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case FormatGraphML, FormatGEXF, FormatMaltego:
+		return f, nil
+	case "csv":
+		return FormatMaltego, nil
+	}
+	return "", fmt.Errorf("%w %q, want graphml, gexf or maltego", ErrFormat, s)
+}
+
+// Write writes g in format.
+func Write(w io.Writer, format string, g *models.Graph) error {
+	switch format {
+	case FormatGraphML:
+		return writeGraphML(w, g)
+	case FormatGEXF:
+		return writeGEXF(w, g)
+	case FormatMaltego:
+		return writeMaltego(w, g)
+	}
+	return fmt.Errorf("%w %q", ErrFormat, format)
+}
+
+// label is what a node is shown as: a pulse's name, otherwise its value.
+func label(n *models.GraphNode) string {
+	if n.Label != "" {
+		return n.Label
+	}
+	return n.ID
+}
+
+func riskScore(n *models.GraphNode) string {
+	if n.RiskScore == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*n.RiskScore, 'f', -1, 64)
+}
+
+func nodeIndex(g *models.Graph) map[string]*models.GraphNode {
+	index := make(map[string]*models.GraphNode, len(g.Nodes))
+	for i := range g.Nodes {
+		index[g.Nodes[i].ID] = &g.Nodes[i]
+	}
+	return index
+}
@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLKeys declare the node and edge attributes. Gephi shows the
+// attributes named label as node and edge labels.
+var graphMLKeys = []graphMLKey{
+	{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+	{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+	{ID: "risk_score", For: "node", AttrName: "risk_score", AttrType: "double"},
+	{ID: "reputation", For: "node", AttrName: "reputation", AttrType: "string"},
+	{ID: "depth", For: "node", AttrName: "depth", AttrType: "int"},
+	{ID: "relation", For: "edge", AttrName: "label", AttrType: "string"},
+	{ID: "sources", For: "edge", AttrName: "sources", AttrType: "string"},
+}
+
+func writeGraphML(w io.Writer, g *models.Graph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: "atia", EdgeDefault: "directed"},
+	}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		data := []graphMLData{
+			{Key: "label", Value: label(n)},
+			{Key: "type", Value: n.Type},
+			{Key: "depth", Value: strconv.Itoa(n.Depth)},
+		}
+		if score := riskScore(n); score != "" {
+			data = append(data, graphMLData{Key: "risk_score", Value: score})
+		}
+		if n.Reputation != "" {
+			data = append(data, graphMLData{Key: "reputation", Value: n.Reputation})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.ID, Data: data})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: e.From,
+			Target: e.To,
+			Data: []graphMLData{
+				{Key: "relation", Value: e.Relation},
+				{Key: "sources", Value: strings.Join(e.Sources, ",")},
+			},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// maltegoHeader is one link per row, for Maltego's "Import Graph from
+// Table": map the entity type columns onto the entities and the relation
+// onto the link label.
+var maltegoHeader = []string{
+	"source_entity", "source_value", "source_reputation", "source_risk_score",
+	"relation",
+	"target_entity", "target_value", "target_reputation", "target_risk_score",
+}
+
+// maltegoEntity is the Maltego entity type of a node.
+func maltegoEntity(n *models.GraphNode) string {
+	switch n.Type {
+	case ioc.TypeIP:
+		if strings.Contains(n.ID, ":") {
+			return "maltego.IPv6Address"
+		}
+		return "maltego.IPv4Address"
+	case ioc.TypeDomain:
+		return "maltego.Domain"
+	case ioc.TypeURL:
+		return "maltego.URL"
+	case ioc.TypeHash:
+		return "maltego.Hash"
+	}
+	return "maltego.Phrase"
+}
+
+func maltegoColumns(n *models.GraphNode) []string {
+	return []string{maltegoEntity(n), label(n), n.Reputation, riskScore(n)}
+}
+
+// writeMaltego writes a row per edge and one for each node without edges,
+// with only its source columns.
+func writeMaltego(w io.Writer, g *models.Graph) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(maltegoHeader); err != nil {
+		return err
+	}
+
+	index := nodeIndex(g)
+	linked := map[string]bool{}
+	for _, e := range g.Edges {
+		from, to := index[e.From], index[e.To]
+		if from == nil || to == nil {
+			continue
+		}
+		linked[e.From], linked[e.To] = true, true
+		row := append(maltegoColumns(from), e.Relation)
+		if err := cw.Write(append(row, maltegoColumns(to)...)); err != nil {
+			return err
+		}
+	}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		if linked[n.ID] {
+			continue
+		}
+		row := append(maltegoColumns(n), "", "", "", "", "")
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,208 @@
+package ioc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Document formats.
+const (
+	FormatText  = "text"
+	FormatHTML  = "html"
+	FormatEmail = "eml"
+)
+
+// maxMIMEDepth bounds nested multiparts and forwarded messages.
+const maxMIMEDepth = 10
+
+// emailHeaders are the headers searched for indicators besides the body.
+var emailHeaders = []string{"From", "Reply-To", "Return-Path", "Sender", "Subject", "Received", "X-Originating-IP", "X-Sender-IP"}
+
+var (
+	htmlSniff   = regexp.MustCompile(`(?i)^\s*(?:<!doctype html|<html|<head|<body)|<(?:p|div|br|a|table|span)\b[^>]*>`)
+	firstHeader = regexp.MustCompile(`^[A-Za-z0-9-]+:[ \t]`)
+	headerSniff = regexp.MustCompile(`(?im)^(?:received|return-path|from|message-id|mime-version|delivered-to):[ \t]`)
+)
+
+// DetectFormat guesses a document's format from its start.
+func DetectFormat(body []byte) string {
+	head := body
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	// An email starts with a header block that names the usual headers.
+	header := head
+	if end := bytes.Index(head, []byte("\n\r\n")); end >= 0 {
+		header = head[:end]
+	} else if end := bytes.Index(head, []byte("\n\n")); end >= 0 {
+		header = head[:end]
+	}
+	if firstHeader.Match(head) && headerSniff.Match(header) {
+		return FormatEmail
+	}
+	if htmlSniff.Match(head) {
+		return FormatHTML
+	}
+	return FormatText
+}
+
+// ParseFormat maps a format name or media type onto a format.
+func ParseFormat(s string) (string, error) {
+	s, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(s)), ";")
+	switch strings.TrimSpace(s) {
+	case FormatText, "txt", "text/plain":
+		return FormatText, nil
+	case FormatHTML, "htm", "text/html", "application/xhtml+xml":
+		return FormatHTML, nil
+	case FormatEmail, "email", "message/rfc822":
+		return FormatEmail, nil
+	}
+	return "", fmt.Errorf("unknown format %q, want text, html or eml", s)
+}
+
+// Text returns the searchable text of a document.
+func Text(r io.Reader, format string) (string, error) {
+	switch format {
+	case FormatHTML:
+		return HTMLText(r)
+	case FormatEmail:
+		return EmailText(r)
+	}
+	b, err := io.ReadAll(r)
+	return string(b), err
+}
+
+// HTMLText returns the visible text of an HTML document plus its link
+// targets, which often differ from the link text. Scripts and styles are
+// left out.
+func HTMLText(r io.Reader) (string, error) {
+	var b strings.Builder
+	z := html.NewTokenizer(r)
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return b.String(), err
+			}
+			return b.String(), nil
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "script", "style":
+				skip++
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "href", "src", "action", "title", "alt":
+					b.WriteByte(' ')
+					b.Write(val)
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style":
+				if skip > 0 {
+					skip--
+				}
+			}
+		}
+		// Keep words of adjacent elements apart.
+		b.WriteByte(' ')
+	}
+}
+
+// EmailText returns the indicator-bearing headers and the text and HTML
+// parts of an RFC 5322 message, including forwarded messages.
+func EmailText(r io.Reader) (string, error) {
+	var b strings.Builder
+	err := emailText(&b, r, 0)
+	return b.String(), err
+}
+
+func emailText(b *strings.Builder, r io.Reader, depth int) error {
+	msg, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("invalid email: %w", err)
+	}
+	dec := new(mime.WordDecoder)
+	for _, name := range emailHeaders {
+		for _, v := range msg.Header[name] {
+			if decoded, err := dec.DecodeHeader(v); err == nil {
+				v = decoded
+			}
+			b.WriteString(v)
+			b.WriteByte('\n')
+		}
+	}
+	return partText(b, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, depth)
+}
+
+func partText(b *strings.Builder, contentType, encoding string, body io.Reader, depth int) error {
+	if depth > maxMIMEDepth {
+		return errors.New("email nested too deeply")
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = partText(b, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	case mediaType == "message/rfc822":
+		return emailText(b, body, depth+1)
+	case mediaType == "text/html":
+		text, err := HTMLText(body)
+		b.WriteString(text)
+		b.WriteByte('\n')
+		return err
+	case strings.HasPrefix(mediaType, "text/"):
+		text, err := io.ReadAll(body)
+		b.Write(text)
+		b.WriteByte('\n')
+		return err
+	}
+	// Attachments other than text are not searched.
+	_, err = io.Copy(io.Discard, body)
+	return err
+}
@@ -0,0 +1,339 @@
+package ioc
+
+import (
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TypeEmail is extracted but, unlike the other types, can't be analyzed.
+const TypeEmail = "email"
+
+var (
+	urlPattern    = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"'\x60{}|\\^]+`)
+	emailPattern  = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}\b`)
+	ipv4Pattern   = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+	ipv6Pattern   = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`)
+	hashCandidate = regexp.MustCompile(`\b[0-9a-fA-F]{32,128}\b`)
+	domainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+(?:[a-z]{2,63}|xn--[a-z0-9-]{1,59})\b`)
+
+	// refangPatterns undo the usual ways reports defang indicators.
+	refangPatterns = []struct {
+		pattern *regexp.Regexp
+		repl    string
+	}{
+		{regexp.MustCompile(`(?i)\bh(?:xx|XX|\*\*|__)p(s?)(\[?:\]?)?(\[?/\]?){0,2}`), "http$1://"},
+		{regexp.MustCompile(`(?i)\bfxp(\[?:\]?)(\[?/\]?){0,2}`), "ftp://"},
+		{regexp.MustCompile(`(?i)\[:\]//|\[://\]`), "://"},
+		{regexp.MustCompile(`(?i)\s?[\[({]\s?(?:\.|dot)\s?[\])}]\s?`), "."},
+		{regexp.MustCompile(`\\\.`), "."},
+		{regexp.MustCompile(`(?i)\s?[\[({]\s?(?:@|at)\s?[\])}]\s?`), "@"},
+		{regexp.MustCompile(`\[:\]`), ":"},
+		{regexp.MustCompile(`\[/\]`), "/"},
+	}
+)
+
+// fileExtensions are suffixes of file names that look like domains, e.g.
+// invoice.pdf or setup.exe. Some are real TLDs, but in reports they are
+// almost always file names.
+var fileExtensions = map[string]bool{
+	"exe": true, "dll": true, "sys": true, "bat": true, "cmd": true, "ps1": true, "vbs": true,
+	"js": true, "jse": true, "hta": true, "lnk": true, "scr": true, "msi": true, "jar": true,
+	"py": true, "sh": true, "pl": true, "rb": true, "php": true, "asp": true, "aspx": true,
+	"jsp": true, "doc": true, "docx": true, "docm": true, "xls": true, "xlsx": true, "xlsm": true,
+	"ppt": true, "pptx": true, "pdf": true, "rtf": true, "txt": true, "log": true, "csv": true,
+	"xml": true, "json": true, "yml": true, "yaml": true, "ini": true, "cfg": true, "conf": true,
+	"zip": true, "rar": true, "7z": true, "gz": true, "tar": true, "iso": true, "img": true,
+	"png": true, "jpg": true, "jpeg": true, "gif": true, "bmp": true, "svg": true, "ico": true,
+	"htm": true, "html": true, "css": true, "md": true, "tmp": true, "dat": true, "bin": true,
+	"go": true, "c": true, "h": true, "cpp": true, "cs": true, "java": true, "class": true,
+	"apk": true, "dmg": true, "pkg": true, "deb": true, "rpm": true, "elf": true, "so": true,
+	"eml": true, "msg": true, "one": true, "wsf": true, "inf": true, "reg": true, "db": true,
+}
+
+// benignDomains are reference and infrastructure domains that reports cite
+// without them being indicators. Subdomains are covered too. URLs on them
+// are kept, since a specific URL can still host something malicious.
+var benignDomains = []string{
+	"example.com", "example.net", "example.org",
+	"google.com", "googleapis.com", "gstatic.com", "youtube.com",
+	"microsoft.com", "windows.com", "windowsupdate.com", "office.com", "live.com", "bing.com",
+	"apple.com", "icloud.com", "amazon.com", "amazonaws.com", "cloudflare.com",
+	"akamai.net", "akamaiedge.net", "facebook.com", "twitter.com", "x.com", "linkedin.com",
+	"github.com", "githubusercontent.com", "gitlab.com", "wikipedia.org", "w3.org",
+	"schema.org", "mozilla.org", "virustotal.com", "mitre.org", "otx.alienvault.com",
+	"abuseipdb.com", "misp-project.org", "oasis-open.org",
+}
+
+// internalSuffixes mark names that only resolve inside an organisation.
+var internalSuffixes = []string{".local", ".localhost", ".internal", ".intranet", ".lan", ".corp", ".home.arpa", ".test", ".invalid", ".example"}
+
+// reservedPrefixes are non-routable or documentation ranges besides those
+// netip already classifies.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Match is one extracted indicator with how often it occurred.
+type Match struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+type ExtractOptions struct {
+	// KeepFalsePositives disables filtering of private addresses, benign
+	// domains and file names.
+	KeepFalsePositives bool
+}
+
+// Extraction is the result of Extract.
+type Extraction struct {
+	Matches []Match
+	// Filtered counts the distinct values dropped as false positives.
+	Filtered int
+}
+
+// Refang restores defanged indicators such as hxxp://evil[.]com.
+func Refang(text string) string {
+	for _, r := range refangPatterns {
+		text = r.pattern.ReplaceAllString(text, r.repl)
+	}
+	return text
+}
+
+// Extract finds the IPs, domains, URLs, hashes and email addresses in
+// text, after refanging it. URLs and addresses are taken out before
+// looking for domains and IPs, so their hosts don't show up twice.
+// Matches are ordered by type, then value.
+func Extract(text string, opts ExtractOptions) *Extraction {
+	text = Refang(text)
+	ex := &extractor{opts: opts, seen: map[string]*Match{}, filtered: map[string]bool{}}
+
+	text = ex.take(text, urlPattern, func(v string) (string, string, bool) {
+		v = trimURL(v)
+		return v, TypeURL, IsURL(v) && publicHost(hostOf(v))
+	})
+	text = ex.take(text, emailPattern, func(v string) (string, string, bool) {
+		_, domain, _ := strings.Cut(strings.ToLower(v), "@")
+		return v, TypeEmail, !isInternal(domain)
+	})
+	text = ex.take(text, ipv4Pattern, func(v string) (string, string, bool) {
+		return v, TypeIP, isPublicIP(v)
+	})
+	text = ex.take(text, ipv6Pattern, func(v string) (string, string, bool) {
+		addr, err := netip.ParseAddr(v)
+		if err != nil || !addr.Is6() || addr.Is4In6() {
+			return "", "", false
+		}
+		return addr.String(), TypeIP, isPublicIP(v)
+	})
+	text = ex.take(text, hashCandidate, func(v string) (string, string, bool) {
+		if !IsHash(v) {
+			return "", "", false
+		}
+		v = strings.ToLower(v)
+		return v, TypeHash, !trivialHash(v)
+	})
+	ex.take(text, domainPattern, func(v string) (string, string, bool) {
+		v = strings.ToLower(v)
+		if !IsDomain(v) {
+			return "", "", false
+		}
+		labels := strings.Split(v, ".")
+		if fileExtensions[labels[len(labels)-1]] {
+			return v, TypeDomain, false
+		}
+		return v, TypeDomain, !isInternal(v) && !isBenign(v)
+	})
+
+	matches := make([]Match, 0, len(ex.seen))
+	for _, m := range ex.seen {
+		matches = append(matches, *m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Type != matches[j].Type {
+			return matches[i].Type < matches[j].Type
+		}
+		return matches[i].Value < matches[j].Value
+	})
+	return &Extraction{Matches: matches, Filtered: len(ex.filtered)}
+}
+
+type extractor struct {
+	opts     ExtractOptions
+	seen     map[string]*Match
+	filtered map[string]bool
+}
+
+// take records the pattern's matches that check accepts, where check
+// returns the normalised value, its type and whether it is a true
+// positive. It returns text with the matches blanked out.
+func (ex *extractor) take(text string, pattern *regexp.Regexp, check func(string) (string, string, bool)) string {
+	locs := pattern.FindAllStringIndex(text, -1)
+	if len(locs) == 0 {
+		return text
+	}
+	var b strings.Builder
+	prev := 0
+	for _, loc := range locs {
+		// A dotted quad inside a longer dotted run is a version number.
+		if pattern == ipv4Pattern && versionNumber(text, loc[0], loc[1]) {
+			continue
+		}
+		value, typ, ok := check(text[loc[0]:loc[1]])
+		if typ == "" {
+			continue
+		}
+		b.WriteString(text[prev:loc[0]])
+		b.WriteString(strings.Repeat(" ", loc[1]-loc[0]))
+		prev = loc[1]
+
+		key := typ + "|" + value
+		if !ok && !ex.opts.KeepFalsePositives {
+			ex.filtered[key] = true
+			continue
+		}
+		if m := ex.seen[key]; m != nil {
+			m.Count++
+		} else {
+			ex.seen[key] = &Match{Value: value, Type: typ, Count: 1}
+		}
+	}
+	b.WriteString(text[prev:])
+	return b.String()
+}
+
+// versionNumber reports whether the dotted quad at text[start:end] is
+// part of a longer dotted run of digits, such as 10.0.19041.1.
+func versionNumber(text string, start, end int) bool {
+	if start >= 2 && text[start-1] == '.' && isDigit(text[start-2]) {
+		return true
+	}
+	return end+1 < len(text) && text[end] == '.' && isDigit(text[end+1])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// trimURL drops punctuation that ends the sentence rather than the URL, and
+// a closing bracket without an opening one.
+func trimURL(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"),
+			last == ']' && strings.Count(u, "[") < strings.Count(u, "]"):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
+
+func hostOf(u string) string {
+	_, rest, _ := strings.Cut(u, "://")
+	rest, _, _ = strings.Cut(rest, "/")
+	rest, _, _ = strings.Cut(rest, "?")
+	rest, _, _ = strings.Cut(rest, "#")
+	if i := strings.LastIndexByte(rest, '@'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	if strings.HasPrefix(rest, "[") {
+		rest, _, _ = strings.Cut(rest[1:], "]")
+		return rest
+	}
+	rest, _, _ = strings.Cut(rest, ":")
+	return strings.ToLower(rest)
+}
+
+// Public reports whether an indicator names something on the public
+// internet rather than a private address or internal host name.
+func Public(indicatorType, value string) bool {
+	switch indicatorType {
+	case TypeIP:
+		return isPublicIP(value)
+	case TypeDomain:
+		return publicHost(strings.ToLower(value))
+	case TypeURL:
+		return publicHost(hostOf(value))
+	}
+	return true
+}
+
+func publicHost(host string) bool {
+	if IsIP(host) {
+		return isPublicIP(host)
+	}
+	return !isInternal(host)
+}
+
+func isPublicIP(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInternal(domain string) bool {
+	domain = strings.TrimSuffix(domain, ".")
+	if !strings.Contains(domain, ".") {
+		return true
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(domain, suffix) {
+			return true
+		}
+	}
+	return isDocumentationDomain(domain)
+}
+
+func isDocumentationDomain(domain string) bool {
+	for _, d := range []string{"example.com", "example.net", "example.org"} {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBenign(domain string) bool {
+	for _, d := range benignDomains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// trivialHash catches hex runs that are padding or counters, not digests.
+func trivialHash(h string) bool {
+	if strings.Trim(h, h[:1]) == "" {
+		return true
+	}
+	return strings.Trim(h, "0123456789") == ""
+}
@@ -0,0 +1,113 @@
+// Package ioc recognises indicators of compromise.
+package ioc
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Indicator types, as stored on analyses.
+const (
+	TypeIP     = "ip"
+	TypeDomain = "domain"
+	TypeURL    = "url"
+	TypeHash   = "hash"
+)
+
+var (
+	hashPattern  = regexp.MustCompile(`^(?:[0-9a-fA-F]{32}|[0-9a-fA-F]{40}|[0-9a-fA-F]{64}|[0-9a-fA-F]{128})$`)
+	labelPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+	tldPattern   = regexp.MustCompile(`^(?:[a-z]{2,63}|xn--[a-z0-9-]{1,59})$`)
+)
+
+// Detect returns the type of an indicator, or "" when it is none of them.
+func Detect(value string) string {
+	switch {
+	case IsIP(value):
+		return TypeIP
+	case IsHash(value):
+		return TypeHash
+	case IsURL(value):
+		return TypeURL
+	case IsDomain(value):
+		return TypeDomain
+	}
+	return ""
+}
+
+// Valid reports whether value is an indicator of the given type.
+func Valid(indicatorType, value string) bool {
+	switch indicatorType {
+	case TypeIP:
+		return IsIP(value)
+	case TypeDomain:
+		return IsDomain(value)
+	case TypeURL:
+		return IsURL(value)
+	case TypeHash:
+		return IsHash(value)
+	}
+	return false
+}
+
+func IsIP(value string) bool {
+	return net.ParseIP(value) != nil
+}
+
+// IsHash accepts MD5, SHA-1, SHA-256 and SHA-512 hex digests.
+func IsHash(value string) bool {
+	return hashPattern.MatchString(value)
+}
+
+// IsURL accepts absolute URLs with a host.
+func IsURL(value string) bool {
+	if !strings.Contains(value, "://") || strings.ContainsAny(value, " \t\r\n") {
+		return false
+	}
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && u.Hostname() != ""
+}
+
+// IsDomain accepts host names of at least two labels with an alphabetic or
+// punycode top-level label.
+func IsDomain(value string) bool {
+	value = strings.TrimSuffix(strings.ToLower(value), ".")
+	if len(value) > 253 {
+		return false
+	}
+	labels := strings.Split(value, ".")
+	if len(labels) < 2 || !tldPattern.MatchString(labels[len(labels)-1]) {
+		return false
+	}
+	for _, label := range labels {
+		if !labelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParentDomains lists the domains above a domain name, nearest first, down
+// to its registrable domain: a.b.example.co.uk gives b.example.co.uk and
+// example.co.uk. Registrable domains and public suffixes have none.
+func ParentDomains(domain string) []string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return nil
+	}
+	var parents []string
+	for domain != registrable {
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		parents = append(parents, parent)
+		domain = parent
+	}
+	return parents
+}
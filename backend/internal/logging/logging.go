@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	mu       sync.Mutex
+	base     = slog.LevelInfo
+	levels   = map[string]*slog.LevelVar{}
+	defaults = map[string]slog.Level{}
+)
+
+// Setup configures JSON logging. level is the default level ("debug", "info",
+// "warn", "error"); components optionally overrides it per component as a
+// comma-separated list such as "providers=debug,webhook=warn".
+func Setup(level, components string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	overrides := map[string]slog.Level{}
+	for _, pair := range strings.Split(components, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid component log level %q", pair)
+		}
+		componentLevel, err := parseLevel(value)
+		if err != nil {
+			return err
+		}
+		overrides[strings.TrimSpace(name)] = componentLevel
+	}
+
+	mu.Lock()
+	base = lvl
+	defaults = overrides
+	for name, v := range levels {
+		v.Set(levelForLocked(name))
+	}
+	mu.Unlock()
+
+	slog.SetDefault(For("server"))
+	return nil
+}
+
+// For returns a logger tagged with the component name and filtered at that
+// component's configured level.
+func For(component string) *slog.Logger {
+	mu.Lock()
+	v, ok := levels[component]
+	if !ok {
+		v = new(slog.LevelVar)
+		v.Set(levelForLocked(component))
+		levels[component] = v
+	}
+	mu.Unlock()
+
+	h := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       v,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&contextHandler{Handler: h}).With("component", component)
+}
+
+func levelForLocked(component string) slog.Level {
+	if lvl, ok := defaults[component]; ok {
+		return lvl
+	}
+	return base
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var lvl slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := lvl.UnmarshalText([]byte(s)); err != nil {
+		return lvl, fmt.Errorf("invalid log level %q", s)
+	}
+	return lvl, nil
+}
+
+// contextHandler adds the request and trace IDs carried by the context to
+// every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
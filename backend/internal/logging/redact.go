@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are header names, query parameters and log attribute keys
+// whose values must never reach the logs. Matching is case-insensitive.
+var sensitiveKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"x-apikey":            true,
+	"x-otx-api-key":       true,
+	"key":                 true,
+	"apikey":              true,
+	"api_key":             true,
+	"token":               true,
+	"access_token":        true,
+	"password":            true,
+	"secret":              true,
+}
+
+func isSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// redactAttr is the slog ReplaceAttr hook that blanks sensitive attributes.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// RedactHeaders returns a flat copy of h with sensitive values replaced.
+func RedactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if isSensitive(name) {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// RedactURL returns u as a string with sensitive query parameters replaced.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	q := u.Query()
+	changed := false
+	for name := range q {
+		if isSensitive(name) {
+			q.Set(name, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	clone := *u
+	clone.RawQuery = q.Encode()
+	return clone.String()
+}
+
+// Request returns a log attribute describing req with credentials removed.
+func Request(req *http.Request) slog.Attr {
+	return slog.Group("http",
+		slog.String("method", req.Method),
+		slog.String("url", RedactURL(req.URL)),
+		slog.Any("headers", RedactHeaders(req.Header)),
+	)
+}
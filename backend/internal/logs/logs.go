@@ -0,0 +1,150 @@
+// Package logs reads the network indicators observed in Zeek, Suricata and
+// syslog logs.
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+)
+
+// Formats. FormatAuto tells them apart line by line.
+const (
+	FormatAuto     = "auto"
+	FormatZeek     = "zeek"
+	FormatSuricata = "suricata"
+	FormatSyslog   = "syslog"
+)
+
+// maxContext caps the raw line kept with an observation.
+const maxContext = 1024
+
+// Observation is an IP, domain, URL or hash seen in a log line.
+type Observation struct {
+	Value string
+	Type  string
+	Time  time.Time
+	// Source names the log, e.g. zeek:dns or suricata:http.
+	Source string
+	// Host is the other end: the client for a server-side indicator.
+	Host    string
+	Context string
+}
+
+// Parser turns log lines into observations. Zeek TSV logs are stateful:
+// the #fields header applies to the lines after it.
+type Parser struct {
+	format string
+	zeek   *zeekHeader
+	now    func() time.Time
+}
+
+func NewParser(format string) (*Parser, error) {
+	switch format {
+	case "", FormatAuto:
+		format = FormatAuto
+	case FormatZeek, FormatSuricata, FormatSyslog:
+	case "eve":
+		format = FormatSuricata
+	default:
+		return nil, fmt.Errorf("unknown log format %q, want zeek, suricata, syslog or auto", format)
+	}
+	return &Parser{format: format, now: time.Now}, nil
+}
+
+// Line returns the observations in one line. Blank lines and Zeek
+// directives yield none.
+func (p *Parser) Line(line string) ([]Observation, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return nil, nil
+	}
+
+	var obs []Observation
+	var err error
+	switch {
+	case strings.HasPrefix(line, "#") && p.format != FormatSyslog && p.format != FormatSuricata:
+		err = p.zeekDirective(line)
+	case strings.HasPrefix(line, "{") && p.format != FormatSyslog:
+		var rec map[string]interface{}
+		if err = json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, err
+		}
+		if _, ok := rec["event_type"]; ok || p.format == FormatSuricata {
+			obs, err = suricataObservations(rec)
+		} else {
+			obs, err = zeekJSONObservations(rec)
+		}
+	case p.format == FormatZeek || (p.format == FormatAuto && p.zeek.matches(line)):
+		obs, err = p.zeekTSVObservations(line)
+	case p.format == FormatAuto || p.format == FormatSyslog:
+		obs = syslogObservations(line, p.now())
+	default:
+		err = fmt.Errorf("not a %s record", p.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	context := truncate(line, maxContext)
+	for i := range obs {
+		obs[i].Context = context
+	}
+	return obs, nil
+}
+
+// observer collects a record's observations, skipping values that aren't
+// indicators and duplicates within the record.
+type observer struct {
+	obs    []Observation
+	time   time.Time
+	source string
+}
+
+func (o *observer) add(value, indicatorType, host string) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "-" {
+		return
+	}
+	if indicatorType == "" {
+		indicatorType = ioc.Detect(value)
+	}
+	if indicatorType == ioc.TypeDomain {
+		value = strings.TrimSuffix(strings.ToLower(value), ".")
+	}
+	if !ioc.Valid(indicatorType, value) {
+		return
+	}
+	for _, ob := range o.obs {
+		if ob.Value == value {
+			return
+		}
+	}
+	o.obs = append(o.obs, Observation{Value: value, Type: indicatorType, Time: o.time, Source: o.source, Host: host})
+}
+
+// hostURL joins an HTTP host and request target, which may already be
+// absolute.
+func hostURL(host, target string) string {
+	if strings.Contains(target, "://") {
+		return target
+	}
+	if host == "" || host == "-" || !strings.HasPrefix(target, "/") {
+		return ""
+	}
+	return "http://" + host + target
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,83 @@
+package logs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+)
+
+// eveTime is the timestamp layout of Suricata's eve.json.
+const eveTime = "2006-01-02T15:04:05.999999-0700"
+
+// suricataObservations reads an eve.json record: its endpoints, DNS
+// names and answers (eve v1 to v3), HTTP host and URL, TLS SNI and
+// file hashes.
+func suricataObservations(rec map[string]interface{}) ([]Observation, error) {
+	var ts time.Time
+	if v := str(rec, "timestamp"); v != "" {
+		var err error
+		if ts, err = time.Parse(eveTime, v); err != nil {
+			if ts, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q", v)
+			}
+		}
+	}
+
+	eventType := str(rec, "event_type")
+	if eventType == "" {
+		return nil, fmt.Errorf("not an eve.json record")
+	}
+	o := &observer{time: ts, source: "suricata:" + eventType}
+	src, dest := str(rec, "src_ip"), str(rec, "dest_ip")
+
+	if dns := obj(rec, "dns"); dns != nil {
+		o.add(str(dns, "rrname"), ioc.TypeDomain, src)
+		if str(dns, "type") == "answer" {
+			o.add(str(dns, "rdata"), "", src)
+		}
+		for _, q := range list(dns, "queries") {
+			o.add(str(q, "rrname"), ioc.TypeDomain, src)
+		}
+		for _, a := range list(dns, "answers") {
+			o.add(str(a, "rdata"), "", src)
+		}
+	}
+	if http := obj(rec, "http"); http != nil {
+		host := str(http, "hostname")
+		o.add(hostURL(host, str(http, "url")), ioc.TypeURL, src)
+		o.add(host, "", src)
+	}
+	if tls := obj(rec, "tls"); tls != nil {
+		o.add(str(tls, "sni"), ioc.TypeDomain, src)
+	}
+	if file := obj(rec, "fileinfo"); file != nil {
+		for _, field := range []string{"md5", "sha1", "sha256"} {
+			o.add(str(file, field), ioc.TypeHash, dest)
+		}
+	}
+	o.add(dest, ioc.TypeIP, src)
+	o.add(src, ioc.TypeIP, dest)
+	return o.obs, nil
+}
+
+func str(rec map[string]interface{}, key string) string {
+	s, _ := rec[key].(string)
+	return s
+}
+
+func obj(rec map[string]interface{}, key string) map[string]interface{} {
+	m, _ := rec[key].(map[string]interface{})
+	return m
+}
+
+func list(rec map[string]interface{}, key string) []map[string]interface{} {
+	items, _ := rec[key].([]interface{})
+	var out []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package logs
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+)
+
+var (
+	// RFC 5424: <PRI>1 TIMESTAMP HOST APP PROCID MSGID SD MSG
+	rfc5424 = regexp.MustCompile(`^(?:<\d{1,3}>)?1 (\S+) (\S+) (\S+) \S+ \S+ (?:-|(?:\[.*?\])+) ?(.*)$`)
+	// RFC 3164: <PRI>Mmm dd hh:mm:ss HOST TAG: MSG
+	rfc3164 = regexp.MustCompile(`^(?:<\d{1,3}>)?([A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d) (\S+) ([^:\s\[]+)(?:\[\d+\])?: ?(.*)$`)
+)
+
+// syslogObservations extracts indicators from a syslog message. The
+// header, when it parses, gives the time and host; otherwise the whole
+// line is the message and it is taken to be seen now.
+func syslogObservations(line string, now time.Time) []Observation {
+	ts, host, app, msg := now, "", "", line
+	if m := rfc5424.FindStringSubmatch(line); m != nil {
+		if parsed, err := time.Parse(time.RFC3339Nano, m[1]); err == nil {
+			ts = parsed
+		}
+		host, app, msg = nilValue(m[2]), nilValue(m[3]), m[4]
+	} else if m := rfc3164.FindStringSubmatch(line); m != nil {
+		// RFC 3164 has no year: take the latest one that isn't in the future.
+		if parsed, err := time.ParseInLocation(time.Stamp, m[1], now.Location()); err == nil {
+			ts = parsed.AddDate(now.Year(), 0, 0)
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+		}
+		host, app, msg = m[2], m[3], m[4]
+	}
+
+	source := "syslog"
+	if app != "" {
+		source += ":" + app
+	}
+	o := &observer{time: ts, source: source}
+	for _, match := range ioc.Extract(msg, ioc.ExtractOptions{}).Matches {
+		switch match.Type {
+		case ioc.TypeEmail:
+		case ioc.TypeURL:
+			o.add(match.Value, match.Type, host)
+			if u, err := url.Parse(match.Value); err == nil {
+				o.add(u.Hostname(), "", host)
+			}
+		default:
+			o.add(match.Value, match.Type, host)
+		}
+	}
+	return o.obs
+}
+
+// nilValue maps the RFC 5424 nil value "-" to empty.
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,166 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+)
+
+// zeekHeader is the state of a Zeek TSV log: its path and columns.
+type zeekHeader struct {
+	separator    string
+	setSeparator string
+	unset        string
+	empty        string
+	path         string
+	fields       []string
+}
+
+func (p *Parser) zeekDirective(line string) error {
+	if p.zeek == nil {
+		p.zeek = &zeekHeader{separator: "\t", setSeparator: ",", unset: "-", empty: "(empty)"}
+	}
+	h := p.zeek
+	if v, ok := strings.CutPrefix(line, "#separator "); ok {
+		sep, err := strconv.Unquote(`"` + strings.TrimSpace(v) + `"`)
+		if err != nil {
+			return fmt.Errorf("invalid #separator %q", v)
+		}
+		h.separator = sep
+		return nil
+	}
+
+	name, value, _ := strings.Cut(strings.TrimPrefix(line, "#"), h.separator)
+	switch name {
+	case "set_separator":
+		h.setSeparator = value
+	case "unset_field":
+		h.unset = value
+	case "empty_field":
+		h.empty = value
+	case "path":
+		h.path = value
+	case "fields":
+		h.fields = strings.Split(value, h.separator)
+	}
+	return nil
+}
+
+// matches reports whether a line has the columns of the current header.
+func (h *zeekHeader) matches(line string) bool {
+	return h != nil && len(h.fields) > 1 && strings.Count(line, h.separator) == len(h.fields)-1
+}
+
+func (p *Parser) zeekTSVObservations(line string) ([]Observation, error) {
+	h := p.zeek
+	if h == nil || len(h.fields) == 0 {
+		return nil, errors.New("zeek record before #fields header")
+	}
+	values := strings.Split(line, h.separator)
+	rec := make(map[string][]string, len(h.fields))
+	for i, field := range h.fields {
+		if i >= len(values) || values[i] == h.unset || values[i] == h.empty {
+			continue
+		}
+		rec[field] = strings.Split(values[i], h.setSeparator)
+	}
+
+	var ts time.Time
+	if v := first(rec["ts"]); v != "" {
+		secs, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ts %q", v)
+		}
+		ts = time.Unix(0, int64(secs*1e9)).UTC()
+	}
+	return zeekObservations(h.path, rec, ts), nil
+}
+
+func zeekJSONObservations(raw map[string]interface{}) ([]Observation, error) {
+	rec := make(map[string][]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			rec[k] = []string{v}
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					rec[k] = append(rec[k], s)
+				}
+			}
+		}
+	}
+
+	var ts time.Time
+	switch v := raw["ts"].(type) {
+	case float64:
+		ts = time.Unix(0, int64(v*1e9)).UTC()
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ts %q", v)
+		}
+		ts = parsed
+	}
+	path := first(rec["_path"])
+	return zeekObservations(path, rec, ts), nil
+}
+
+// zeekObservations reads conn, dns, http, ssl and files records. Without
+// a path, the log is recognised by its fields.
+func zeekObservations(path string, rec map[string][]string, ts time.Time) []Observation {
+	if path == "" {
+		switch {
+		case rec["query"] != nil:
+			path = "dns"
+		case rec["uri"] != nil || rec["method"] != nil:
+			path = "http"
+		case rec["server_name"] != nil:
+			path = "ssl"
+		case rec["fuid"] != nil && rec["uid"] == nil:
+			path = "files"
+		default:
+			path = "conn"
+		}
+	}
+
+	o := &observer{time: ts, source: "zeek:" + path}
+	orig, resp := first(rec["id.orig_h"]), first(rec["id.resp_h"])
+	switch path {
+	case "conn":
+		o.add(resp, ioc.TypeIP, orig)
+		o.add(orig, ioc.TypeIP, resp)
+	case "dns":
+		o.add(first(rec["query"]), ioc.TypeDomain, orig)
+		for _, answer := range rec["answers"] {
+			o.add(answer, "", orig)
+		}
+	case "http":
+		host := first(rec["host"])
+		o.add(hostURL(host, first(rec["uri"])), ioc.TypeURL, orig)
+		o.add(host, "", orig)
+		o.add(resp, ioc.TypeIP, orig)
+	case "ssl":
+		o.add(first(rec["server_name"]), ioc.TypeDomain, orig)
+		o.add(resp, ioc.TypeIP, orig)
+	case "files":
+		host := first(rec["rx_hosts"])
+		for _, field := range []string{"md5", "sha1", "sha256"} {
+			o.add(first(rec[field]), ioc.TypeHash, host)
+		}
+	default:
+		o.add(resp, ioc.TypeIP, orig)
+	}
+	return o.obs
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
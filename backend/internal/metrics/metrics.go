@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "atia"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	providerCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_requests_total",
+		Help:      "Upstream provider calls, by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	providerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Failed upstream provider calls, by provider and error class.",
+	}, []string{"provider", "class"})
+
+	providerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Upstream provider call latency.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30},
+	}, []string{"provider"})
+
+	providerQuota = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_quota_remaining",
+		Help:      "Remaining upstream quota as last reported by the provider.",
+	}, []string{"provider"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_cache_lookups_total",
+		Help:      "Provider response cache lookups, by provider and result (hit or miss).",
+	}, []string{"provider", "result"})
+
+	analysisScores = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "analysis_risk_score",
+		Help:      "Distribution of computed risk scores, by indicator type.",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10),
+	}, []string{"type"})
+
+	analysisVerdicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "analyses_total",
+		Help:      "Completed analyses, by indicator type and reputation.",
+	}, []string{"type", "reputation"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected with 429, by policy and bucket scope (key or ip).",
+	}, []string{"policy", "scope"})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Outbound webhook delivery attempts, by outcome.",
+	}, []string{"outcome"})
+
+	webhookDeadLetters = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_dead_letters_total",
+		Help:      "Webhook deliveries abandoned after their last attempt.",
+	})
+
+	eventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_published_total",
+		Help:      "Events published on the internal bus, by type.",
+	}, []string{"type"})
+
+	eventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Events a subscriber missed because its queue was full.",
+	}, []string{"subscriber"})
+
+	eventsBackpressure = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_backpressure_total",
+		Help:      "Publishes that had to wait for a full subscriber queue.",
+	}, []string{"subscriber"})
+)
+
+// Middleware records request counts and latency for every route. The route
+// label uses the registered path template so cardinality stays bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the Prometheus exposition format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// ObserveProviderCall records one upstream call. An empty errClass means the
+// call succeeded.
+func ObserveProviderCall(provider string, duration time.Duration, errClass string) {
+	providerDuration.WithLabelValues(provider).Observe(duration.Seconds())
+	if errClass == "" {
+		providerCalls.WithLabelValues(provider, "success").Inc()
+		return
+	}
+	providerCalls.WithLabelValues(provider, "error").Inc()
+	providerErrors.WithLabelValues(provider, errClass).Inc()
+}
+
+func SetProviderQuota(provider string, remaining float64) {
+	providerQuota.WithLabelValues(provider).Set(remaining)
+}
+
+func RecordCacheLookup(provider string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(provider, result).Inc()
+}
+
+func ObserveAnalysis(indicatorType string, riskScore float64, reputation string) {
+	analysisScores.WithLabelValues(indicatorType).Observe(riskScore)
+	analysisVerdicts.WithLabelValues(indicatorType, reputation).Inc()
+}
+
+func RecordRateLimited(policy, scope string) {
+	rateLimited.WithLabelValues(policy, scope).Inc()
+}
+
+func RecordWebhookDelivery(success bool) {
+	outcome := "failure"
+	if success {
+		outcome = "success"
+	}
+	webhookDeliveries.WithLabelValues(outcome).Inc()
+}
+
+func RecordWebhookDeadLetter() {
+	webhookDeadLetters.Inc()
+}
+
+func RecordEventPublished(eventType string) {
+	eventsPublished.WithLabelValues(eventType).Inc()
+}
+
+func RecordEventDropped(subscriber string) {
+	eventsDropped.WithLabelValues(subscriber).Inc()
+}
+
+func RecordEventBackpressure(subscriber string) {
+	eventsBackpressure.WithLabelValues(subscriber).Inc()
+}
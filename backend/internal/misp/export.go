@@ -0,0 +1,138 @@
+package misp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+
+	"github.com/google/uuid"
+)
+
+var ErrTLP = errors.New("misp: unknown TLP level")
+
+// tlpLevels are the TLP values MISP's tlp taxonomy knows, 1.0 and 2.0.
+var tlpLevels = map[string]bool{
+	"white": true, "clear": true, "green": true, "amber": true, "amber+strict": true, "red": true,
+}
+
+// atiaNamespace seeds event and attribute UUIDs, so a re-exported event
+// updates the copy a partner imported earlier instead of duplicating it.
+var atiaNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/AEX0TIC/ATIA/misp"))
+
+var atiaOrg = &Org{UUID: uuid.NewSHA1(atiaNamespace, []byte("org")).String(), Name: "ATIA"}
+
+// hashTypes maps hash algorithms onto MISP attribute types.
+var hashTypes = map[string]string{
+	"MD5":     "md5",
+	"SHA-1":   "sha1",
+	"SHA-256": "sha256",
+	"SHA-512": "sha512",
+}
+
+type ExportOptions struct {
+	TenantID string
+	Info     string
+	// TLP is the level for the event's tlp tag, e.g. amber.
+	TLP          string
+	Distribution int
+}
+
+// Export builds one event holding an attribute per analysis. The event's
+// UUID depends on the tenant and info, and each attribute's on the
+// indicator.
+func Export(threats []models.ThreatIndicator, opts ExportOptions) (*Wrapper, error) {
+	tlp := strings.ToLower(opts.TLP)
+	if !tlpLevels[tlp] {
+		return nil, fmt.Errorf("%w: %q", ErrTLP, opts.TLP)
+	}
+
+	event := Event{
+		UUID:          uuid.NewSHA1(atiaNamespace, []byte("event|"+opts.TenantID+"|"+opts.Info)).String(),
+		Info:          opts.Info,
+		Analysis:      AnalysisCompleted,
+		ThreatLevelID: ThreatLevelUndefined,
+		Distribution:  Int(opts.Distribution),
+		Orgc:          atiaOrg,
+		Tag:           []Tag{{Name: "tlp:" + tlp}},
+		Attribute:     []Attribute{},
+	}
+	for _, t := range threats {
+		attr, ok := attribute(&t, opts.TenantID)
+		if !ok {
+			continue
+		}
+		event.Attribute = append(event.Attribute, attr)
+
+		if attr.Timestamp > event.Timestamp {
+			event.Timestamp = attr.Timestamp
+			event.Date = t.LastUpdated.UTC().Format("2006-01-02")
+		}
+		switch {
+		case t.Reputation == "malicious":
+			event.ThreatLevelID = ThreatLevelHigh
+		case t.Reputation == "suspicious" && event.ThreatLevelID != ThreatLevelHigh:
+			event.ThreatLevelID = ThreatLevelMedium
+		}
+	}
+	// MISP rejects events without a date, so ones without timestamped
+	// attributes are dated now.
+	if event.Timestamp == 0 {
+		now := time.Now()
+		event.Timestamp = Int(now.Unix())
+		event.Date = now.UTC().Format("2006-01-02")
+	}
+	return &Wrapper{Event: event}, nil
+}
+
+func attribute(t *models.ThreatIndicator, tenantID string) (Attribute, bool) {
+	attr := Attribute{
+		UUID:         uuid.NewSHA1(atiaNamespace, []byte("attribute|"+tenantID+"|"+t.Indicator)).String(),
+		Value:        t.Indicator,
+		ToIDS:        t.Reputation == "malicious",
+		Distribution: DistributionInherit,
+		Category:     "Network activity",
+	}
+	if !t.LastUpdated.IsZero() {
+		attr.Timestamp = Int(t.LastUpdated.Unix())
+	}
+	switch t.Type {
+	case "ip":
+		attr.Type = "ip-dst"
+	case "domain":
+		attr.Type = "domain"
+		attr.Value = strings.ToLower(t.Indicator)
+	case "url":
+		attr.Type = "url"
+	case "hash":
+		attr.Value = strings.ToLower(t.Indicator)
+		algorithm, _ := stix.HashAlgorithm(attr.Value)
+		hashType, ok := hashTypes[algorithm]
+		if !ok {
+			return Attribute{}, false
+		}
+		attr.Type, attr.Category = hashType, "Payload delivery"
+	default:
+		return Attribute{}, false
+	}
+
+	var flagged []string
+	for _, s := range t.Sources {
+		if s.Verdict == "malicious" || s.Verdict == "suspicious" {
+			flagged = append(flagged, s.Name)
+		}
+	}
+	attr.Comment = fmt.Sprintf("ATIA risk score %.1f (%s)", t.RiskScore, t.Reputation)
+	if len(flagged) > 0 {
+		attr.Comment += ", flagged by " + strings.Join(flagged, ", ")
+	}
+	for _, tag := range t.Tags {
+		if tag != "" {
+			attr.Tag = append(attr.Tag, Tag{Name: tag})
+		}
+	}
+	return attr, true
+}
@@ -0,0 +1,274 @@
+package misp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNoEvents = errors.New("misp: no events in document")
+
+// maxEventSize caps one event document fetched from a feed.
+const maxEventSize = 32 << 20
+
+// Indicator is an observable taken from an event attribute.
+type Indicator struct {
+	Value    string
+	Type     string // ip, domain, url or hash
+	Category string
+	Tags     []string
+}
+
+var hashPattern = regexp.MustCompile(`^(?:[0-9a-fA-F]{32}|[0-9a-fA-F]{40}|[0-9a-fA-F]{64}|[0-9a-fA-F]{128})$`)
+
+// Parse reads events from a document in the event format: a single
+// {"Event": ...}, a bare event, an array of either, or the
+// {"response": [...]} envelope of the MISP search API.
+func Parse(body []byte) ([]Event, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, err
+		}
+		return parseItems(items)
+	}
+
+	var doc struct {
+		Event    *Event            `json:"Event"`
+		Response []json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	switch {
+	case doc.Event != nil:
+		return []Event{*doc.Event}, nil
+	case doc.Response != nil:
+		return parseItems(doc.Response)
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.UUID == "" && len(event.Attribute) == 0 && len(event.Object) == 0 {
+		return nil, ErrNoEvents
+	}
+	return []Event{event}, nil
+}
+
+func parseItems(items []json.RawMessage) ([]Event, error) {
+	events := make([]Event, 0, len(items))
+	for i := range items {
+		var item struct {
+			Event *Event `json:"Event"`
+		}
+		if err := json.Unmarshal(items[i], &item); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		if item.Event != nil {
+			events = append(events, *item.Event)
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(items[i], &event); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return nil, ErrNoEvents
+	}
+	return events, nil
+}
+
+// Indicators maps an event's attributes, including those inside objects,
+// onto indicators carrying the event's and attribute's tags. Attributes
+// of types ATIA can't analyze are counted as skipped.
+func Indicators(event Event) (indicators []Indicator, skipped int) {
+	var eventTags []string
+	for _, tag := range event.Tag {
+		eventTags = append(eventTags, tag.Name)
+	}
+
+	attrs := append([]Attribute(nil), event.Attribute...)
+	for _, obj := range event.Object {
+		attrs = append(attrs, obj.Attribute...)
+	}
+
+	seen := map[string]bool{}
+	for _, attr := range attrs {
+		values := attributeIndicators(attr)
+		if len(values) == 0 {
+			skipped++
+			continue
+		}
+		tags := append([]string(nil), eventTags...)
+		for _, tag := range attr.Tag {
+			if !containsString(tags, tag.Name) {
+				tags = append(tags, tag.Name)
+			}
+		}
+		for _, ind := range values {
+			if seen[ind.Value] {
+				continue
+			}
+			seen[ind.Value] = true
+			ind.Category = attr.Category
+			ind.Tags = tags
+			indicators = append(indicators, ind)
+		}
+	}
+	return indicators, skipped
+}
+
+// attributeIndicators handles the attribute types with an ATIA equivalent.
+// Composite values such as ip-dst|port or filename|sha256 split on "|".
+func attributeIndicators(attr Attribute) []Indicator {
+	value := strings.TrimSpace(attr.Value)
+	first, second, composite := strings.Cut(value, "|")
+
+	switch attr.Type {
+	case "ip-src", "ip-dst":
+		return ip(value)
+	case "ip-src|port", "ip-dst|port":
+		return ip(first)
+	case "domain", "hostname":
+		return domain(value)
+	case "domain|ip", "hostname|port":
+		if !composite {
+			return nil
+		}
+		if attr.Type == "hostname|port" {
+			return domain(first)
+		}
+		return append(domain(first), ip(second)...)
+	case "url", "uri", "link":
+		if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+			return []Indicator{{Value: value, Type: "url"}}
+		}
+	case "md5", "sha1", "sha256", "sha512":
+		return hash(value)
+	case "filename|md5", "filename|sha1", "filename|sha256", "filename|sha512":
+		if composite {
+			return hash(second)
+		}
+	}
+	return nil
+}
+
+func ip(value string) []Indicator {
+	if net.ParseIP(value) == nil {
+		return nil
+	}
+	return []Indicator{{Value: value, Type: "ip"}}
+}
+
+func domain(value string) []Indicator {
+	value = strings.TrimSuffix(strings.ToLower(value), ".")
+	if value == "" || !strings.Contains(value, ".") || strings.ContainsAny(value, " /:") {
+		return nil
+	}
+	return []Indicator{{Value: value, Type: "domain"}}
+}
+
+func hash(value string) []Indicator {
+	if !hashPattern.MatchString(value) {
+		return nil
+	}
+	return []Indicator{{Value: strings.ToLower(value), Type: "hash"}}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchOptions limits what FetchFeed reads.
+type FetchOptions struct {
+	// Since skips events whose manifest timestamp is older.
+	Since time.Time
+	// MaxEvents caps the events fetched, newest first.
+	MaxEvents int
+}
+
+// FetchFeed reads a MISP feed: manifest.json under feedURL, then the
+// <uuid>.json of each event it lists that passes opts. An event that can't
+// be fetched or parsed is reported in failed and the rest are still read;
+// err is set only when the manifest can't be read or ctx is done.
+func FetchFeed(ctx context.Context, client *http.Client, feedURL string, opts FetchOptions) (events []Event, failed []error, err error) {
+	base := strings.TrimSuffix(feedURL, "/manifest.json")
+	base = strings.TrimSuffix(base, "/") + "/"
+
+	var manifest map[string]ManifestEntry
+	if err := fetchJSON(ctx, client, base+"manifest.json", &manifest); err != nil {
+		return nil, nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	type entry struct {
+		uuid      string
+		timestamp int64
+	}
+	entries := make([]entry, 0, len(manifest))
+	for uuid, m := range manifest {
+		if !opts.Since.IsZero() && int64(m.Timestamp) < opts.Since.Unix() {
+			continue
+		}
+		entries = append(entries, entry{uuid: uuid, timestamp: int64(m.Timestamp)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].timestamp != entries[j].timestamp {
+			return entries[i].timestamp > entries[j].timestamp
+		}
+		return entries[i].uuid < entries[j].uuid
+	})
+	if opts.MaxEvents > 0 && len(entries) > opts.MaxEvents {
+		entries = entries[:opts.MaxEvents]
+	}
+
+	events = make([]Event, 0, len(entries))
+	for _, e := range entries {
+		var wrapper Wrapper
+		if err := fetchJSON(ctx, client, base+url.PathEscape(e.uuid)+".json", &wrapper); err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			failed = append(failed, fmt.Errorf("event %s: %w", e.uuid, err))
+			continue
+		}
+		events = append(events, wrapper.Event)
+	}
+	return events, failed, nil
+}
+
+func fetchJSON(ctx context.Context, client *http.Client, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxEventSize)).Decode(v)
+}
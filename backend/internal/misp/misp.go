@@ -0,0 +1,108 @@
+// Package misp converts between stored analyses and MISP events, and reads
+// events from MISP feeds.
+package misp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// Int is a MISP integer field. MISP writes most of them as strings, but
+// other producers use numbers, so both are accepted.
+type Int int64
+
+func (i *Int) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	b = bytes.Trim(b, `"`)
+	if len(b) == 0 {
+		*i = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = Int(n)
+	return nil
+}
+
+// MarshalJSON writes the string form MISP itself produces.
+func (i Int) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+// Distribution levels.
+const (
+	DistributionOrganisation = 0
+	DistributionCommunity    = 1
+	DistributionConnected    = 2
+	DistributionAll          = 3
+	DistributionInherit      = 5 // attributes only: use the event's
+)
+
+// Threat levels and analysis states.
+const (
+	ThreatLevelHigh      = 1
+	ThreatLevelMedium    = 2
+	ThreatLevelLow       = 3
+	ThreatLevelUndefined = 4
+
+	AnalysisCompleted = 2
+)
+
+// Wrapper is the {"Event": {...}} envelope of the event JSON format.
+type Wrapper struct {
+	Event Event `json:"Event"`
+}
+
+type Event struct {
+	UUID          string      `json:"uuid"`
+	Info          string      `json:"info"`
+	Date          string      `json:"date"`
+	Timestamp     Int         `json:"timestamp"`
+	Published     bool        `json:"published"`
+	Analysis      Int         `json:"analysis"`
+	ThreatLevelID Int         `json:"threat_level_id"`
+	Distribution  Int         `json:"distribution"`
+	Orgc          *Org        `json:"Orgc,omitempty"`
+	Tag           []Tag       `json:"Tag,omitempty"`
+	Attribute     []Attribute `json:"Attribute"`
+	Object        []Object    `json:"Object,omitempty"`
+}
+
+type Org struct {
+	UUID string `json:"uuid,omitempty"`
+	Name string `json:"name"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Attribute struct {
+	UUID         string `json:"uuid"`
+	Type         string `json:"type"`
+	Category     string `json:"category"`
+	Value        string `json:"value"`
+	ToIDS        bool   `json:"to_ids"`
+	Comment      string `json:"comment,omitempty"`
+	Timestamp    Int    `json:"timestamp"`
+	Distribution Int    `json:"distribution"`
+	Tag          []Tag  `json:"Tag,omitempty"`
+}
+
+// Object groups attributes, e.g. a file object with its hashes.
+type Object struct {
+	Name      string      `json:"name"`
+	Attribute []Attribute `json:"Attribute"`
+}
+
+// ManifestEntry describes one event in a feed's manifest.json, which maps
+// event UUIDs to entries.
+type ManifestEntry struct {
+	Info      string `json:"info"`
+	Timestamp Int    `json:"timestamp"`
+}
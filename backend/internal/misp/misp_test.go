@@ -0,0 +1,328 @@
+package misp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+const (
+	oldEventUUID = "5f8d2a1e-0000-4000-8000-000000000001"
+	newEventUUID = "5f8d2a1e-0000-4000-8000-000000000002"
+	midEventUUID = "5f8d2a1e-0000-4000-8000-000000000003"
+)
+
+// feedEvents are event documents as a MISP feed serves them, with the
+// string-typed integers MISP writes.
+var feedEvents = map[string]string{
+	oldEventUUID: `{"Event": {"uuid": "` + oldEventUUID + `", "info": "Old campaign", "date": "2024-01-01", "timestamp": "1704067200",
+		"Attribute": [{"uuid": "a1", "type": "ip-dst", "category": "Network activity", "value": "203.0.113.7", "timestamp": "1704067200"}]}}`,
+	midEventUUID: `{"Event": {"uuid": "` + midEventUUID + `", "info": "Phishing kit", "date": "2025-02-01", "timestamp": "1738368000",
+		"Tag": [{"name": "tlp:green"}],
+		"Attribute": [
+			{"uuid": "b1", "type": "url", "category": "Network activity", "value": "https://login.example.net/kit.php", "to_ids": true},
+			{"uuid": "b2", "type": "email-src", "category": "Payload delivery", "value": "phish@example.net"}
+		]}}`,
+	newEventUUID: `{"Event": {"uuid": "` + newEventUUID + `", "info": "Loader", "date": "2025-03-01", "timestamp": "1740787200",
+		"Tag": [{"name": "tlp:amber"}],
+		"Attribute": [
+			{"uuid": "c1", "type": "domain|ip", "category": "Network activity", "value": "Evil.Example.com|198.51.100.9",
+			 "Tag": [{"name": "malware:loader"}]},
+			{"uuid": "c2", "type": "ip-dst|port", "category": "Network activity", "value": "198.51.100.9|443"}
+		],
+		"Object": [{"name": "file", "Attribute": [
+			{"uuid": "c3", "type": "filename|sha256", "category": "Payload delivery",
+			 "value": "loader.exe|E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"},
+			{"uuid": "c4", "type": "filename", "category": "Payload delivery", "value": "loader.exe"}
+		]}]}}`,
+}
+
+// newFeed serves feedEvents and a manifest listing them, recording the
+// paths requested.
+func newFeed(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var paths []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		manifest := map[string]interface{}{}
+		for uuid, doc := range feedEvents {
+			events, err := Parse([]byte(doc))
+			if err != nil {
+				t.Errorf("feed event %s: %v", uuid, err)
+				continue
+			}
+			manifest[uuid] = map[string]string{
+				"info":      events[0].Info,
+				"timestamp": strings.Trim(string(mustJSON(t, events[0].Timestamp)), `"`),
+			}
+		}
+		_ = json.NewEncoder(w).Encode(manifest)
+	})
+	mux.HandleFunc("/feed/", func(w http.ResponseWriter, r *http.Request) {
+		uuid := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/feed/"), ".json")
+		doc, ok := feedEvents[uuid]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(paths)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFetchFeed(t *testing.T) {
+	srv, requested := newFeed(t)
+
+	events, failed, err := FetchFeed(context.Background(), srv.Client(), srv.URL+"/feed/manifest.json", FetchOptions{})
+	if err != nil || len(failed) != 0 {
+		t.Fatal(err, failed)
+	}
+	var uuids []string
+	for _, e := range events {
+		uuids = append(uuids, e.UUID)
+	}
+	if want := []string{newEventUUID, midEventUUID, oldEventUUID}; !slices.Equal(uuids, want) {
+		t.Errorf("events = %v, want newest first %v", uuids, want)
+	}
+	if events[0].Timestamp != 1740787200 {
+		t.Errorf("string timestamp read as %d", events[0].Timestamp)
+	}
+	if paths := requested(); len(paths) != 4 || paths[0] != "/feed/manifest.json" {
+		t.Errorf("requested %v", paths)
+	}
+}
+
+func TestFetchFeedOptions(t *testing.T) {
+	srv, requested := newFeed(t)
+
+	opts := FetchOptions{Since: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), MaxEvents: 1}
+	events, failed, err := FetchFeed(context.Background(), srv.Client(), srv.URL+"/feed/", opts)
+	if err != nil || len(failed) != 0 {
+		t.Fatal(err, failed)
+	}
+	if len(events) != 1 || events[0].UUID != newEventUUID {
+		t.Fatalf("got %d events, want only %s", len(events), newEventUUID)
+	}
+	if paths := requested(); len(paths) != 2 {
+		t.Errorf("requested %v, want the manifest and one event", paths)
+	}
+}
+
+func TestFetchFeedErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/manifest.json":
+			_, _ = w.Write([]byte(`{"missing-event": {"timestamp": "3"}, "broken-event": {"timestamp": "2"},
+				"` + oldEventUUID + `": {"timestamp": "1"}}`))
+		case strings.HasSuffix(r.URL.Path, "broken-event.json"):
+			_, _ = w.Write([]byte(`{"Event": `))
+		case strings.HasSuffix(r.URL.Path, oldEventUUID+".json"):
+			_, _ = w.Write([]byte(feedEvents[oldEventUUID]))
+		default:
+			http.Error(w, "gone", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	// One bad event doesn't cost the rest of the feed.
+	events, failed, err := FetchFeed(context.Background(), srv.Client(), srv.URL, FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].UUID != oldEventUUID {
+		t.Errorf("got %d events, want only %s", len(events), oldEventUUID)
+	}
+	if len(failed) != 2 || !strings.Contains(failed[0].Error(), "missing-event") || !strings.Contains(failed[1].Error(), "broken-event") {
+		t.Errorf("failed = %v, want the missing and the broken event", failed)
+	}
+
+	_, _, err = FetchFeed(context.Background(), srv.Client(), srv.URL+"/nofeed/x", FetchOptions{})
+	if err == nil {
+		t.Error("FetchFeed succeeded without a manifest")
+	}
+}
+
+func TestParse(t *testing.T) {
+	event := feedEvents[newEventUUID]
+	bare := `{"uuid": "bare", "Attribute": [{"type": "domain", "value": "a.example"}]}`
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{"wrapped", event, []string{newEventUUID}},
+		{"bare", bare, []string{"bare"}},
+		{"array", "[" + event + "," + bare + "]", []string{newEventUUID, "bare"}},
+		{"search response", `{"response": [` + feedEvents[oldEventUUID] + `,` + event + `]}`, []string{oldEventUUID, newEventUUID}},
+	}
+	for _, tt := range tests {
+		events, err := Parse([]byte(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var uuids []string
+		for _, e := range events {
+			uuids = append(uuids, e.UUID)
+		}
+		if !slices.Equal(uuids, tt.want) {
+			t.Errorf("%s: events %v, want %v", tt.name, uuids, tt.want)
+		}
+	}
+
+	for _, doc := range []string{`{}`, `[]`, `{"response": []}`} {
+		if _, err := Parse([]byte(doc)); !errors.Is(err, ErrNoEvents) {
+			t.Errorf("Parse(%s) error = %v, want ErrNoEvents", doc, err)
+		}
+	}
+	if _, err := Parse([]byte(`{"Event": `)); err == nil {
+		t.Error("Parse accepted truncated JSON")
+	}
+}
+
+func TestIndicators(t *testing.T) {
+	events, err := Parse([]byte(feedEvents[newEventUUID]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indicators, skipped := Indicators(events[0])
+
+	want := []Indicator{
+		{Value: "evil.example.com", Type: "domain", Category: "Network activity", Tags: []string{"tlp:amber", "malware:loader"}},
+		{Value: "198.51.100.9", Type: "ip", Category: "Network activity", Tags: []string{"tlp:amber", "malware:loader"}},
+		{Value: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Type: "hash", Category: "Payload delivery", Tags: []string{"tlp:amber"}},
+	}
+	if len(indicators) != len(want) {
+		t.Fatalf("got %d indicators %+v, want %d", len(indicators), indicators, len(want))
+	}
+	for i := range want {
+		got := indicators[i]
+		if got.Value != want[i].Value || got.Type != want[i].Type || got.Category != want[i].Category || !slices.Equal(got.Tags, want[i].Tags) {
+			t.Errorf("indicator %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	// The port attribute repeats the IP; the bare filename has no equivalent.
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	updated := time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC)
+	threats := []models.ThreatIndicator{
+		{Indicator: "198.51.100.9", Type: "ip", Reputation: "malicious", RiskScore: 88, LastUpdated: updated.Add(-time.Hour),
+			Sources: []models.SourceData{{Name: "VirusTotal", Verdict: "malicious"}}, Tags: []string{"botnet"}},
+		{Indicator: "Evil.Example.com", Type: "domain", Reputation: "suspicious", RiskScore: 45, LastUpdated: updated},
+		{Indicator: "https://login.example.net/kit.php", Type: "url", Reputation: "clean"},
+		{Indicator: "D41D8CD98F00B204E9800998ECF8427E", Type: "hash", Reputation: "malicious", RiskScore: 92},
+		{Indicator: "abc", Type: "hash"},
+	}
+	opts := ExportOptions{TenantID: "acme", Info: "ATIA export", TLP: "Amber", Distribution: DistributionCommunity}
+	wrapper, err := Export(threats, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := mustJSON(t, wrapper)
+	events, err := Parse(doc)
+	if err != nil {
+		t.Fatalf("Parse(Export): %v", err)
+	}
+	event := events[0]
+	if event.UUID != wrapper.Event.UUID || event.Info != "ATIA export" || event.Distribution != DistributionCommunity {
+		t.Errorf("event header %+v", event)
+	}
+	if event.ThreatLevelID != ThreatLevelHigh {
+		t.Errorf("threat level = %d, want high", event.ThreatLevelID)
+	}
+	if event.Date != "2025-03-02" || event.Timestamp != Int(updated.Unix()) {
+		t.Errorf("date %q timestamp %d, want the newest attribute's", event.Date, event.Timestamp)
+	}
+	if len(event.Tag) != 1 || event.Tag[0].Name != "tlp:amber" {
+		t.Errorf("tags = %v", event.Tag)
+	}
+
+	indicators, skipped := Indicators(event)
+	if skipped != 0 {
+		t.Errorf("skipped = %d, want 0", skipped)
+	}
+	var got []string
+	for _, ind := range indicators {
+		got = append(got, ind.Type+" "+ind.Value)
+	}
+	want := []string{
+		"ip 198.51.100.9",
+		"domain evil.example.com",
+		"url https://login.example.net/kit.php",
+		"hash d41d8cd98f00b204e9800998ecf8427e",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+	if !slices.Contains(indicators[0].Tags, "botnet") {
+		t.Errorf("attribute tags lost: %v", indicators[0].Tags)
+	}
+	if !event.Attribute[0].ToIDS || event.Attribute[1].ToIDS {
+		t.Error("to_ids should be set for malicious indicators only")
+	}
+
+	// Re-exporting yields the same UUIDs, so a partner's import updates.
+	again, err := Export(threats, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Event.UUID != event.UUID || again.Event.Attribute[0].UUID != event.Attribute[0].UUID {
+		t.Error("UUIDs changed between exports")
+	}
+}
+
+func TestExportDefaults(t *testing.T) {
+	wrapper, err := Export(nil, ExportOptions{Info: "empty", TLP: "clear"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := wrapper.Event
+	if _, err := time.Parse("2006-01-02", event.Date); err != nil {
+		t.Errorf("empty export date = %q: %v", event.Date, err)
+	}
+	if event.Timestamp == 0 {
+		t.Error("empty export has no timestamp")
+	}
+	if event.Attribute == nil {
+		t.Error("attributes marshal as null")
+	}
+
+	if _, err := Export(nil, ExportOptions{TLP: "purple"}); !errors.Is(err, ErrTLP) {
+		t.Errorf("unknown TLP error = %v, want ErrTLP", err)
+	}
+}
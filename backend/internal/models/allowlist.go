@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AllowlistEntry is a value that must never appear in generated blocklists:
+// an IP address, CIDR range, domain (covering its subdomains), URL or hash.
+type AllowlistEntry struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID string             `bson:"tenant_id" json:"tenant_id"`
+	Value    string             `bson:"value" json:"value"`
+	// Type is derived from Value: ip (addresses and ranges), domain, url or
+	// hash.
+	Type      string    `bson:"type" json:"type"`
+	Comment   string    `bson:"comment,omitempty" json:"comment,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type AllowlistRequest struct {
+	Value   string `json:"value" binding:"required"`
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Relationships between graph nodes, read as "From relation To".
+const (
+	RelationResolvesTo     = "resolves_to"     // domain -> IP
+	RelationContactedBy    = "contacted_by"    // IP, domain or URL -> file that contacted it
+	RelationRelatedPulse   = "related_pulse"   // indicator -> OTX pulse
+	RelationDownloadedFrom = "downloaded_from" // file -> URL
+)
+
+// NodePulse is the type of OTX pulse nodes. Other nodes are indicators
+// and have the indicator types.
+const NodePulse = "pulse"
+
+// GraphEdge is a relationship found in provider results, e.g. a domain's
+// DNS resolution in VirusTotal or an OTX pulse listing an indicator.
+type GraphEdge struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	TenantID string             `bson:"tenant_id" json:"-"`
+	From     string             `bson:"from" json:"from"`
+	FromType string             `bson:"from_type" json:"from_type"`
+	To       string             `bson:"to" json:"to"`
+	ToType   string             `bson:"to_type" json:"to_type"`
+	Relation string             `bson:"relation" json:"relation"`
+	// ToLabel names a pulse node.
+	ToLabel   string    `bson:"to_label,omitempty" json:"to_label,omitempty"`
+	Sources   []string  `bson:"sources" json:"sources"`
+	FirstSeen time.Time `bson:"first_seen" json:"first_seen"`
+	LastSeen  time.Time `bson:"last_seen" json:"last_seen"`
+}
+
+// GraphNode is an indicator or pulse in a graph. Reputation and RiskScore
+// are set for indicators the tenant has analyzed.
+type GraphNode struct {
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	Label      string   `json:"label,omitempty"`
+	Depth      int      `json:"depth"`
+	Reputation string   `json:"reputation,omitempty"`
+	RiskScore  *float64 `json:"risk_score,omitempty"`
+}
+
+// Graph is the neighbourhood of its roots up to Depth hops. Truncated is
+// set when nodes were left out to keep it within bounds.
+type Graph struct {
+	Roots     []string    `json:"roots"`
+	Depth     int         `json:"depth"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RPZState is the current version of a tenant's response policy zone.
+// Records are kept so the next version can be diffed for IXFR.
+type RPZState struct {
+	TenantID  string    `bson:"_id" json:"tenant_id"`
+	Serial    int64     `bson:"serial" json:"serial"`
+	Hash      string    `bson:"hash" json:"hash"`
+	Records   []string  `bson:"records" json:"-"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// RPZChange is the difference between two consecutive zone versions, in
+// zone file syntax.
+type RPZChange struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID   string             `bson:"tenant_id" json:"tenant_id"`
+	FromSerial int64              `bson:"from_serial" json:"from_serial"`
+	ToSerial   int64              `bson:"to_serial" json:"to_serial"`
+	Added      []string           `bson:"added" json:"added"`
+	Removed    []string           `bson:"removed" json:"removed"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sighting records that an indicator was observed in the tenant's
+// environment, e.g. in a DNS log, as opposed to when it was analyzed.
+type Sighting struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID      string             `bson:"tenant_id" json:"tenant_id"`
+	Indicator     string             `bson:"indicator" json:"indicator"`
+	IndicatorType string             `bson:"indicator_type" json:"indicator_type"`
+	// ObservedAt is the latest observation this record covers.
+	ObservedAt time.Time `bson:"observed_at" json:"observed_at"`
+	// Source is the system that observed it, e.g. zeek:dns or suricata.
+	Source string `bson:"source" json:"source"`
+	// Host is the host involved, usually the internal client.
+	Host  string `bson:"host,omitempty" json:"host,omitempty"`
+	Count int    `bson:"count" json:"count"`
+	// Context is the raw log line or other evidence, truncated.
+	Context   string    `bson:"context,omitempty" json:"context,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// SightingRequest reports one sighting. Type is detected when empty;
+// ObservedAt defaults to now and Count to 1.
+type SightingRequest struct {
+	Indicator  string    `json:"indicator" binding:"required"`
+	Type       string    `json:"type" binding:"omitempty,oneof=ip domain hash url"`
+	ObservedAt time.Time `json:"observed_at"`
+	Source     string    `json:"source" binding:"required"`
+	Host       string    `json:"host"`
+	Count      int       `json:"count" binding:"omitempty,min=1"`
+	Context    string    `json:"context"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// SavedFilter is a stored selection of analyses, e.g. malicious IPs updated
+// in the last 7 days. An empty filter matches everything.
+type SavedFilter struct {
+	Types       []string `bson:"types" json:"types" binding:"dive,oneof=ip domain hash url"`
+	Reputations []string `bson:"reputations" json:"reputations" binding:"dive,oneof=malicious suspicious unknown clean"`
+	Tags        []string `bson:"tags" json:"tags"` // matches analyses carrying any of these tags
+	MinScore    float64  `bson:"min_score" json:"min_score" binding:"min=0,max=100"`
+	// MaxAgeDays limits the filter to analyses updated in the last N days.
+	MaxAgeDays int `bson:"max_age_days,omitempty" json:"max_age_days,omitempty" binding:"min=0"`
+}
+
+// ThreatFilter resolves the filter at now.
+func (f SavedFilter) ThreatFilter(now time.Time) ThreatFilter {
+	filter := ThreatFilter{
+		Types:       f.Types,
+		Reputations: f.Reputations,
+		Tags:        f.Tags,
+		MinScore:    f.MinScore,
+	}
+	if f.MaxAgeDays > 0 {
+		filter.UpdatedSince = now.AddDate(0, 0, -f.MaxAgeDays)
+	}
+	return filter
+}
+
+// TAXIICollection is a read-only TAXII collection serving the analyses that
+// match its filter.
+type TAXIICollection struct {
+	ID          string      `bson:"_id" json:"id"` // a UUID, as TAXII requires
+	TenantID    string      `bson:"tenant_id" json:"tenant_id"`
+	Title       string      `bson:"title" json:"title"`
+	Description string      `bson:"description,omitempty" json:"description,omitempty"`
+	Filter      SavedFilter `bson:"filter" json:"filter"`
+	CreatedAt   time.Time   `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time   `bson:"updated_at" json:"updated_at"`
+}
+
+type TAXIICollectionRequest struct {
+	Title       string      `json:"title" binding:"required"`
+	Description string      `json:"description"`
+	Filter      SavedFilter `json:"filter"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// DefaultTenant owns records created before tenancy existed and requests made
+// with the bootstrap key or with authentication disabled. Its admins manage
+// the other tenants.
+const DefaultTenant = "default"
+
+type Tenant struct {
+	ID           string       `bson:"_id" json:"id"`
+	Name         string       `bson:"name" json:"name"`
+	ProviderKeys ProviderKeys `bson:"provider_keys" json:"-"`
+	// WebhookURL overrides the deployment-wide webhook for this tenant.
+	WebhookURL string `bson:"webhook_url,omitempty" json:"webhook_url,omitempty"`
+	// ShareVerdicts publishes verdicts on public indicators to the shared
+	// pool and lets the tenant read verdicts other sharing tenants published.
+	ShareVerdicts bool      `bson:"share_verdicts" json:"share_verdicts"`
+	CreatedAt     time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// ProviderKeys overrides the deployment-wide provider API keys. Empty values
+// fall back to the global key.
+type ProviderKeys struct {
+	VirusTotal string `bson:"virustotal,omitempty" json:"virustotal,omitempty"`
+	OTX        string `bson:"otx,omitempty" json:"otx,omitempty"`
+	AbuseIPDB  string `bson:"abuseipdb,omitempty" json:"abuseipdb,omitempty"`
+}
+
+// ConfiguredProviders lists which providers have a tenant-specific key, so
+// admins can see the setup without the keys being echoed back.
+func (t *Tenant) ConfiguredProviders() []string {
+	providers := []string{}
+	if t.ProviderKeys.VirusTotal != "" {
+		providers = append(providers, "virustotal")
+	}
+	if t.ProviderKeys.OTX != "" {
+		providers = append(providers, "otx")
+	}
+	if t.ProviderKeys.AbuseIPDB != "" {
+		providers = append(providers, "abuseipdb")
+	}
+	return providers
+}
+
+type TenantRequest struct {
+	ID            string        `json:"id"`
+	Name          string        `json:"name" binding:"required"`
+	ProviderKeys  *ProviderKeys `json:"provider_keys"` // nil leaves stored keys unchanged
+	WebhookURL    string        `json:"webhook_url" binding:"omitempty,url"`
+	ShareVerdicts bool          `json:"share_verdicts"`
+}
+
+type TenantResponse struct {
+	*Tenant
+	ProviderKeys []string `json:"provider_keys"`
+}
+
+// SharedVerdict is the cross-tenant view of an indicator: scores and verdicts
+// only, never tags, notes, metadata or raw provider details.
+type SharedVerdict struct {
+	Indicator   string         `bson:"_id" json:"indicator"`
+	Type        string         `bson:"type" json:"type"`
+	RiskScore   float64        `bson:"risk_score" json:"risk_score"`
+	Reputation  string         `bson:"reputation" json:"reputation"`
+	Sources     []SharedSource `bson:"sources" json:"sources"`
+	Reports     int64          `bson:"reports" json:"reports"`
+	LastUpdated time.Time      `bson:"last_updated" json:"last_updated"`
+}
+
+type SharedSource struct {
+	Name      string    `bson:"name" json:"name"`
+	Verdict   string    `bson:"verdict" json:"verdict"`
+	Score     float64   `bson:"score" json:"score"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// NewSharedVerdict strips a threat down to what may be shared.
+func NewSharedVerdict(threat *ThreatIndicator) *SharedVerdict {
+	sources := make([]SharedSource, 0, len(threat.Sources))
+	for _, s := range threat.Sources {
+		sources = append(sources, SharedSource{
+			Name:      s.Name,
+			Verdict:   s.Verdict,
+			Score:     s.Score,
+			Timestamp: s.Timestamp,
+		})
+	}
+	return &SharedVerdict{
+		Indicator:   threat.Indicator,
+		Type:        threat.Type,
+		RiskScore:   threat.RiskScore,
+		Reputation:  threat.Reputation,
+		Sources:     sources,
+		LastUpdated: threat.LastUpdated,
+	}
+}
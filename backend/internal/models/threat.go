@@ -8,6 +8,7 @@ import (
 
 type ThreatIndicator struct {
 	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	TenantID    string                 `bson:"tenant_id" json:"tenant_id"`
 	Indicator   string                 `bson:"indicator" json:"indicator"`
 	Type        string                 `bson:"type" json:"type"` // "ip", "domain", "hash", "url"
 	RiskScore   float64                `bson:"risk_score" json:"risk_score"`
@@ -17,6 +18,42 @@ type ThreatIndicator struct {
 	FirstSeen   time.Time              `bson:"first_seen" json:"first_seen"`
 	LastUpdated time.Time              `bson:"last_updated" json:"last_updated"`
 	Tags        []string               `bson:"tags" json:"tags"`
+	Notes       string                 `bson:"notes,omitempty" json:"notes,omitempty"` // analyst notes, kept across re-analysis
+	// SightingCount and LastSighted summarise the indicator's sightings.
+	// They are only written when sightings are recorded, so re-analysis
+	// leaves them alone.
+	SightingCount int        `bson:"sighting_count,omitempty" json:"sighting_count"`
+	LastSighted   *time.Time `bson:"last_sighted,omitempty" json:"last_sighted,omitempty"`
+	// STIXIDs are the IDs of the analysis' STIX objects, so TAXII can find
+	// an object's analysis.
+	STIXIDs []string `bson:"stix_ids,omitempty" json:"-"`
+}
+
+// MetadataImportedTags is the metadata key holding tags that came with an
+// imported indicator. They are kept in Tags across re-analysis.
+const MetadataImportedTags = "imported_tags"
+
+// ImportedTags reads the imported tags from a threat's metadata, which holds
+// []string when set in memory and bson.A after a round trip through Mongo.
+func ImportedTags(metadata map[string]interface{}) []string {
+	var tags []string
+	switch v := metadata[MetadataImportedTags].(type) {
+	case []string:
+		tags = v
+	case []interface{}:
+		for _, tag := range v {
+			if s, ok := tag.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	case primitive.A:
+		for _, tag := range v {
+			if s, ok := tag.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+	return tags
 }
 
 type SourceData struct {
@@ -28,8 +65,8 @@ type SourceData struct {
 }
 
 type AnalysisRequest struct {
-	Indicator string `json:"indicator" binding:"required"`
-	Type      string `json:"type" binding:"required"`
+	Indicator string `json:"indicator" form:"indicator" binding:"required"`
+	Type      string `json:"type" form:"type" binding:"required"`
 }
 
 type AnalysisResponse struct {
@@ -38,7 +75,23 @@ type AnalysisResponse struct {
 	Error   string           `json:"error,omitempty"`
 }
 
+type NotesRequest struct {
+	Notes string `json:"notes"`
+}
+
 type HistoricalData struct {
 	Indicator string            `json:"indicator"`
 	History   []ThreatIndicator `json:"history"`
 }
+
+// ThreatFilter selects stored analyses for exports and feeds. Zero fields
+// match everything.
+type ThreatFilter struct {
+	Types       []string
+	Reputations []string
+	Tags        []string
+	MinScore    float64
+	// UpdatedSince matches analyses updated at or after this time.
+	UpdatedSince time.Time
+	Limit        int64
+}
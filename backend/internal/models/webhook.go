@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook event types.
+const (
+	EventThreatAnalyzed    = "threat_analyzed"
+	EventReputationChanged = "reputation_changed"
+	EventIndicatorSighted  = "indicator_sighted"
+)
+
+// Webhook payload formats.
+const (
+	FormatJSON      = "json" // WebhookPayload, the default
+	FormatSlack     = "slack"
+	FormatTeams     = "teams"
+	FormatPagerDuty = "pagerduty"
+	FormatTemplate  = "template"
+)
+
+type WebhookSubscription struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID string             `bson:"tenant_id" json:"tenant_id"`
+	Name     string             `bson:"name" json:"name"`
+	URL      string             `bson:"url" json:"url"`
+	// Filters; an empty filter matches everything.
+	EventTypes     []string `bson:"event_types" json:"event_types"`
+	MinSeverity    string   `bson:"min_severity,omitempty" json:"min_severity,omitempty"`
+	IndicatorTypes []string `bson:"indicator_types" json:"indicator_types"`
+	Tags           []string `bson:"tags" json:"tags"` // matches threats carrying any of these tags
+	// Headers are added to every delivery, e.g. an auth token for the receiver.
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+	// Format selects the payload shape. Template (with ContentType) is used
+	// by FormatTemplate and RoutingKey by FormatPagerDuty.
+	Format      string `bson:"format,omitempty" json:"format,omitempty"`
+	Template    string `bson:"template,omitempty" json:"template,omitempty"`
+	ContentType string `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	RoutingKey  string `bson:"routing_key,omitempty" json:"routing_key,omitempty"`
+	// Secret signs deliveries (see X-ATIA-Signature). It is only returned
+	// when the subscription is created or the secret rotated.
+	Secret    string    `bson:"secret" json:"secret,omitempty"`
+	Enabled   bool      `bson:"enabled" json:"enabled"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Redacted returns a copy safe to return from the API: the secret is removed
+// and header values and the routing key are masked since they usually carry
+// credentials.
+func (s WebhookSubscription) Redacted() WebhookSubscription {
+	s.Secret = ""
+	if s.RoutingKey != "" {
+		s.RoutingKey = "[REDACTED]"
+	}
+	if len(s.Headers) > 0 {
+		masked := make(map[string]string, len(s.Headers))
+		for name := range s.Headers {
+			masked[name] = "[REDACTED]"
+		}
+		s.Headers = masked
+	}
+	return s
+}
+
+type WebhookRequest struct {
+	Name           string            `json:"name" binding:"required"`
+	URL            string            `json:"url" binding:"required,url"`
+	EventTypes     []string          `json:"event_types" binding:"dive,oneof=threat_analyzed reputation_changed indicator_sighted"`
+	MinSeverity    string            `json:"min_severity" binding:"omitempty,oneof=low medium high critical"`
+	IndicatorTypes []string          `json:"indicator_types" binding:"dive,oneof=ip domain hash url"`
+	Tags           []string          `json:"tags"`
+	Headers        map[string]string `json:"headers"` // nil keeps stored headers on update
+	Format         string            `json:"format" binding:"omitempty,oneof=json slack teams pagerduty template"`
+	Template       string            `json:"template"`
+	ContentType    string            `json:"content_type"`
+	RoutingKey     string            `json:"routing_key"` // empty keeps the stored key on update
+	Enabled        *bool             `json:"enabled"`     // defaults to true
+}
+
+// Webhook delivery states.
+const (
+	DeliveryPending    = "pending"
+	DeliveryInProgress = "delivering"
+	DeliveryDelivered  = "delivered"
+	DeliveryDead       = "dead" // gave up after the last attempt
+)
+
+// WebhookDelivery is one event queued for one subscription. The payload is
+// stored as sent so retries and replays carry identical bodies.
+type WebhookDelivery struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID string             `bson:"tenant_id" json:"tenant_id"`
+	// SubscriptionID is zero for the tenant's default webhook URL.
+	SubscriptionID   primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	SubscriptionName string             `bson:"subscription_name" json:"subscription_name"`
+	URL              string             `bson:"url" json:"url"`
+	EventType        string             `bson:"event_type" json:"event_type"`
+	ContentType      string             `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	Payload          string             `bson:"payload" json:"payload"`
+	RequestID        string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Status           string             `bson:"status" json:"status"`
+	Attempts         int                `bson:"attempts" json:"attempts"`
+	MaxAttempts      int                `bson:"max_attempts" json:"max_attempts"`
+	NextAttemptAt    time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	// LockedUntil is the lease of the worker currently delivering; a crashed
+	// worker's delivery is picked up again once it passes.
+	LockedUntil time.Time         `bson:"locked_until,omitempty" json:"-"`
+	LastError   string            `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Log         []DeliveryAttempt `bson:"log" json:"log"` // most recent attempts
+	CreatedAt   time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time         `bson:"updated_at" json:"updated_at"`
+	DeliveredAt *time.Time        `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
+
+type DeliveryAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMS int64     `bson:"duration_ms" json:"duration_ms"`
+}
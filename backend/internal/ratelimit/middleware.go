@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/auth"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+var limitLog = logging.For("ratelimit")
+
+// Policy is the budget for one class of endpoints, applied separately to each
+// API key and each client IP.
+type Policy struct {
+	Name   string
+	PerKey Rate
+	PerIP  Rate
+}
+
+// NewPolicy builds a policy from two ParseRate strings.
+func NewPolicy(name, perKey, perIP string) (Policy, error) {
+	keyRate, err := ParseRate(perKey)
+	if err != nil {
+		return Policy{}, err
+	}
+	ipRate, err := ParseRate(perIP)
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{Name: name, PerKey: keyRate, PerIP: ipRate}, nil
+}
+
+// Policies are the budgets applied to the API's route groups.
+type Policies struct {
+	Analyze Policy
+	Read    Policy
+}
+
+type Limiter struct {
+	store Store
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// IPMiddleware enforces the policy's per-IP budget. It runs before
+// authentication so that requests with missing or bad keys are counted too.
+func (l *Limiter) IPMiddleware(p Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l.enforce(c, p, "ip")
+	}
+}
+
+// KeyMiddleware enforces the policy's per-key budget. It must run after
+// authentication so the API key is known.
+func (l *Limiter) KeyMiddleware(p Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l.enforce(c, p, "key")
+	}
+}
+
+// tightestKey holds the Result behind the X-RateLimit-* headers already set.
+const tightestKey = "ratelimit.tightest"
+
+// enforce counts the request against one bucket of the policy. Responses
+// carry X-RateLimit-* headers for the tightest bucket the request went
+// through; an exhausted bucket gets 429 with Retry-After. Store errors fail
+// open so a database hiccup doesn't take the API down.
+func (l *Limiter) enforce(c *gin.Context, p Policy, scope string) {
+	ctx := c.Request.Context()
+	now := time.Now()
+
+	key, rate, ok := callerBucket(c, p, scope)
+	if !ok {
+		c.Next()
+		return
+	}
+	res, err := Take(ctx, l.store, key, rate, now)
+	if err != nil {
+		limitLog.WarnContext(ctx, "rate limit store unavailable", "policy", p.Name, "error", err)
+		c.Next()
+		return
+	}
+
+	resetIn := int64(math.Ceil(res.Reset.Sub(now).Seconds()))
+	if prev, ok := c.Get(tightestKey); !ok || res.Remaining < prev.(Result).Remaining {
+		c.Set(tightestKey, res)
+		h := c.Writer.Header()
+		h.Set("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+		h.Set("X-RateLimit-Remaining", strconv.FormatInt(max(res.Remaining, 0), 10))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(resetIn, 10))
+	}
+
+	if !res.Allowed() {
+		metrics.RecordRateLimited(p.Name, scope)
+		c.Header("Retry-After", strconv.FormatInt(resetIn, 10))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
+
+	c.Next()
+}
+
+// callerBucket returns the counter key and rate of the caller's bucket in
+// scope (ip or key), or false when the policy has no such budget or the
+// caller no key.
+func callerBucket(c *gin.Context, p Policy, scope string) (string, Rate, bool) {
+	switch scope {
+	case "ip":
+		return "ip:" + p.Name + ":" + c.ClientIP(), p.PerIP, p.PerIP.Enabled()
+	case "key":
+		principal := auth.PrincipalFrom(c)
+		if principal == nil || principal.KeyID == "" {
+			return "", Rate{}, false
+		}
+		return "key:" + p.Name + ":" + principal.KeyID, p.PerKey, p.PerKey.Enabled()
+	}
+	return "", Rate{}, false
+}
+
+// Allow counts one more request of the policy against the caller, for work
+// a request queues beyond itself, and reports whether it fits the budget.
+// Store errors fail open.
+func (l *Limiter) Allow(c *gin.Context, p Policy) bool {
+	ctx := c.Request.Context()
+	now := time.Now()
+	for _, scope := range []string{"ip", "key"} {
+		key, rate, ok := callerBucket(c, p, scope)
+		if !ok {
+			continue
+		}
+		res, err := Take(ctx, l.store, key, rate, now)
+		if err != nil {
+			limitLog.WarnContext(ctx, "rate limit store unavailable", "policy", p.Name, "error", err)
+			continue
+		}
+		if !res.Allowed() {
+			metrics.RecordRateLimited(p.Name, scope)
+			return false
+		}
+	}
+	return true
+}
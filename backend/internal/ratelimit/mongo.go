@@ -0,0 +1,21 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/database"
+)
+
+// MongoStore shares counters through MongoDB so limits hold across replicas.
+type MongoStore struct {
+	db *database.MongoDB
+}
+
+func NewMongoStore(db *database.MongoDB) *MongoStore {
+	return &MongoStore{db: db}
+}
+
+func (s *MongoStore) Incr(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, error) {
+	return s.db.IncrementRateLimit(ctx, key, windowStart, window)
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is a budget of Limit requests per Window. The zero Rate is unlimited.
+type Rate struct {
+	Limit  int64
+	Window time.Duration
+}
+
+func (r Rate) Enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// ParseRate parses "N/window", e.g. "30/1m" or "1000/1h". An empty string
+// or "0" means unlimited.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Rate{}, nil
+	}
+
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q: want N/window", s)
+	}
+	limit, err := strconv.ParseInt(count, 10, 64)
+	if err != nil || limit < 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: bad count", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: bad window", s)
+	}
+	return Rate{Limit: limit, Window: d}, nil
+}
+
+// Result describes the state of one bucket after a request was counted.
+type Result struct {
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+func (r Result) Allowed() bool {
+	return r.Remaining >= 0
+}
+
+// Store counts requests in fixed windows. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Incr counts one request against key in the window containing now and
+	// returns the total so far in that window.
+	Incr(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, error)
+}
+
+// Take counts one request against key and reports the bucket state.
+func Take(ctx context.Context, store Store, key string, rate Rate, now time.Time) (Result, error) {
+	start := now.Truncate(rate.Window)
+	count, err := store.Incr(ctx, key, start, rate.Window)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Limit:     rate.Limit,
+		Remaining: rate.Limit - count,
+		Reset:     start.Add(rate.Window),
+	}, nil
+}
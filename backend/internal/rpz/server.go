@@ -0,0 +1,441 @@
+package rpz
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// cacheTTL is how long a built zone answers queries before it is
+	// rebuilt; it also batches the rebuilds that trigger NOTIFY.
+	cacheTTL = 10 * time.Second
+	// chunkSize keeps each transfer message well under 64KiB.
+	chunkSize = 100
+	// maxVersions bounds the cached zones and remembered serials.
+	maxVersions = 1024
+)
+
+type ServerOptions struct {
+	Addr string
+	// AllowTransfer lists the prefixes that may query and transfer zones.
+	AllowTransfer []string
+	// TSIGKey is "name:base64secret" (hmac-sha256) for the default
+	// tenant's zone. When set, its transfers must be signed with it.
+	TSIGKey string
+	// TenantKeys are "tenant=name:base64secret" entries. Another tenant's
+	// zone is served only to requests signed with that tenant's key; a
+	// tenant without one gets its zone from /export/rpz only.
+	TenantKeys []string
+	// Notify lists host:port secondaries sent a NOTIFY when a zone changes.
+	Notify []string
+}
+
+// Server answers SOA queries and AXFR/IXFR requests for the policy zones.
+type Server struct {
+	zones  *Zones
+	opts   ServerOptions
+	allow  []netip.Prefix
+	keys   map[string]string // tenant ID to TSIG key name
+	secret map[string]string // key name to secret
+
+	udp, tcp *dns.Server
+
+	mu      sync.Mutex
+	cache   map[string]cachedZone
+	dirty   map[string]bool
+	serials map[string]uint32
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type cachedZone struct {
+	zone  *Zone
+	built time.Time
+}
+
+func NewServer(zones *Zones, opts ServerOptions) (*Server, error) {
+	if len(opts.AllowTransfer) == 0 {
+		opts.AllowTransfer = []string{"127.0.0.0/8", "::1/128"}
+	}
+	s := &Server{
+		zones:   zones,
+		opts:    opts,
+		cache:   make(map[string]cachedZone),
+		dirty:   make(map[string]bool),
+		serials: make(map[string]uint32),
+		keys:    make(map[string]string),
+		secret:  make(map[string]string),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, cidr := range opts.AllowTransfer {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RPZ transfer ACL entry %q: %w", cidr, err)
+		}
+		s.allow = append(s.allow, prefix.Masked())
+	}
+	if opts.TSIGKey != "" {
+		if err := s.addKey(models.DefaultTenant, opts.TSIGKey); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range opts.TenantKeys {
+		tenantID, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || tenantID == "" {
+			return nil, fmt.Errorf("invalid RPZ tenant key %q, want tenant=name:base64secret", entry)
+		}
+		if err := s.addKey(tenantID, key); err != nil {
+			return nil, err
+		}
+	}
+	for _, target := range opts.Notify {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return nil, fmt.Errorf("invalid RPZ notify target %q: %w", target, err)
+		}
+	}
+	return s, nil
+}
+
+// addKey binds a "name:base64secret" TSIG key to the tenant whose zone it
+// may transfer.
+func (s *Server) addKey(tenantID, key string) error {
+	name, secret, ok := strings.Cut(key, ":")
+	if !ok || name == "" {
+		return errors.New("RPZ TSIG key must be name:base64secret")
+	}
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		return fmt.Errorf("RPZ TSIG secret is not base64: %w", err)
+	}
+	name = dns.Fqdn(strings.ToLower(name))
+	if _, dup := s.secret[name]; dup {
+		return fmt.Errorf("RPZ TSIG key %s is configured twice", name)
+	}
+	if _, dup := s.keys[tenantID]; dup {
+		return fmt.Errorf("RPZ tenant %s has more than one TSIG key", tenantID)
+	}
+	s.keys[tenantID] = name
+	s.secret[name] = secret
+	return nil
+}
+
+// Start listens on UDP and TCP and returns once both are bound.
+func (s *Server) Start() error {
+	pc, err := net.ListenPacket("udp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	s.udp = &dns.Server{PacketConn: pc, Handler: s, TsigSecret: s.secret}
+	s.tcp = &dns.Server{Listener: ln, Handler: s, TsigSecret: s.secret}
+	for _, srv := range []*dns.Server{s.udp, s.tcp} {
+		go func(srv *dns.Server) {
+			if err := srv.ActivateAndServe(); err != nil {
+				rpzLog.Error("DNS listener stopped", "error", err)
+			}
+		}(srv)
+	}
+	go s.notifyLoop()
+	return nil
+}
+
+// Addr is the bound TCP address, useful when listening on port 0.
+func (s *Server) Addr() net.Addr {
+	return s.tcp.Listener.Addr()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.stop)
+	<-s.done
+	return errors.Join(s.udp.ShutdownContext(ctx), s.tcp.ShutdownContext(ctx))
+}
+
+// Subscribe marks zones for a rebuild, and their secondaries for a NOTIFY,
+// when domain verdicts change.
+func (s *Server) Subscribe(bus *events.Bus) {
+	bus.Subscribe("rpz", events.SubscribeOptions{
+		Types:        []string{events.AnalysisCompleted, events.ReputationChanged, events.IndicatorDeleted},
+		DropWhenFull: true,
+	}, func(e events.Event) {
+		if e.IndicatorType != "domain" {
+			return
+		}
+		s.mu.Lock()
+		s.dirty[e.TenantID] = true
+		s.mu.Unlock()
+	})
+}
+
+func (s *Server) zone(ctx context.Context, tenantID string) (*Zone, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[tenantID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.built) < cacheTTL {
+		return cached.zone, nil
+	}
+
+	zone, err := s.zones.Current(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if len(s.cache) >= maxVersions {
+		clear(s.cache)
+	}
+	s.cache[tenantID] = cachedZone{zone: zone, built: time.Now()}
+	s.mu.Unlock()
+	return zone, nil
+}
+
+func (s *Server) notifyLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(cacheTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		dirty := s.dirty
+		s.dirty = make(map[string]bool)
+		s.mu.Unlock()
+
+		for tenantID := range dirty {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			zone, err := s.zones.Current(ctx, tenantID)
+			cancel()
+			if err != nil {
+				rpzLog.Error("failed to rebuild zone", "tenant", tenantID, "error", err)
+				continue
+			}
+
+			s.mu.Lock()
+			s.cache[tenantID] = cachedZone{zone: zone, built: time.Now()}
+			changed := s.serials[tenantID] != zone.Serial
+			if len(s.serials) >= maxVersions {
+				clear(s.serials)
+			}
+			s.serials[tenantID] = zone.Serial
+			s.mu.Unlock()
+
+			if changed {
+				s.notify(tenantID, zone)
+			}
+		}
+	}
+}
+
+// notify tells the secondaries about zones they can transfer, signed with
+// the owning tenant's key.
+func (s *Server) notify(tenantID string, zone *Zone) {
+	keyName, keyed := s.keys[tenantID]
+	if !keyed && tenantID != models.DefaultTenant {
+		return
+	}
+	for _, target := range s.opts.Notify {
+		m := new(dns.Msg)
+		m.SetNotify(zone.Origin)
+		m.Answer = []dns.RR{zone.SOA()}
+		c := &dns.Client{Net: "udp", Timeout: 5 * time.Second, TsigSecret: s.secret}
+		if keyed {
+			m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		if _, _, err := c.Exchange(m, target); err != nil {
+			rpzLog.Warn("NOTIFY failed", "zone", zone.Origin, "target", target, "error", err)
+			continue
+		}
+		rpzLog.Debug("NOTIFY sent", "zone", zone.Origin, "serial", zone.Serial, "target", target)
+	}
+}
+
+func (s *Server) allowed(addr net.Addr) bool {
+	var ip netip.Addr
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip, _ = netip.AddrFromSlice(a.IP)
+	case *net.TCPAddr:
+		ip, _ = netip.AddrFromSlice(a.IP)
+	}
+	ip = ip.Unmap()
+	for _, prefix := range s.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	q := r.Question[0]
+	_, isTCP := w.RemoteAddr().(*net.TCPAddr)
+	reply := func(rcode int, answer ...dns.RR) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		m.Authoritative = rcode == dns.RcodeSuccess
+		m.Answer = answer
+		if tsig := r.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+			m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+		}
+		_ = w.WriteMsg(m)
+	}
+
+	if !s.allowed(w.RemoteAddr()) || r.Opcode != dns.OpcodeQuery {
+		reply(dns.RcodeRefused)
+		return
+	}
+
+	tenantID, err := s.zones.Tenant(ctx, q.Name)
+	if err != nil {
+		if errors.Is(err, ErrUnknownZone) {
+			reply(dns.RcodeRefused)
+		} else {
+			reply(dns.RcodeServerFailure)
+		}
+		return
+	}
+	// The transfer ACL is shared, so only the tenant's own key opens its
+	// zone; the default zone is open to the ACL when it has no key.
+	if keyName, keyed := s.keys[tenantID]; keyed {
+		tsig := r.IsTsig()
+		if tsig == nil || tsig.Hdr.Name != keyName || w.TsigStatus() != nil {
+			rpzLog.Warn("unsigned or badly signed request", "remote", w.RemoteAddr().String(), "zone", q.Name)
+			reply(dns.RcodeNotAuth)
+			return
+		}
+	} else if tenantID != models.DefaultTenant {
+		reply(dns.RcodeRefused)
+		return
+	}
+	zone, err := s.zone(ctx, tenantID)
+	if err != nil {
+		rpzLog.Error("failed to build zone", "zone", q.Name, "error", err)
+		reply(dns.RcodeServerFailure)
+		return
+	}
+
+	switch q.Qtype {
+	case dns.TypeSOA:
+		reply(dns.RcodeSuccess, zone.SOA())
+	case dns.TypeNS:
+		reply(dns.RcodeSuccess, zone.NS())
+	case dns.TypeAXFR:
+		if !isTCP {
+			reply(dns.RcodeRefused)
+			return
+		}
+		s.transfer(w, r, axfr(zone))
+	case dns.TypeIXFR:
+		var client uint32
+		if len(r.Ns) == 1 {
+			if soa, ok := r.Ns[0].(*dns.SOA); ok {
+				client = soa.Serial
+			}
+		}
+		// Up-to-date clients, and IXFR over UDP, get just the current SOA;
+		// the client then retries over TCP (RFC 1995, section 2).
+		if client == zone.Serial || !isTCP {
+			reply(dns.RcodeSuccess, zone.SOA())
+			return
+		}
+		rrs, err := s.ixfr(ctx, tenantID, client, zone)
+		if err != nil {
+			rpzLog.Error("failed to read zone journal", "zone", q.Name, "error", err)
+			reply(dns.RcodeServerFailure)
+			return
+		}
+		s.transfer(w, r, rrs)
+	default:
+		reply(dns.RcodeRefused)
+	}
+}
+
+func (s *Server) transfer(w dns.ResponseWriter, r *dns.Msg, rrs []dns.RR) {
+	ch := make(chan *dns.Envelope, len(rrs)/chunkSize+1)
+	for len(rrs) > 0 {
+		n := min(chunkSize, len(rrs))
+		ch <- &dns.Envelope{RR: rrs[:n]}
+		rrs = rrs[n:]
+	}
+	close(ch)
+	tr := new(dns.Transfer)
+	if err := tr.Out(w, r, ch); err != nil {
+		rpzLog.Warn("zone transfer failed", "remote", w.RemoteAddr().String(), "zone", r.Question[0].Name, "error", err)
+	}
+	w.Close()
+}
+
+func axfr(zone *Zone) []dns.RR {
+	soa := zone.SOA()
+	rrs := make([]dns.RR, 0, len(zone.Records)+3)
+	rrs = append(rrs, soa, zone.NS())
+	rrs = append(rrs, zone.Records...)
+	return append(rrs, soa)
+}
+
+// ixfr builds the incremental transfer from the client's serial, falling
+// back to a full transfer when the journal doesn't cover it.
+func (s *Server) ixfr(ctx context.Context, tenantID string, serial uint32, zone *Zone) ([]dns.RR, error) {
+	changes, ok, err := s.zones.Changes(ctx, tenantID, serial, zone)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return axfr(zone), nil
+	}
+
+	soaAt := func(serial int64) dns.RR {
+		soa := zone.SOA()
+		soa.Serial = uint32(serial)
+		return soa
+	}
+	rrs := []dns.RR{zone.SOA()}
+	for _, c := range changes {
+		removed, err := parseRecords(c.Removed)
+		if err != nil {
+			return nil, err
+		}
+		added, err := parseRecords(c.Added)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, soaAt(c.FromSerial))
+		rrs = append(rrs, removed...)
+		rrs = append(rrs, soaAt(c.ToSerial))
+		rrs = append(rrs, added...)
+	}
+	return append(rrs, zone.SOA()), nil
+}
+
+func parseRecords(lines []string) ([]dns.RR, error) {
+	rrs := make([]dns.RR, 0, len(lines))
+	for _, line := range lines {
+		rr, err := dns.NewRR(line)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
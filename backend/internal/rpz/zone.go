@@ -0,0 +1,154 @@
+// Package rpz builds DNS Response Policy Zones from stored domain verdicts
+// and serves them to resolvers over zone transfer.
+package rpz
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+// Policy actions.
+const (
+	ActionNXDOMAIN = "nxdomain"
+	ActionNODATA   = "nodata"
+	ActionSinkhole = "sinkhole" // CNAME to Options.Sinkhole
+	ActionNone     = "none"     // leave the reputation out of the zone
+)
+
+type Options struct {
+	// Zone is the origin, e.g. rpz.atia.local. Tenants other than the
+	// default one get <tenant>.<Zone>.
+	Zone string
+	// Action applies to malicious domains and SuspiciousAction to
+	// suspicious ones.
+	Action           string
+	SuspiciousAction string
+	Sinkhole         string
+	TTL              uint32
+	// Wildcard adds *.<domain> so subdomains are blocked too.
+	Wildcard bool
+}
+
+func (o *Options) validate() error {
+	if _, ok := dns.IsDomainName(o.Zone); !ok || o.Zone == "" {
+		return fmt.Errorf("invalid RPZ zone %q", o.Zone)
+	}
+	for _, action := range []string{o.Action, o.SuspiciousAction} {
+		switch action {
+		case ActionNXDOMAIN, ActionNODATA, ActionNone:
+		case ActionSinkhole:
+			if _, ok := dns.IsDomainName(o.Sinkhole); !ok || o.Sinkhole == "" {
+				return fmt.Errorf("the sinkhole action needs a sinkhole domain, got %q", o.Sinkhole)
+			}
+		default:
+			return fmt.Errorf("unknown RPZ action %q", action)
+		}
+	}
+	if o.TTL == 0 {
+		o.TTL = 300
+	}
+	return nil
+}
+
+// target is the CNAME target that encodes the action in RPZ.
+func (o *Options) target(reputation string) (string, bool) {
+	action := o.Action
+	if reputation == "suspicious" {
+		action = o.SuspiciousAction
+	} else if reputation != "malicious" {
+		return "", false
+	}
+	switch action {
+	case ActionNXDOMAIN:
+		return ".", true
+	case ActionNODATA:
+		return "*.", true
+	case ActionSinkhole:
+		return dns.Fqdn(o.Sinkhole), true
+	}
+	return "", false
+}
+
+// records builds the policy records for the tenant's domains under origin,
+// sorted so equal policies hash equally.
+func records(origin string, threats []models.ThreatIndicator, allow *feeds.Allowlist, opts *Options) []dns.RR {
+	seen := map[string]bool{}
+	var rrs []dns.RR
+	add := func(owner, target string) {
+		if seen[owner] {
+			return
+		}
+		seen[owner] = true
+		rrs = append(rrs, &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: owner, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: opts.TTL},
+			Target: target,
+		})
+	}
+
+	for _, t := range threats {
+		if t.Type != "domain" || allow.Allows(t.Type, t.Indicator) {
+			continue
+		}
+		domain := feeds.NormalizeDomain(t.Indicator)
+		if _, ok := dns.IsDomainName(domain); !ok || domain == "" {
+			continue
+		}
+		target, ok := opts.target(t.Reputation)
+		if !ok {
+			continue
+		}
+		add(domain+"."+origin, target)
+		if opts.Wildcard {
+			add("*."+domain+"."+origin, target)
+		}
+	}
+
+	slices.SortFunc(rrs, func(a, b dns.RR) int { return strings.Compare(a.String(), b.String()) })
+	return rrs
+}
+
+// Zone is one version of a tenant's policy zone.
+type Zone struct {
+	Origin  string
+	Serial  uint32
+	Records []dns.RR
+	ttl     uint32
+}
+
+func (z *Zone) SOA() *dns.SOA {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: z.Origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: z.ttl},
+		Ns:      "localhost.",
+		Mbox:    "hostmaster.localhost.",
+		Serial:  z.Serial,
+		Refresh: 300,
+		Retry:   60,
+		Expire:  604800,
+		Minttl:  z.ttl,
+	}
+}
+
+func (z *Zone) NS() *dns.NS {
+	return &dns.NS{
+		Hdr: dns.RR_Header{Name: z.Origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: z.ttl},
+		Ns:  "localhost.",
+	}
+}
+
+// File renders the zone in master file format.
+func (z *Zone) File() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ORIGIN %s\n$TTL %d\n", z.Origin, z.ttl)
+	b.WriteString(z.SOA().String() + "\n")
+	b.WriteString(z.NS().String() + "\n")
+	for _, rr := range z.Records {
+		b.WriteString(rr.String() + "\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,178 @@
+package rpz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/miekg/dns"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var rpzLog = logging.For("rpz")
+
+// maxZoneRecords bounds the domains read into one zone, which keeps its
+// stored records (two per domain with wildcards) under MongoDB's 16MB
+// document limit.
+const maxZoneRecords = 50000
+
+// ErrUnknownZone is returned for zone names that belong to no tenant.
+var ErrUnknownZone = errors.New("rpz: unknown zone")
+
+// Zones builds tenants' policy zones and versions them in MongoDB: the
+// serial only moves when the records change, and each change is journaled
+// for incremental transfers.
+type Zones struct {
+	db   *database.MongoDB
+	opts Options
+	mu   sync.Mutex
+}
+
+func NewZones(db *database.MongoDB, opts Options) (*Zones, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts.Zone = dns.Fqdn(strings.ToLower(opts.Zone))
+	return &Zones{db: db, opts: opts}, nil
+}
+
+// Origin is the tenant's zone name.
+func (z *Zones) Origin(tenantID string) string {
+	if tenantID == models.DefaultTenant {
+		return z.opts.Zone
+	}
+	return tenantID + "." + z.opts.Zone
+}
+
+// Tenant resolves a zone name to its tenant.
+func (z *Zones) Tenant(ctx context.Context, origin string) (string, error) {
+	origin = strings.ToLower(dns.Fqdn(origin))
+	if origin == z.opts.Zone {
+		return models.DefaultTenant, nil
+	}
+	tenantID, ok := strings.CutSuffix(origin, "."+z.opts.Zone)
+	if !ok || strings.Contains(tenantID, ".") {
+		return "", ErrUnknownZone
+	}
+	if _, err := z.db.GetTenant(ctx, tenantID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrUnknownZone
+		}
+		return "", err
+	}
+	return tenantID, nil
+}
+
+// Current builds the tenant's zone from its stored verdicts, bumping the
+// serial if the records changed since the last version.
+func (z *Zones) Current(ctx context.Context, tenantID string) (*Zone, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	threats, err := z.db.FindThreats(ctx, tenantID, models.ThreatFilter{
+		Types:       []string{"domain"},
+		Reputations: []string{"malicious", "suspicious"},
+		Limit:       maxZoneRecords,
+	})
+	if err != nil {
+		return nil, err
+	}
+	allowlist, err := z.db.ListAllowlist(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	zone := &Zone{Origin: z.Origin(tenantID), ttl: z.opts.TTL}
+	zone.Records = records(zone.Origin, threats, feeds.NewAllowlist(allowlist), &z.opts)
+	lines := make([]string, len(zone.Records))
+	for i, rr := range zone.Records {
+		lines[i] = rr.String()
+	}
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	hash := hex.EncodeToString(sum[:])
+
+	// Another replica may write a version concurrently; re-read and retry.
+	for attempt := 0; attempt < 3; attempt++ {
+		state, err := z.db.GetRPZState(ctx, tenantID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		if state != nil && state.Hash == hash {
+			zone.Serial = uint32(state.Serial)
+			return zone, nil
+		}
+
+		next := &models.RPZState{TenantID: tenantID, Hash: hash, Records: lines}
+		var prevSerial int64
+		var change *models.RPZChange
+		if state != nil {
+			prevSerial = state.Serial
+			added, removed := diff(state.Records, lines)
+			change = &models.RPZChange{TenantID: tenantID, FromSerial: prevSerial, Added: added, Removed: removed}
+		}
+		// Serials start from the clock so they keep increasing even if the
+		// stored state is lost.
+		next.Serial = max(prevSerial+1, time.Now().Unix())
+		if change != nil {
+			change.ToSerial = next.Serial
+		}
+
+		err = z.db.SaveRPZVersion(ctx, prevSerial, next, change)
+		if errors.Is(err, database.ErrStale) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rpzLog.InfoContext(ctx, "zone updated", "zone", zone.Origin, "serial", next.Serial, "records", len(lines))
+		zone.Serial = uint32(next.Serial)
+		return zone, nil
+	}
+	return nil, database.ErrStale
+}
+
+// Changes returns the journaled steps from serial to the zone's current
+// version, or false if the journal doesn't reach back that far.
+func (z *Zones) Changes(ctx context.Context, tenantID string, serial uint32, zone *Zone) ([]models.RPZChange, bool, error) {
+	changes, err := z.db.ListRPZChanges(ctx, tenantID, int64(serial))
+	if err != nil {
+		return nil, false, err
+	}
+	from := int64(serial)
+	for _, c := range changes {
+		if c.FromSerial != from {
+			return nil, false, nil
+		}
+		from = c.ToSerial
+	}
+	return changes, len(changes) > 0 && from == int64(zone.Serial), nil
+}
+
+func diff(old, new []string) (added, removed []string) {
+	had := make(map[string]bool, len(old))
+	for _, line := range old {
+		had[line] = true
+	}
+	has := make(map[string]bool, len(new))
+	for _, line := range new {
+		has[line] = true
+		if !had[line] {
+			added = append(added, line)
+		}
+	}
+	for _, line := range old {
+		if !has[line] {
+			removed = append(removed, line)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,322 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+
+	"github.com/miekg/dns"
+)
+
+// NIDS rule flavors.
+const (
+	FlavorSuricata = "suricata"
+	FlavorSnort    = "snort" // Snort 2.9 syntax, which Snort 3 converts with snort2lua
+)
+
+// Files referenced by the Suricata rules; they must sit in the rule
+// directory next to the rule file.
+const (
+	IPRepFile           = "atia-iprep.list"
+	IPRepCategoriesFile = "atia-iprep-categories.txt"
+	MD5File             = "atia-md5.list"
+	SHA1File            = "atia-sha1.list"
+	SHA256File          = "atia-sha256.list"
+)
+
+// iprep categories, one per reputation.
+var iprepCategories = []struct {
+	id         int
+	name       string
+	reputation string
+}{
+	{1, "ATIA_Malicious", "malicious"},
+	{2, "ATIA_Suspicious", "suspicious"},
+}
+
+// NIDS is the rule content for a set of verdicts: a rule file plus the lists
+// the Suricata rules reference.
+type NIDS struct {
+	Flavor string
+	rules  []string
+	iprep  []string
+	// iprepUsed records the categories with listed addresses.
+	iprepUsed map[string]bool
+	hashes    map[string][]string // by list file
+}
+
+// BuildNIDS converts the threats into rules for flavor, leaving out
+// allowlisted values and clean verdicts.
+func BuildNIDS(flavor string, threats []models.ThreatIndicator, allow *feeds.Allowlist) (*NIDS, error) {
+	if flavor != FlavorSuricata && flavor != FlavorSnort {
+		return nil, fmt.Errorf("%w: %q", ErrFormat, flavor)
+	}
+	n := &NIDS{Flavor: flavor, iprepUsed: map[string]bool{}, hashes: map[string][]string{}}
+	sids := sids{}
+	for _, t := range sorted(threats) {
+		if t.Reputation != "malicious" && t.Reputation != "suspicious" {
+			continue
+		}
+		if allow.Allows(t.Type, t.Indicator) {
+			continue
+		}
+		switch t.Type {
+		case "ip":
+			n.addIP(&t, sids)
+		case "domain":
+			n.addDomain(&t, sids)
+		case "url":
+			n.addURL(&t, sids)
+		case "hash":
+			n.addHash(&t)
+		}
+	}
+
+	if flavor == FlavorSuricata {
+		n.addListRules(sids)
+	}
+	return n, nil
+}
+
+func (n *NIDS) addIP(t *models.ThreatIndicator, sids sids) {
+	addr, err := netip.ParseAddr(t.Indicator)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	if n.Flavor == FlavorSuricata {
+		for _, c := range iprepCategories {
+			if c.reputation == t.Reputation {
+				// iprep scores run 1-127.
+				score := max(1, int(math.Round(t.RiskScore*127/100)))
+				n.iprep = append(n.iprep, fmt.Sprintf("%s,%d,%d", addr, c.id, score))
+				n.iprepUsed[c.name] = true
+			}
+		}
+		return
+	}
+	n.rules = append(n.rules, rule(
+		fmt.Sprintf("alert ip $HOME_NET any <> %s any", addr),
+		msg(t, "traffic"),
+		nil, t.Reputation, metadata(t), sids.next("ip", t.Indicator)))
+}
+
+func (n *NIDS) addDomain(t *models.ThreatIndicator, sids sids) {
+	domain := feeds.NormalizeDomain(t.Indicator)
+	if _, ok := dns.IsDomainName(domain); !ok || !strings.Contains(domain, ".") {
+		return
+	}
+	if n.Flavor == FlavorSuricata {
+		// dotprefix plus endswith matches the domain and its subdomains.
+		match := []string{"dotprefix", "content:" + content("."+domain), "endswith", "nocase"}
+		n.rules = append(n.rules,
+			rule("alert dns $HOME_NET any -> any any", msg(t, "DNS query"),
+				append([]string{"dns.query"}, match...), t.Reputation, metadata(t), sids.next("dns", domain)),
+			rule("alert tls $HOME_NET any -> $EXTERNAL_NET any", msg(t, "TLS SNI"),
+				append([]string{"flow:established,to_server", "tls.sni"}, match...), t.Reputation, metadata(t), sids.next("tls", domain)))
+		return
+	}
+
+	// Snort 2 has no DNS buffer, so match the name's wire format. A
+	// subdomain's encoding ends with the same labels.
+	var wire strings.Builder
+	for _, label := range strings.Split(domain, ".") {
+		fmt.Fprintf(&wire, "|%02X|%s", len(label), contentBody(label))
+	}
+	wire.WriteString("|00|")
+	n.rules = append(n.rules,
+		rule("alert udp $HOME_NET any -> any 53", msg(t, "DNS query"),
+			[]string{`content:"` + wire.String() + `"`, "nocase"}, t.Reputation, metadata(t), sids.next("dns", domain)),
+		rule("alert tcp $HOME_NET any -> $EXTERNAL_NET 443", msg(t, "TLS SNI"),
+			[]string{"flow:established,to_server", "ssl_state:client_hello", "content:" + content(domain), "nocase"},
+			t.Reputation, metadata(t), sids.next("tls", domain)))
+}
+
+func (n *NIDS) addURL(t *models.ThreatIndicator, sids sids) {
+	u, err := url.Parse(t.Indicator)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+	path := u.RequestURI()
+
+	var options []string
+	if n.Flavor == FlavorSuricata {
+		// Both buffers are normalized, and the host is lowercased without
+		// its port.
+		options = []string{"flow:established,to_server", "http.host", "content:" + content(host), "startswith", "endswith"}
+		if path != "/" {
+			options = append(options, "http.uri", "content:"+content(path), "startswith", "endswith")
+		}
+		n.rules = append(n.rules, rule("alert http $HOME_NET any -> $EXTERNAL_NET any", msg(t, "HTTP request"),
+			options, t.Reputation, metadata(t), sids.next("url", t.Indicator)))
+		return
+	}
+	options = []string{"flow:established,to_server", "content:" + content(host), "http_header", "nocase"}
+	if path != "/" {
+		options = append(options, "content:"+content(path), "http_uri")
+	}
+	n.rules = append(n.rules, rule("alert tcp $HOME_NET any -> $EXTERNAL_NET $HTTP_PORTS", msg(t, "HTTP request"),
+		options, t.Reputation, metadata(t), sids.next("url", t.Indicator)))
+}
+
+func (n *NIDS) addHash(t *models.ThreatIndicator) {
+	if n.Flavor != FlavorSuricata {
+		return
+	}
+	hash := strings.ToLower(t.Indicator)
+	algorithm, _ := stix.HashAlgorithm(hash)
+	switch algorithm {
+	case "MD5":
+		n.hashes[MD5File] = append(n.hashes[MD5File], hash)
+	case "SHA-1":
+		n.hashes[SHA1File] = append(n.hashes[SHA1File], hash)
+	case "SHA-256":
+		n.hashes[SHA256File] = append(n.hashes[SHA256File], hash)
+	}
+}
+
+// addListRules adds the rules that match the iprep and hash lists.
+func (n *NIDS) addListRules(sids sids) {
+	for _, c := range iprepCategories {
+		if !n.iprepUsed[c.name] {
+			continue
+		}
+		for _, dir := range []string{"src", "dst"} {
+			header := "alert ip $HOME_NET any -> any any"
+			if dir == "src" {
+				header = "alert ip any any -> $HOME_NET any"
+			}
+			n.rules = append(n.rules, rule(header,
+				fmt.Sprintf("ATIA %s IP reputation (%s)", c.reputation, dir),
+				[]string{fmt.Sprintf("iprep:%s,%s,>,0", dir, c.name)},
+				c.reputation, nil, sids.next("iprep", dir+" "+c.name)))
+		}
+	}
+
+	for _, list := range []struct{ file, keyword, name string }{
+		{MD5File, "filemd5", "MD5"},
+		{SHA1File, "filesha1", "SHA-1"},
+		{SHA256File, "filesha256", "SHA-256"},
+	} {
+		if len(n.hashes[list.file]) == 0 {
+			continue
+		}
+		for _, proto := range []string{"http", "smtp", "smb"} {
+			n.rules = append(n.rules, rule(fmt.Sprintf("alert %s any any -> any any", proto),
+				fmt.Sprintf("ATIA flagged file %s (%s)", list.name, proto),
+				[]string{list.keyword + ":" + list.file},
+				"malicious", nil, sids.next("file", proto+" "+list.keyword)))
+		}
+	}
+}
+
+// Rules renders the rule file.
+func (n *NIDS) Rules() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# ATIA %s rules\n# %d rules\n", n.Flavor, len(n.rules))
+	if n.Flavor == FlavorSuricata {
+		fmt.Fprintf(&buf, "# Set %s as reputation-categories-file and %s in\n# reputation-files, and put the atia-*.list hash lists in the rule directory.\n",
+			IPRepCategoriesFile, IPRepFile)
+	}
+	for _, r := range n.rules {
+		buf.WriteString(r)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// File renders one of the list files the Suricata rules reference.
+func (n *NIDS) File(name string) ([]byte, bool) {
+	var buf bytes.Buffer
+	switch name {
+	case IPRepCategoriesFile:
+		for _, c := range iprepCategories {
+			fmt.Fprintf(&buf, "%d,%s,ATIA %s verdicts\n", c.id, c.name, c.reputation)
+		}
+	case IPRepFile:
+		for _, line := range n.iprep {
+			buf.WriteString(line + "\n")
+		}
+	case MD5File, SHA1File, SHA256File:
+		for _, hash := range n.hashes[name] {
+			buf.WriteString(hash + "\n")
+		}
+	default:
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+var classtypes = map[string]string{
+	"malicious":  "trojan-activity",
+	"suspicious": "bad-unknown",
+}
+
+func rule(header, message string, options []string, reputation string, meta []string, sid uint32) string {
+	parts := []string{`msg:"` + msgEscape.Replace(message) + `"`}
+	parts = append(parts, options...)
+	if classtype, ok := classtypes[reputation]; ok {
+		parts = append(parts, "classtype:"+classtype)
+	}
+	parts = append(parts, fmt.Sprintf("sid:%d", sid), "rev:1")
+	if len(meta) > 0 {
+		parts = append(parts, "metadata:"+strings.Join(meta, ", "))
+	}
+	return header + " (" + strings.Join(parts, "; ") + ";)"
+}
+
+func msg(t *models.ThreatIndicator, what string) string {
+	return describe(t) + " (" + what + ")"
+}
+
+// metadata carries the verdict into the rule: score, reputation, sources,
+// tags and dates, in the YYYY_MM_DD form public rulesets use.
+func metadata(t *models.ThreatIndicator) []string {
+	meta := []string{
+		"atia_reputation " + t.Reputation,
+		fmt.Sprintf("atia_risk_score %d", int(math.Round(t.RiskScore))),
+	}
+	for _, name := range sourceNames(t) {
+		meta = append(meta, "atia_source "+metaValue(name))
+	}
+	for _, tag := range t.Tags {
+		meta = append(meta, "atia_tag "+metaValue(tag))
+	}
+	if !t.FirstSeen.IsZero() {
+		meta = append(meta, "created_at "+t.FirstSeen.UTC().Format("2006_01_02"))
+	}
+	if !t.LastUpdated.IsZero() {
+		meta = append(meta, "updated_at "+t.LastUpdated.UTC().Format("2006_01_02"))
+	}
+	return meta
+}
+
+var msgEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `;`, `\;`)
+
+// content quotes a value for a content match.
+func content(v string) string {
+	return `"` + contentBody(v) + `"`
+}
+
+// contentBody hex-escapes the characters rule syntax reserves and anything
+// unprintable.
+func contentBody(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c < 0x20 || c > 0x7e || c == '"' || c == ';' || c == '\\' || c == '|' || c == ':' {
+			fmt.Fprintf(&b, "|%02X|", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
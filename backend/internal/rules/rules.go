@@ -0,0 +1,79 @@
+// Package rules turns stored verdicts into detection content for network
+// and host sensors.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+var ErrFormat = errors.New("rules: unsupported format")
+
+// Host-level rule formats, next to the NIDS flavors.
+const (
+	FlavorYARA  = "yara"
+	FlavorSigma = "sigma"
+)
+
+// SIDs are taken from a range no public ruleset uses, so ATIA rules can be
+// loaded next to ET Open or Talos rules.
+const (
+	sidBase  = 100000000
+	sidSpace = 900000000
+)
+
+// sids hands out stable SIDs: each rule's SID is a hash of its kind and
+// indicator, probed forward on the rare collision within one file.
+type sids map[uint32]bool
+
+func (s sids) next(kind, indicator string) uint32 {
+	h := fnv.New64a()
+	h.Write([]byte(kind + "|" + strings.ToLower(indicator)))
+	n := h.Sum64() % sidSpace
+	for s[uint32(sidBase+n)] {
+		n = (n + 1) % sidSpace
+	}
+	s[uint32(sidBase+n)] = true
+	return uint32(sidBase + n)
+}
+
+// sorted returns the threats in indicator order, so collisions resolve the
+// same way every time.
+func sorted(threats []models.ThreatIndicator) []models.ThreatIndicator {
+	threats = slices.Clone(threats)
+	slices.SortFunc(threats, func(a, b models.ThreatIndicator) int {
+		return strings.Compare(a.Type+" "+a.Indicator, b.Type+" "+b.Indicator)
+	})
+	return threats
+}
+
+// metaValue reduces a value to the characters rule metadata allows.
+func metaValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, v)
+}
+
+// sourceNames lists the providers that flagged the indicator.
+func sourceNames(t *models.ThreatIndicator) []string {
+	var names []string
+	for _, s := range t.Sources {
+		if s.Verdict == "malicious" || s.Verdict == "suspicious" {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// describe is a one-line summary for rule titles and messages.
+func describe(t *models.ThreatIndicator) string {
+	return fmt.Sprintf("ATIA %s %s %s", t.Reputation, t.Type, t.Indicator)
+}
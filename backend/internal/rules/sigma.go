@@ -0,0 +1,192 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+
+	"github.com/google/uuid"
+	"github.com/miekg/dns"
+)
+
+// sigmaNamespace seeds the rule IDs, so a rule keeps its ID across exports.
+var sigmaNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/AEX0TIC/ATIA/sigma"))
+
+var sigmaLevels = map[string]string{
+	"malicious":  "high",
+	"suspicious": "medium",
+}
+
+// sigmaHashFields are the Sysmon-style prefixes in the Hashes field.
+var sigmaHashFields = map[string]string{
+	"MD5":     "MD5",
+	"SHA-1":   "SHA1",
+	"SHA-256": "SHA256",
+}
+
+// sigmaRule is one rule; selections are named field|modifier lists ORed
+// together.
+type sigmaRule struct {
+	key         string
+	title       string
+	description string
+	category    string
+	reputation  string
+	tags        []string
+	selections  []sigmaSelection
+	created     time.Time
+	modified    time.Time
+}
+
+type sigmaSelection struct {
+	name   string
+	field  string
+	values []string
+}
+
+func (r *sigmaRule) add(name, field, value string, t *models.ThreatIndicator) {
+	i := slices.IndexFunc(r.selections, func(s sigmaSelection) bool { return s.name == name })
+	if i < 0 {
+		r.selections = append(r.selections, sigmaSelection{name: name, field: field})
+		i = len(r.selections) - 1
+	}
+	r.selections[i].values = append(r.selections[i].values, sigmaEscape.Replace(value))
+	if r.created.IsZero() || (!t.FirstSeen.IsZero() && t.FirstSeen.Before(r.created)) {
+		r.created = t.FirstSeen
+	}
+	if t.LastUpdated.After(r.modified) {
+		r.modified = t.LastUpdated
+	}
+}
+
+// Sigma renders rules for the dns, proxy and process_creation log sources,
+// one per source and reputation, as a multi-document YAML collection that
+// sigma-cli converts for a SIEM backend.
+func Sigma(threats []models.ThreatIndicator, allow *feeds.Allowlist) []byte {
+	rules := map[string]*sigmaRule{}
+	rule := func(category, reputation string) *sigmaRule {
+		key := category + "/" + reputation
+		r := rules[key]
+		if r == nil {
+			r = &sigmaRule{key: key, category: category, reputation: reputation}
+			switch category {
+			case "dns":
+				r.title = fmt.Sprintf("DNS Query For ATIA %s Domain", titleCase(reputation))
+				r.description = fmt.Sprintf("Detects DNS queries for domains or their subdomains that ATIA rated %s.", reputation)
+				r.tags = []string{"attack.command-and-control", "attack.t1071.004"}
+			case "proxy":
+				r.title = fmt.Sprintf("Proxy Request To ATIA %s URL Or Domain", titleCase(reputation))
+				r.description = fmt.Sprintf("Detects web requests to URLs, or hosts under domains, that ATIA rated %s.", reputation)
+				r.tags = []string{"attack.command-and-control", "attack.t1071.001"}
+			case "process_creation":
+				r.title = fmt.Sprintf("Process Created From ATIA %s File Hash", titleCase(reputation))
+				r.description = fmt.Sprintf("Detects processes whose image hash ATIA rated %s.", reputation)
+				r.tags = []string{"attack.execution"}
+			}
+			rules[key] = r
+		}
+		return r
+	}
+
+	for _, t := range sorted(threats) {
+		if (t.Reputation != "malicious" && t.Reputation != "suspicious") || allow.Allows(t.Type, t.Indicator) {
+			continue
+		}
+		switch t.Type {
+		case "domain":
+			domain := feeds.NormalizeDomain(t.Indicator)
+			if _, ok := dns.IsDomainName(domain); !ok || !strings.Contains(domain, ".") {
+				continue
+			}
+			dnsRule := rule("dns", t.Reputation)
+			dnsRule.add("selection_domain", "query", domain, &t)
+			dnsRule.add("selection_subdomain", "query|endswith", "."+domain, &t)
+			proxyRule := rule("proxy", t.Reputation)
+			proxyRule.add("selection_domain", "cs-host", domain, &t)
+			proxyRule.add("selection_subdomain", "cs-host|endswith", "."+domain, &t)
+		case "url":
+			if u, err := url.Parse(t.Indicator); err != nil || u.Hostname() == "" {
+				continue
+			}
+			rule("proxy", t.Reputation).add("selection_url", "c-uri", t.Indicator, &t)
+		case "hash":
+			hash := strings.ToLower(t.Indicator)
+			algorithm, _ := stix.HashAlgorithm(hash)
+			field, ok := sigmaHashFields[algorithm]
+			if !ok {
+				continue
+			}
+			rule("process_creation", t.Reputation).add("selection_hash", "Hashes|contains", field+"="+hash, &t)
+		}
+	}
+
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var buf bytes.Buffer
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		rules[key].write(&buf)
+	}
+	return buf.Bytes()
+}
+
+func (r *sigmaRule) write(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "title: %s\n", yamlQuote(r.title))
+	fmt.Fprintf(buf, "id: %s\n", uuid.NewSHA1(sigmaNamespace, []byte(r.key)))
+	buf.WriteString("status: experimental\n")
+	fmt.Fprintf(buf, "description: %s\n", yamlQuote(r.description))
+	buf.WriteString("author: ATIA\n")
+	if !r.created.IsZero() {
+		fmt.Fprintf(buf, "date: %s\n", r.created.UTC().Format("2006-01-02"))
+	}
+	if !r.modified.IsZero() {
+		fmt.Fprintf(buf, "modified: %s\n", r.modified.UTC().Format("2006-01-02"))
+	}
+	buf.WriteString("tags:\n")
+	for _, tag := range r.tags {
+		fmt.Fprintf(buf, "    - %s\n", tag)
+	}
+	fmt.Fprintf(buf, "logsource:\n    category: %s\n", r.category)
+	buf.WriteString("detection:\n")
+	for _, s := range r.selections {
+		fmt.Fprintf(buf, "    %s:\n        %s:\n", s.name, s.field)
+		for _, v := range s.values {
+			fmt.Fprintf(buf, "            - %s\n", yamlQuote(v))
+		}
+	}
+	condition := r.selections[0].name
+	if len(r.selections) > 1 {
+		condition = "1 of selection_*"
+	}
+	fmt.Fprintf(buf, "    condition: %s\n", condition)
+	buf.WriteString("falsepositives:\n    - Indicators that were rated before the infrastructure changed hands\n")
+	fmt.Fprintf(buf, "level: %s\n", sigmaLevels[r.reputation])
+}
+
+// sigmaEscape keeps values literal: * and ? are wildcards in Sigma.
+var sigmaEscape = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+// yamlQuote single-quotes a scalar, which escapes nothing but the quote.
+func yamlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func titleCase(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/stix"
+)
+
+// yaraHashFuncs maps hash algorithms onto the YARA hash module.
+var yaraHashFuncs = map[string]string{
+	"MD5":     "md5",
+	"SHA-1":   "sha1",
+	"SHA-256": "sha256",
+}
+
+// untagged groups hashes without tags.
+const untagged = "untagged"
+
+type yaraGroup struct {
+	hashes     map[string][]string // by hash module function
+	reputation map[string]bool
+	maxScore   float64
+	modified   time.Time
+}
+
+// YARA renders one rule per tag matching the flagged file hashes carrying
+// it, using the hash module. A hash with several tags appears in each of
+// their rules.
+func YARA(threats []models.ThreatIndicator, allow *feeds.Allowlist) []byte {
+	groups := map[string]*yaraGroup{}
+	for _, t := range sorted(threats) {
+		if t.Type != "hash" || (t.Reputation != "malicious" && t.Reputation != "suspicious") || allow.Allows(t.Type, t.Indicator) {
+			continue
+		}
+		hash := strings.ToLower(t.Indicator)
+		algorithm, _ := stix.HashAlgorithm(hash)
+		fn, ok := yaraHashFuncs[algorithm]
+		if !ok {
+			continue
+		}
+
+		tags := t.Tags
+		if len(tags) == 0 {
+			tags = []string{untagged}
+		}
+		for _, tag := range tags {
+			name := yaraIdentifier(tag)
+			g := groups[name]
+			if g == nil {
+				g = &yaraGroup{hashes: map[string][]string{}, reputation: map[string]bool{}}
+				groups[name] = g
+			}
+			g.hashes[fn] = append(g.hashes[fn], hash)
+			g.reputation[t.Reputation] = true
+			g.maxScore = max(g.maxScore, t.RiskScore)
+			if t.LastUpdated.After(g.modified) {
+				g.modified = t.LastUpdated
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// ATIA file hash rules, one per tag\n\nimport \"hash\"\n")
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		g := groups[name]
+		reputations := make([]string, 0, len(g.reputation))
+		for r := range g.reputation {
+			reputations = append(reputations, r)
+		}
+		slices.Sort(reputations)
+
+		count := 0
+		var conditions []string
+		for _, fn := range []string{"md5", "sha1", "sha256"} {
+			for _, hash := range g.hashes[fn] {
+				conditions = append(conditions, fmt.Sprintf("hash.%s(0, filesize) == \"%s\"", fn, hash))
+			}
+			count += len(g.hashes[fn])
+		}
+
+		fmt.Fprintf(&buf, "\nrule ATIA_%s : atia %s\n{\n", name, strings.Join(reputations, " "))
+		buf.WriteString("    meta:\n")
+		fmt.Fprintf(&buf, "        author = \"ATIA\"\n")
+		description := "Files ATIA flagged with tag " + name
+		if name == untagged {
+			description = "Files ATIA flagged without tags"
+		}
+		fmt.Fprintf(&buf, "        description = \"%s\"\n", yaraEscape.Replace(description))
+		if !g.modified.IsZero() {
+			fmt.Fprintf(&buf, "        date = \"%s\"\n", g.modified.UTC().Format("2006-01-02"))
+		}
+		fmt.Fprintf(&buf, "        hash_count = %d\n", count)
+		fmt.Fprintf(&buf, "        max_risk_score = %d\n", int(math.Round(g.maxScore)))
+		buf.WriteString("    condition:\n        ")
+		buf.WriteString(strings.Join(conditions, " or\n        "))
+		buf.WriteString("\n}\n")
+	}
+	return buf.Bytes()
+}
+
+var yaraEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// yaraIdentifier turns a tag into the tail of a rule name, which YARA caps
+// at 128 characters.
+func yaraIdentifier(tag string) string {
+	if len(tag) > 100 {
+		tag = tag[:100]
+	}
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, tag), "_")
+}
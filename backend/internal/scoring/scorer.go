@@ -2,6 +2,7 @@ package scoring
 
 import (
 	"math"
+	"time"
 
 	"github.com/AEX0TIC/ATIA/backend/internal/models"
 )
@@ -39,3 +40,50 @@ func DetermineReputation(riskScore float64) string {
 	}
 	return "clean"
 }
+
+// Severity buckets a risk score for notifications.
+func Severity(riskScore float64) string {
+	if riskScore > 70 {
+		return "critical"
+	} else if riskScore > 50 {
+		return "high"
+	} else if riskScore > 30 {
+		return "medium"
+	}
+	return "low"
+}
+
+var severityRanks = map[string]int{"low": 0, "medium": 1, "high": 2, "critical": 3}
+
+// SeverityAtLeast reports whether severity is at or above min. An empty min
+// matches everything.
+func SeverityAtLeast(severity, min string) bool {
+	if min == "" {
+		return true
+	}
+	return severityRanks[severity] >= severityRanks[min]
+}
+
+// Sightings in the last sightingWindow raise a risk score by up to
+// maxSightingBonus points.
+const (
+	sightingWindow   = 30 * 24 * time.Hour
+	maxSightingBonus = 15.0
+)
+
+// ApplySightings raises the risk score of an indicator seen in the
+// environment, more for recent and repeated sightings. Clean scores are
+// left alone so that traffic to benign hosts doesn't make them suspicious.
+func ApplySightings(riskScore float64, count int, lastSighted, now time.Time) float64 {
+	if riskScore < 10 || count <= 0 || lastSighted.IsZero() {
+		return riskScore
+	}
+	age := now.Sub(lastSighted)
+	if age >= sightingWindow {
+		return riskScore
+	}
+	recency := 1 - math.Max(age.Hours(), 0)/sightingWindow.Hours()
+	// A single sighting earns a little over half the bonus, 99 or more all of it.
+	volume := 0.5 + 0.5*math.Min(math.Log10(float64(count)+1)/2, 1)
+	return math.Min(riskScore+maxSightingBonus*recency*volume, 100.0)
+}
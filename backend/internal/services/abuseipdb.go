@@ -1,9 +1,8 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 )
@@ -20,14 +19,25 @@ func NewAbuseIPDBService(apiKey string) *AbuseIPDBService {
 	}
 }
 
-func (a *AbuseIPDBService) AnalyzeIP(ip string) (map[string]interface{}, error) {
+// withAPIKey returns a copy using apiKey, sharing the HTTP client. An empty
+// key returns the receiver unchanged.
+func (a *AbuseIPDBService) withAPIKey(apiKey string) *AbuseIPDBService {
+	if apiKey == "" {
+		return a
+	}
+	clone := *a
+	clone.apiKey = apiKey
+	return &clone
+}
+
+func (a *AbuseIPDBService) AnalyzeIP(ctx context.Context, ip string) (map[string]interface{}, error) {
 	if a.apiKey == "" {
 		return map[string]interface{}{"error": "API key not configured"}, nil
 	}
 
 	url := fmt.Sprintf("https://api.abuseipdb.com/api/v2/check?ipAddress=%s&maxAgeInDays=90&verbose", ip)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -35,21 +45,5 @@ func (a *AbuseIPDBService) AnalyzeIP(ip string) (map[string]interface{}, error)
 	req.Header.Add("Key", a.apiKey)
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return fetchJSON(a.client, providerAbuseIPDB, req)
 }
@@ -1,43 +1,123 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/feeds"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
 	"github.com/AEX0TIC/ATIA/backend/internal/models"
 	"github.com/AEX0TIC/ATIA/backend/internal/scoring"
+	"github.com/AEX0TIC/ATIA/backend/internal/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var aggregatorLog = logging.For("aggregator")
+
 type Aggregator struct {
 	vtService    *VirusTotalService
 	otxService   *OTXService
 	abuseService *AbuseIPDBService
 	db           *database.MongoDB
 	webhook      *WebhookService
+	bus          *events.Bus
+	cache        *responseCache
+	queue        *AnalysisQueue
+	// enrichBudget is how many graph neighbours an analysis may queue.
+	enrichBudget int
 }
 
 func NewAggregator(vt *VirusTotalService, otx *OTXService, abuse *AbuseIPDBService, db *database.MongoDB) *Aggregator {
-	return &Aggregator{
-		vtService:    vt,
-		otxService:   otx,
-		abuseService: abuse,
-		db:           db,
-		webhook:      NewWebhookService(""),
-	}
+	return NewAggregatorWithWebhook(vt, otx, abuse, db, "")
 }
 
 func NewAggregatorWithWebhook(vt *VirusTotalService, otx *OTXService, abuse *AbuseIPDBService, db *database.MongoDB, webhookURL string) *Aggregator {
-	return &Aggregator{
+	a := &Aggregator{
 		vtService:    vt,
 		otxService:   otx,
 		abuseService: abuse,
 		db:           db,
-		webhook:      NewWebhookService(webhookURL),
+		webhook:      NewWebhookService(db, webhookURL),
 	}
+	a.queue = newAnalysisQueue(a)
+	return a
+}
+
+// Webhooks returns the service that delivers the aggregator's events.
+func (a *Aggregator) Webhooks() *WebhookService {
+	return a.webhook
+}
+
+// Queue returns the background analysis queue.
+func (a *Aggregator) Queue() *AnalysisQueue {
+	return a.queue
 }
 
-func (a *Aggregator) AnalyzeIndicator(indicator, indicatorType string) (*models.ThreatIndicator, error) {
+// Events returns the bus the aggregator publishes to, or nil.
+func (a *Aggregator) Events() *events.Bus {
+	return a.bus
+}
+
+// PublishTo reports lifecycle events on bus. Without a bus nothing reacts
+// to analyses beyond storing them.
+func (a *Aggregator) PublishTo(bus *events.Bus) {
+	a.bus = bus
+}
+
+// EnableCache turns on provider response caching with the given TTL. A
+// non-positive TTL leaves caching disabled.
+func (a *Aggregator) EnableCache(ttl time.Duration) {
+	if ttl > 0 {
+		a.cache = newResponseCache(ttl)
+	}
+}
+
+// EnableGraphEnrichment queues up to budget unanalyzed first-hop graph
+// neighbours of every analyzed indicator. Zero disables it.
+func (a *Aggregator) EnableGraphEnrichment(budget int) {
+	a.enrichBudget = budget
+}
+
+// AnalyzeIndicator queries every provider for the indicator using the
+// tenant's provider keys and stores the result under that tenant.
+func (a *Aggregator) AnalyzeIndicator(ctx context.Context, tenantID, indicator, indicatorType string) (*models.ThreatIndicator, error) {
+	return a.AnalyzeIndicatorWithProgress(ctx, tenantID, indicator, indicatorType, nil)
+}
+
+// AnalyzeIndicatorWithProgress is AnalyzeIndicator that also passes this
+// analysis' analysis_started and source_completed events to progress as
+// they happen. progress may be called from several goroutines at once; all
+// calls return before the analysis does.
+func (a *Aggregator) AnalyzeIndicatorWithProgress(ctx context.Context, tenantID, indicator, indicatorType string, progress func(events.Event)) (_ *models.ThreatIndicator, err error) {
+	ctx, span := tracing.Start(ctx, "AnalyzeIndicator", attribute.String("indicator.type", indicatorType))
+	defer func() { tracing.End(span, err) }()
+
+	tenant, err := loadTenant(ctx, a.db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	started := events.Event{
+		Type:          events.AnalysisStarted,
+		TenantID:      tenant.ID,
+		Indicator:     indicator,
+		IndicatorType: indicatorType,
+	}
+	a.bus.Publish(ctx, started)
+	if progress != nil {
+		progress(started)
+	}
+
+	vt := a.vtService.withAPIKey(tenant.ProviderKeys.VirusTotal)
+	otx := a.otxService.withAPIKey(tenant.ProviderKeys.OTX)
+	abuse := a.abuseService.withAPIKey(tenant.ProviderKeys.AbuseIPDB)
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	sources := []models.SourceData{}
@@ -46,62 +126,68 @@ func (a *Aggregator) AnalyzeIndicator(indicator, indicatorType string) (*models.
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		var vtData map[string]interface{}
-		var err error
-
-		switch indicatorType {
-		case "ip":
-			vtData, err = a.vtService.AnalyzeIP(indicator)
-		case "domain":
-			vtData, err = a.vtService.AnalyzeDomain(indicator)
-		case "hash":
-			vtData, err = a.vtService.AnalyzeHash(indicator)
-		case "url":
-			vtData, err = a.vtService.AnalyzeURL(indicator)
-		}
+		vtData, err := a.fetchSource(ctx, tenantID, providerVirusTotal, indicatorType, indicator, func(ctx context.Context) (map[string]interface{}, error) {
+			switch indicatorType {
+			case "ip":
+				return vt.AnalyzeIP(ctx, indicator)
+			case "domain":
+				return vt.AnalyzeDomain(ctx, indicator)
+			case "hash":
+				return vt.AnalyzeHash(ctx, indicator)
+			case "url":
+				return vt.AnalyzeURL(ctx, indicator)
+			}
+			return nil, nil
+		})
 
+		var source *models.SourceData
 		if err == nil && vtData != nil {
-			mu.Lock()
-			sources = append(sources, models.SourceData{
-				Name:      "VirusTotal",
+			source = &models.SourceData{
+				Name:      providerVirusTotal,
 				Verdict:   extractVerdict(vtData, "virustotal"),
 				Score:     extractScore(vtData, "virustotal"),
 				Details:   vtData,
 				Timestamp: time.Now(),
-			})
+			}
+			mu.Lock()
+			sources = append(sources, *source)
 			mu.Unlock()
 		}
+		a.publishSource(ctx, progress, tenant.ID, indicator, indicatorType, providerVirusTotal, source, err)
 	}()
 
 	// Fetch from OTX
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		var otxData map[string]interface{}
-		var err error
-
-		switch indicatorType {
-		case "ip":
-			otxData, err = a.otxService.AnalyzeIP(indicator)
-		case "domain":
-			otxData, err = a.otxService.AnalyzeDomain(indicator)
-		case "hash":
-			otxData, err = a.otxService.AnalyzeHash(indicator)
-		case "url":
-			otxData, err = a.otxService.AnalyzeURL(indicator)
-		}
+		otxData, err := a.fetchSource(ctx, tenantID, providerOTX, indicatorType, indicator, func(ctx context.Context) (map[string]interface{}, error) {
+			switch indicatorType {
+			case "ip":
+				return otx.AnalyzeIP(ctx, indicator)
+			case "domain":
+				return otx.AnalyzeDomain(ctx, indicator)
+			case "hash":
+				return otx.AnalyzeHash(ctx, indicator)
+			case "url":
+				return otx.AnalyzeURL(ctx, indicator)
+			}
+			return nil, nil
+		})
 
+		var source *models.SourceData
 		if err == nil && otxData != nil {
-			mu.Lock()
-			sources = append(sources, models.SourceData{
-				Name:      "AlienVault OTX",
+			source = &models.SourceData{
+				Name:      providerOTX,
 				Verdict:   extractVerdict(otxData, "otx"),
 				Score:     extractScore(otxData, "otx"),
 				Details:   otxData,
 				Timestamp: time.Now(),
-			})
+			}
+			mu.Lock()
+			sources = append(sources, *source)
 			mu.Unlock()
 		}
+		a.publishSource(ctx, progress, tenant.ID, indicator, indicatorType, providerOTX, source, err)
 	}()
 
 	// Fetch from AbuseIPDB (only for IPs)
@@ -109,18 +195,23 @@ func (a *Aggregator) AnalyzeIndicator(indicator, indicatorType string) (*models.
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			abuseData, err := a.abuseService.AnalyzeIP(indicator)
+			abuseData, err := a.fetchSource(ctx, tenantID, providerAbuseIPDB, indicatorType, indicator, func(ctx context.Context) (map[string]interface{}, error) {
+				return abuse.AnalyzeIP(ctx, indicator)
+			})
+			var source *models.SourceData
 			if err == nil && abuseData != nil {
-				mu.Lock()
-				sources = append(sources, models.SourceData{
-					Name:      "AbuseIPDB",
+				source = &models.SourceData{
+					Name:      providerAbuseIPDB,
 					Verdict:   extractVerdict(abuseData, "abuseipdb"),
 					Score:     extractScore(abuseData, "abuseipdb"),
 					Details:   abuseData,
 					Timestamp: time.Now(),
-				})
+				}
+				mu.Lock()
+				sources = append(sources, *source)
 				mu.Unlock()
 			}
+			a.publishSource(ctx, progress, tenant.ID, indicator, indicatorType, providerAbuseIPDB, source, err)
 		}()
 	}
 
@@ -129,8 +220,14 @@ func (a *Aggregator) AnalyzeIndicator(indicator, indicatorType string) (*models.
 	// Calculate risk score
 	riskScore := scoring.CalculateRiskScore(sources)
 	reputation := scoring.DetermineReputation(riskScore)
+	span.SetAttributes(
+		attribute.Float64("threat.risk_score", riskScore),
+		attribute.String("threat.reputation", reputation),
+		attribute.Int("threat.sources", len(sources)),
+	)
 
 	threat := &models.ThreatIndicator{
+		TenantID:    tenant.ID,
 		Indicator:   indicator,
 		Type:        indicatorType,
 		RiskScore:   riskScore,
@@ -142,22 +239,180 @@ func (a *Aggregator) AnalyzeIndicator(indicator, indicatorType string) (*models.
 		Tags:        extractTags(sources),
 	}
 
+	aggregatorLog.InfoContext(ctx, "analysis completed",
+		"indicator_type", indicatorType,
+		"risk_score", riskScore,
+		"reputation", reputation,
+		"sources", len(sources))
+
+	// The stored record, if any, tells whether the reputation changed and
+	// when the indicator was first seen. Imported context in its metadata
+	// is kept, and its sightings count towards the score.
+	var previousReputation string
+	var sighted *models.ThreatIndicator
+	if previous, err := a.db.GetThreat(ctx, tenant.ID, indicator); err == nil {
+		previousReputation = previous.Reputation
+		threat.FirstSeen = previous.FirstSeen
+		for k, v := range previous.Metadata {
+			threat.Metadata[k] = v
+		}
+		threat.Tags = mergeTags(threat.Tags, models.ImportedTags(previous.Metadata))
+		if previous.LastSighted != nil {
+			sighted = previous
+			threat.RiskScore = scoring.ApplySightings(riskScore, previous.SightingCount, *previous.LastSighted, time.Now())
+			threat.Reputation = scoring.DetermineReputation(threat.RiskScore)
+		}
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		aggregatorLog.WarnContext(ctx, "failed to load previous analysis", "error", err)
+	}
+
 	// Save to database
-	if err := a.db.SaveThreat(threat); err != nil {
+	if err := a.db.SaveThreat(ctx, threat); err != nil {
+		aggregatorLog.ErrorContext(ctx, "failed to save threat", "indicator_type", indicatorType, "error", err)
 		return threat, err
 	}
+	// Set after saving so that sightings recorded meanwhile aren't
+	// overwritten.
+	if sighted != nil {
+		threat.SightingCount, threat.LastSighted = sighted.SightingCount, sighted.LastSighted
+	}
 
-	// Trigger webhook for n8n notification (non-blocking)
-	go func() {
-		if err := a.webhook.TriggerThreatAnalysis(threat); err != nil {
-			// Log error but don't fail the request
-			_ = err
+	if edges := extractRelations(threat); len(edges) > 0 {
+		if err := a.db.SaveGraphEdges(ctx, edges); err != nil {
+			aggregatorLog.WarnContext(ctx, "failed to save relationships", "error", err)
+		} else {
+			a.enrichNeighbors(ctx, tenant.ID, indicator, edges)
 		}
-	}()
+	}
+
+	if tenant.ShareVerdicts && isPublicIndicator(indicatorType, indicator) {
+		// Other tenants get the providers' verdict, not this tenant's
+		// sightings.
+		verdict := models.NewSharedVerdict(threat)
+		verdict.RiskScore, verdict.Reputation = riskScore, reputation
+		if err := a.db.SaveSharedVerdict(ctx, verdict); err != nil {
+			aggregatorLog.WarnContext(ctx, "failed to share verdict", "error", err)
+		}
+	}
+
+	a.bus.Publish(ctx, events.Event{
+		Type:          events.AnalysisCompleted,
+		TenantID:      tenant.ID,
+		Indicator:     indicator,
+		IndicatorType: indicatorType,
+		Threat:        threat,
+	})
+	if previousReputation != "" && previousReputation != threat.Reputation {
+		a.bus.Publish(ctx, events.Event{
+			Type:               events.ReputationChanged,
+			TenantID:           tenant.ID,
+			Indicator:          indicator,
+			IndicatorType:      indicatorType,
+			Threat:             threat,
+			PreviousReputation: previousReputation,
+		})
+	}
+	// Allowlisted indicators are left out of feeds and rules whatever the
+	// verdict, so their analyses are flagged.
+	if entries, err := a.db.ListAllowlist(ctx, tenant.ID); err != nil {
+		aggregatorLog.WarnContext(ctx, "failed to load allowlist", "error", err)
+	} else if feeds.NewAllowlist(entries).Allows(indicatorType, indicator) {
+		a.bus.Publish(ctx, events.Event{
+			Type:          events.ListMatched,
+			TenantID:      tenant.ID,
+			Indicator:     indicator,
+			IndicatorType: indicatorType,
+			Threat:        threat,
+			List:          events.ListAllowlist,
+		})
+	}
 
 	return threat, nil
 }
 
+// DeleteThreat removes a stored analysis by ID and announces it.
+func (a *Aggregator) DeleteThreat(ctx context.Context, tenantID, id string) error {
+	threat, err := a.db.DeleteThreat(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	a.bus.Publish(ctx, events.Event{
+		Type:          events.IndicatorDeleted,
+		TenantID:      tenantID,
+		Indicator:     threat.Indicator,
+		IndicatorType: threat.Type,
+		Threat:        threat,
+	})
+	return nil
+}
+
+func (a *Aggregator) publishSource(ctx context.Context, progress func(events.Event), tenantID, indicator, indicatorType, provider string, source *models.SourceData, err error) {
+	event := events.Event{
+		Type:          events.SourceCompleted,
+		TenantID:      tenantID,
+		Indicator:     indicator,
+		IndicatorType: indicatorType,
+		SourceName:    provider,
+		Source:        source,
+	}
+	switch {
+	case err != nil:
+		event.SourceError = err.Error()
+	case source == nil:
+		event.SourceError = "no data"
+	}
+	a.bus.Publish(ctx, event)
+	if progress != nil {
+		progress(event)
+	}
+}
+
+// loadTenant returns the tenant's settings. The default tenant need not be
+// stored; without a document it simply uses the deployment-wide settings.
+func loadTenant(ctx context.Context, db *database.MongoDB, tenantID string) (*models.Tenant, error) {
+	tenant, err := db.GetTenant(ctx, tenantID)
+	if errors.Is(err, mongo.ErrNoDocuments) && tenantID == models.DefaultTenant {
+		return &models.Tenant{ID: models.DefaultTenant}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load tenant %q: %w", tenantID, err)
+	}
+	return tenant, nil
+}
+
+// fetchSource runs one provider lookup in its own span, going through the
+// response cache when it is enabled.
+func (a *Aggregator) fetchSource(ctx context.Context, tenantID, provider, indicatorType, indicator string, load func(context.Context) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "provider "+provider,
+		attribute.String("source.name", provider),
+		attribute.String("indicator.type", indicatorType),
+	)
+
+	data, err := a.cache.fetch(ctx, tenantID, provider, indicatorType, indicator, func() (map[string]interface{}, error) {
+		return load(ctx)
+	})
+	// Provider error replies are read like other responses; they were only
+	// kept out of the cache.
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		err = nil
+	}
+
+	status := "success"
+	switch {
+	case err != nil:
+		status = "error"
+	case data == nil:
+		status = "skipped"
+	case data["error"] != nil:
+		status = "unavailable"
+	}
+	span.SetAttributes(attribute.String("source.status", status))
+	tracing.End(span, err)
+
+	return data, err
+}
+
 func extractVerdict(data map[string]interface{}, source string) string {
 	// Implement verdict extraction logic based on source
 	switch source {
@@ -211,6 +466,16 @@ func extractScore(data map[string]interface{}, source string) float64 {
 	return 0.0
 }
 
+// mergeTags appends the extra tags missing from tags.
+func mergeTags(tags, extra []string) []string {
+	for _, tag := range extra {
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 func extractTags(sources []models.SourceData) []string {
 	tags := make(map[string]bool)
 	for _, source := range sources {
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxCacheEntries = 10000
+
+// responseCache memoizes raw provider responses for a short TTL so repeated
+// analyses of the same indicator don't spend upstream quota.
+type responseCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	data    map[string]interface{}
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// fetch returns the tenant's cached response for provider/type/indicator, or
+// calls load and caches its result unless it failed, including with a
+// provider error reply. A nil cache always calls load.
+func (c *responseCache) fetch(ctx context.Context, tenantID, provider, indicatorType, indicator string, load func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if c == nil {
+		return load()
+	}
+
+	key := tenantID + "|" + provider + "|" + indicatorType + "|" + indicator
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && now.After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	metrics.RecordCacheLookup(provider, ok)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", ok))
+	if ok {
+		return entry.data, nil
+	}
+
+	data, err := load()
+	if err != nil || data == nil {
+		return data, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxCacheEntries {
+		c.pruneLocked(now)
+	}
+	c.entries[key] = cacheEntry{data: data, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return data, nil
+}
+
+// pruneLocked drops expired entries, or the oldest one when none have
+// expired, so the cache stays within maxCacheEntries. Callers must hold c.mu.
+func (c *responseCache) pruneLocked(now time.Time) {
+	var oldest string
+	var oldestExpires time.Time
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(oldestExpires) {
+			oldest, oldestExpires = key, entry.expires
+		}
+	}
+	if len(c.entries) >= maxCacheEntries {
+		delete(c.entries, oldest)
+	}
+}
@@ -1,9 +1,8 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 )
@@ -20,53 +19,48 @@ func NewOTXService(apiKey string) *OTXService {
 	}
 }
 
-func (o *OTXService) AnalyzeIP(ip string) (map[string]interface{}, error) {
+// withAPIKey returns a copy using apiKey, sharing the HTTP client. An empty
+// key returns the receiver unchanged.
+func (o *OTXService) withAPIKey(apiKey string) *OTXService {
+	if apiKey == "" {
+		return o
+	}
+	clone := *o
+	clone.apiKey = apiKey
+	return &clone
+}
+
+func (o *OTXService) AnalyzeIP(ctx context.Context, ip string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("https://otx.alienvault.com/api/v1/indicators/IPv4/%s/general", ip)
-	return o.makeRequest(url)
+	return o.makeRequest(ctx, url)
 }
 
-func (o *OTXService) AnalyzeDomain(domain string) (map[string]interface{}, error) {
+func (o *OTXService) AnalyzeDomain(ctx context.Context, domain string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("https://otx.alienvault.com/api/v1/indicators/domain/%s/general", domain)
-	return o.makeRequest(url)
+	return o.makeRequest(ctx, url)
 }
 
-func (o *OTXService) AnalyzeHash(hash string) (map[string]interface{}, error) {
+func (o *OTXService) AnalyzeHash(ctx context.Context, hash string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("https://otx.alienvault.com/api/v1/indicators/file/%s/general", hash)
-	return o.makeRequest(url)
+	return o.makeRequest(ctx, url)
 }
 
-func (o *OTXService) AnalyzeURL(urlToCheck string) (map[string]interface{}, error) {
+func (o *OTXService) AnalyzeURL(ctx context.Context, urlToCheck string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("https://otx.alienvault.com/api/v1/indicators/url/%s/general", urlToCheck)
-	return o.makeRequest(url)
+	return o.makeRequest(ctx, url)
 }
 
-func (o *OTXService) makeRequest(url string) (map[string]interface{}, error) {
+func (o *OTXService) makeRequest(ctx context.Context, url string) (map[string]interface{}, error) {
 	if o.apiKey == "" {
 		return map[string]interface{}{"error": "API key not configured"}, nil
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("X-OTX-API-KEY", o.apiKey)
 
-	resp, err := o.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return fetchJSON(o.client, providerOTX, req)
 }
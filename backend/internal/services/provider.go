@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Provider names as they appear in SourceData and metric labels.
+const (
+	providerVirusTotal = "VirusTotal"
+	providerOTX        = "AlienVault OTX"
+	providerAbuseIPDB  = "AbuseIPDB"
+)
+
+var providerLog = logging.For("providers")
+
+// statusError comes with the decoded body of a non-2xx response, such as a
+// quota or authentication error, so the body isn't cached as a result.
+type statusError struct {
+	provider string
+	status   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d", e.provider, e.status)
+}
+
+// fetchJSON executes a provider request and decodes the JSON body. Every call
+// is timed and classified for the provider metrics, and any quota header the
+// provider returns is exported as a gauge. Non-2xx bodies are returned with a
+// *statusError.
+func fetchJSON(client *http.Client, provider string, req *http.Request) (map[string]interface{}, error) {
+	ctx := req.Context()
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		metrics.ObserveProviderCall(provider, time.Since(start), classifyError(err))
+		providerLog.WarnContext(ctx, "provider request failed",
+			"provider", provider, logging.Request(req), "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		if v, err := strconv.ParseFloat(remaining, 64); err == nil {
+			metrics.SetProviderQuota(provider, v)
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		metrics.ObserveProviderCall(provider, time.Since(start), classifyError(err))
+		providerLog.WarnContext(ctx, "provider response read failed",
+			"provider", provider, "status", resp.StatusCode, "error", err)
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		metrics.ObserveProviderCall(provider, time.Since(start), "decode")
+		providerLog.WarnContext(ctx, "provider returned invalid JSON",
+			"provider", provider, "status", resp.StatusCode, "error", err)
+		return nil, err
+	}
+
+	class := classifyStatus(resp.StatusCode)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	metrics.ObserveProviderCall(provider, time.Since(start), class)
+
+	level := slog.LevelDebug
+	if class != "" {
+		level = slog.LevelWarn
+	}
+	providerLog.Log(ctx, level, "provider response",
+		"provider", provider, logging.Request(req),
+		"status", resp.StatusCode, "duration", time.Since(start))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result, &statusError{provider: provider, status: resp.StatusCode}
+	}
+	return result, nil
+}
+
+func classifyStatus(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "unauthorized"
+	case status >= 500:
+		return "http_5xx"
+	case status >= 400:
+		return "http_4xx"
+	}
+	return ""
+}
+
+func classifyError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "network"
+}
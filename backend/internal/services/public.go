@@ -0,0 +1,56 @@
+package services
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// internalSuffixes mark names that only resolve inside an organisation.
+var internalSuffixes = []string{".local", ".localhost", ".internal", ".intranet", ".lan", ".corp", ".home.arpa"}
+
+// isPublicIndicator reports whether an indicator describes something on the
+// public internet, and so may be shared between tenants. Private, loopback
+// and link-local addresses and internal host names are never shared.
+func isPublicIndicator(indicatorType, indicator string) bool {
+	switch indicatorType {
+	case "ip":
+		return isPublicIP(indicator)
+	case "domain":
+		return isPublicHost(indicator)
+	case "url":
+		u, err := url.Parse(indicator)
+		if err != nil || u.Hostname() == "" {
+			return false
+		}
+		return isPublicHost(u.Hostname())
+	case "hash":
+		return true
+	}
+	return false
+}
+
+func isPublicIP(s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+func isPublicHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return isPublicIP(host)
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+)
+
+var queueLog = logging.For("queue")
+
+// analysisTimeout bounds one queued analysis.
+const analysisTimeout = 2 * time.Minute
+
+// QueueOptions tunes the background analysis queue. Zero fields take
+// defaults.
+type QueueOptions struct {
+	Workers int
+	Size    int
+}
+
+type analysisJob struct {
+	tenantID, indicator, indicatorType string
+	// neighbor marks graph enrichment, which doesn't enrich further.
+	neighbor bool
+}
+
+// AnalysisQueue runs analyses in the background for bulk sources such as
+// imports, with a few workers so provider quotas aren't exhausted at once.
+// Jobs live in memory: a restart drops what hasn't run yet.
+type AnalysisQueue struct {
+	aggregator *Aggregator
+
+	mu      sync.Mutex
+	jobs    chan analysisJob
+	pending map[analysisJob]bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newAnalysisQueue(a *Aggregator) *AnalysisQueue {
+	return &AnalysisQueue{aggregator: a, pending: make(map[analysisJob]bool)}
+}
+
+// Start launches the workers. Enqueue refuses jobs until then.
+func (q *AnalysisQueue) Start(opts QueueOptions) {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.Size <= 0 {
+		opts.Size = 10000
+	}
+
+	q.mu.Lock()
+	q.jobs = make(chan analysisJob, opts.Size)
+	q.stop = make(chan struct{})
+	q.mu.Unlock()
+	for i := 0; i < opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	queueLog.Info("analysis queue started", "workers", opts.Workers, "size", opts.Size)
+}
+
+// Enqueue schedules an analysis. An indicator already waiting is not queued
+// twice. It reports false when the queue is full or not running.
+func (q *AnalysisQueue) Enqueue(tenantID, indicator, indicatorType string) bool {
+	return q.enqueue(analysisJob{tenantID: tenantID, indicator: indicator, indicatorType: indicatorType})
+}
+
+func (q *AnalysisQueue) enqueue(job analysisJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.jobs == nil {
+		return false
+	}
+	if q.pending[job] {
+		return true
+	}
+	select {
+	case q.jobs <- job:
+		q.pending[job] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops taking jobs and waits for running analyses until ctx is
+// done. Jobs still queued are dropped.
+func (q *AnalysisQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if q.stop == nil {
+		q.mu.Unlock()
+		return nil
+	}
+	close(q.stop)
+	q.jobs = nil
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *AnalysisQueue) work() {
+	defer q.wg.Done()
+
+	q.mu.Lock()
+	jobs, stop := q.jobs, q.stop
+	q.mu.Unlock()
+	for {
+		select {
+		case <-stop:
+			return
+		case job := <-jobs:
+			q.mu.Lock()
+			delete(q.pending, job)
+			q.mu.Unlock()
+
+			ctx, cancel := context.WithTimeout(context.Background(), analysisTimeout)
+			if job.neighbor {
+				ctx = context.WithValue(ctx, neighborKey{}, true)
+			}
+			if _, err := q.aggregator.AnalyzeIndicator(ctx, job.tenantID, job.indicator, job.indicatorType); err != nil {
+				queueLog.Warn("queued analysis failed", "tenant", job.tenantID, "indicator_type", job.indicatorType, "error", err)
+			}
+			cancel()
+		}
+	}
+}
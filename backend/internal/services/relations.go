@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/ioc"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+// VirusTotal relationships requested with each lookup. Only descriptors
+// (type and ID) come back, which is all the graph needs.
+var vtRelationships = map[string]string{
+	"ip":     "resolutions,communicating_files",
+	"domain": "resolutions,communicating_files",
+	"hash":   "contacted_domains,contacted_ips,contacted_urls,itw_urls",
+}
+
+// maxEdgesPerRelation bounds what one provider result adds to the graph.
+const maxEdgesPerRelation = 40
+
+// vtTypes maps VirusTotal object types onto indicator types.
+var vtTypes = map[string]string{
+	"ip_address": ioc.TypeIP,
+	"domain":     ioc.TypeDomain,
+	"file":       ioc.TypeHash,
+	"url":        ioc.TypeURL,
+}
+
+// extractRelations reads the relationships in a threat's VirusTotal and
+// OTX results as graph edges.
+func extractRelations(threat *models.ThreatIndicator) []models.GraphEdge {
+	now := time.Now()
+	var edges []models.GraphEdge
+	add := func(source, from, fromType, relation, to, toType, label string) {
+		if from == "" || to == "" || from == to {
+			return
+		}
+		if (toType != models.NodePulse && !ioc.Valid(toType, to)) || !ioc.Valid(fromType, from) {
+			return
+		}
+		edges = append(edges, models.GraphEdge{
+			TenantID:  threat.TenantID,
+			From:      from,
+			FromType:  fromType,
+			To:        to,
+			ToType:    toType,
+			Relation:  relation,
+			ToLabel:   label,
+			Sources:   []string{source},
+			FirstSeen: now,
+			LastSeen:  now,
+		})
+	}
+
+	for _, source := range threat.Sources {
+		switch source.Name {
+		case providerVirusTotal:
+			vtRelations(threat, source.Details, func(relation, from, fromType, to, toType string) {
+				add(providerVirusTotal, from, fromType, relation, to, toType, "")
+			})
+		case providerOTX:
+			for _, pulse := range otxPulses(source.Details) {
+				add(providerOTX, threat.Indicator, threat.Type, models.RelationRelatedPulse, pulse.id, models.NodePulse, pulse.name)
+			}
+		}
+	}
+	return edges
+}
+
+func vtRelations(threat *models.ThreatIndicator, details map[string]interface{}, add func(relation, from, fromType, to, toType string)) {
+	data, _ := details["data"].(map[string]interface{})
+	relationships, _ := data["relationships"].(map[string]interface{})
+	self, selfType := threat.Indicator, threat.Type
+
+	for name, rel := range relationships {
+		rel, _ := rel.(map[string]interface{})
+		items, _ := rel["data"].([]interface{})
+		if len(items) > maxEdgesPerRelation {
+			items = items[:maxEdgesPerRelation]
+		}
+		for _, item := range items {
+			item, _ := item.(map[string]interface{})
+			id, _ := item["id"].(string)
+			objType := vtTypes[stringValue(item, "type")]
+			if name == "contacted_urls" || name == "itw_urls" {
+				// URL objects are identified by a hash of the URL.
+				attrs, _ := item["context_attributes"].(map[string]interface{})
+				id, objType = stringValue(attrs, "url"), ioc.TypeURL
+			}
+
+			switch name {
+			case "resolutions":
+				// A resolution's ID is the IP followed by the host name.
+				if selfType == ioc.TypeIP {
+					add(models.RelationResolvesTo, strings.TrimPrefix(id, self), ioc.TypeDomain, self, ioc.TypeIP)
+				} else {
+					add(models.RelationResolvesTo, self, ioc.TypeDomain, strings.TrimSuffix(id, self), ioc.TypeIP)
+				}
+			case "communicating_files":
+				add(models.RelationContactedBy, self, selfType, id, ioc.TypeHash)
+			case "contacted_domains", "contacted_ips", "contacted_urls":
+				add(models.RelationContactedBy, id, objType, self, ioc.TypeHash)
+			case "itw_urls":
+				add(models.RelationDownloadedFrom, self, ioc.TypeHash, id, ioc.TypeURL)
+			}
+		}
+	}
+}
+
+type otxPulse struct {
+	id, name string
+}
+
+func otxPulses(details map[string]interface{}) []otxPulse {
+	info, _ := details["pulse_info"].(map[string]interface{})
+	items, _ := info["pulses"].([]interface{})
+	if len(items) > maxEdgesPerRelation {
+		items = items[:maxEdgesPerRelation]
+	}
+	var pulses []otxPulse
+	for _, item := range items {
+		item, _ := item.(map[string]interface{})
+		if id := stringValue(item, "id"); id != "" {
+			pulses = append(pulses, otxPulse{id: id, name: stringValue(item, "name")})
+		}
+	}
+	return pulses
+}
+
+func stringValue(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+type neighborKey struct{}
+
+// enrichNeighbors queues analyses of the first-hop neighbours of an
+// analyzed indicator that the tenant hasn't analyzed yet, up to the
+// enrichment budget. Neighbours' own analyses don't enrich further.
+func (a *Aggregator) enrichNeighbors(ctx context.Context, tenantID, indicator string, edges []models.GraphEdge) {
+	if a.enrichBudget <= 0 || ctx.Value(neighborKey{}) != nil {
+		return
+	}
+
+	types := map[string]string{}
+	var candidates []string
+	for _, e := range edges {
+		value, valueType := e.To, e.ToType
+		if e.To == indicator {
+			value, valueType = e.From, e.FromType
+		}
+		if valueType == models.NodePulse || types[value] != "" || !isPublicIndicator(valueType, value) {
+			continue
+		}
+		types[value] = valueType
+		candidates = append(candidates, value)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	known, err := a.db.FindKnownThreats(ctx, tenantID, candidates, nil)
+	if err != nil {
+		aggregatorLog.WarnContext(ctx, "failed to look up graph neighbours", "error", err)
+		return
+	}
+	for _, t := range known {
+		delete(types, t.Indicator)
+	}
+
+	queued := 0
+	for _, value := range candidates {
+		if queued >= a.enrichBudget {
+			break
+		}
+		if types[value] != "" && a.queue.enqueue(analysisJob{tenantID: tenantID, indicator: value, indicatorType: types[value], neighbor: true}) {
+			queued++
+		}
+	}
+	if queued > 0 {
+		aggregatorLog.DebugContext(ctx, "queued graph neighbours for analysis", "queued", queued)
+	}
+}
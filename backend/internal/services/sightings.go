@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/scoring"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SightedReputations are the reputations whose sightings raise
+// indicator_sighted events.
+var SightedReputations = []string{"malicious", "suspicious"}
+
+type sightedIndicator struct {
+	tenantID, indicator string
+}
+
+// RecordSightings stores sightings and adds them to the stored analyses of
+// the sighted indicators, which are rescored. Sightings of malicious or
+// suspicious indicators raise indicator_sighted events, and a rescore that
+// changes the reputation raises reputation_changed. Sightings of
+// indicators the tenant hasn't analyzed are stored all the same.
+func (a *Aggregator) RecordSightings(ctx context.Context, sightings []models.Sighting) error {
+	if err := a.db.CreateSightings(ctx, sightings); err != nil {
+		return err
+	}
+
+	totals := map[sightedIndicator]*models.Sighting{}
+	for i := range sightings {
+		s := &sightings[i]
+		key := sightedIndicator{s.TenantID, s.Indicator}
+		if total := totals[key]; total != nil {
+			total.Count += s.Count
+			if s.ObservedAt.After(total.ObservedAt) {
+				total.ObservedAt = s.ObservedAt
+			}
+		} else {
+			totals[key] = &models.Sighting{Count: s.Count, ObservedAt: s.ObservedAt}
+		}
+	}
+
+	threats := make(map[sightedIndicator]*models.ThreatIndicator, len(totals))
+	for key, total := range totals {
+		threat, err := a.db.AddThreatSightings(ctx, key.tenantID, key.indicator, total.Count, total.ObservedAt)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := a.rescore(ctx, threat); err != nil {
+			return err
+		}
+		threats[key] = threat
+	}
+
+	for i := range sightings {
+		s := &sightings[i]
+		threat := threats[sightedIndicator{s.TenantID, s.Indicator}]
+		if threat == nil || !slices.Contains(SightedReputations, threat.Reputation) {
+			continue
+		}
+		a.bus.Publish(ctx, events.Event{
+			Type:          events.IndicatorSighted,
+			TenantID:      s.TenantID,
+			Indicator:     s.Indicator,
+			IndicatorType: s.IndicatorType,
+			Threat:        threat,
+			Sighting:      s,
+		})
+	}
+	return nil
+}
+
+// rescore applies a threat's sightings to its providers' score. Threats
+// without provider results keep the score they were stored with.
+func (a *Aggregator) rescore(ctx context.Context, threat *models.ThreatIndicator) error {
+	if len(threat.Sources) == 0 || threat.LastSighted == nil {
+		return nil
+	}
+	riskScore := scoring.ApplySightings(scoring.CalculateRiskScore(threat.Sources), threat.SightingCount, *threat.LastSighted, time.Now())
+	if riskScore == threat.RiskScore {
+		return nil
+	}
+	reputation := scoring.DetermineReputation(riskScore)
+	if err := a.db.UpdateThreatScore(ctx, threat.TenantID, threat.Indicator, riskScore, reputation); err != nil {
+		return err
+	}
+
+	previousReputation := threat.Reputation
+	threat.RiskScore, threat.Reputation = riskScore, reputation
+	if previousReputation != reputation {
+		a.bus.Publish(ctx, events.Event{
+			Type:               events.ReputationChanged,
+			TenantID:           threat.TenantID,
+			Indicator:          threat.Indicator,
+			IndicatorType:      threat.Type,
+			Threat:             threat,
+			PreviousReputation: previousReputation,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
+)
+
+var auditLog = logging.For("audit")
+
+// SubscribeMetrics records analysis metrics from bus.
+func SubscribeMetrics(bus *events.Bus) {
+	bus.Subscribe("metrics", events.SubscribeOptions{
+		Types:        []string{events.AnalysisCompleted},
+		DropWhenFull: true,
+	}, func(e events.Event) {
+		metrics.ObserveAnalysis(e.IndicatorType, e.Threat.RiskScore, e.Threat.Reputation)
+	})
+}
+
+// SubscribeAudit writes an audit log line for every change to stored
+// indicators: completed analyses, reputation changes and deletions.
+func SubscribeAudit(bus *events.Bus) {
+	bus.Subscribe("audit", events.SubscribeOptions{
+		Types: []string{events.AnalysisCompleted, events.ReputationChanged, events.IndicatorDeleted, events.ListMatched},
+	}, func(e events.Event) {
+		attrs := []any{
+			"event", e.Type,
+			"tenant_id", e.TenantID,
+			"indicator", e.Indicator,
+			"indicator_type", e.IndicatorType,
+		}
+		if e.Threat != nil {
+			attrs = append(attrs, "risk_score", e.Threat.RiskScore, "reputation", e.Threat.Reputation)
+		}
+		if e.PreviousReputation != "" {
+			attrs = append(attrs, "previous_reputation", e.PreviousReputation)
+		}
+		if e.List != "" {
+			attrs = append(attrs, "list", e.List)
+		}
+		auditLog.InfoContext(e.Context(), "indicator "+e.Type, attrs...)
+	})
+}
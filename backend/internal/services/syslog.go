@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/scoring"
+)
+
+var syslogLog = logging.For("syslog")
+
+const (
+	syslogVendor  = "ATIA"
+	syslogProduct = "ATIA"
+	syslogVersion = "1.0"
+	syslogTimeout = 5 * time.Second
+)
+
+// SyslogOptions configures a SyslogSink.
+type SyslogOptions struct {
+	Network string // udp, tcp or tls
+	Address string // host:port
+	Format  string // cef or leef
+	// Facility is the syslog facility number, e.g. 16 for local0.
+	Facility int
+	AppName  string
+	// CAFile verifies the collector's certificate for tls; empty uses the
+	// system roots.
+	CAFile string
+}
+
+// SyslogSink writes analysis events as RFC 5424 syslog messages with CEF or
+// LEEF bodies, for SIEMs that ingest syslog rather than webhooks. TCP and TLS
+// use octet-counting framing (RFC 6587 / RFC 5425).
+type SyslogSink struct {
+	opts     SyslogOptions
+	hostname string
+	tls      *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewSyslogSink(opts SyslogOptions) (*SyslogSink, error) {
+	switch opts.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", opts.Network)
+	}
+	switch opts.Format {
+	case "cef", "leef":
+	default:
+		return nil, fmt.Errorf("unknown syslog format %q", opts.Format)
+	}
+	if opts.Facility < 0 || opts.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", opts.Facility)
+	}
+	if opts.AppName == "" {
+		opts.AppName = "atia"
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &SyslogSink{opts: opts, hostname: hostname}
+	if opts.Network == "tls" {
+		host, _, err := net.SplitHostPort(opts.Address)
+		if err != nil {
+			return nil, err
+		}
+		s.tls = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if opts.CAFile != "" {
+			pem, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("syslog CA file contains no certificates")
+			}
+			s.tls.RootCAs = pool
+		}
+	}
+	return s, nil
+}
+
+// Subscribe writes completed analyses and reputation changes published on
+// bus. Events are dropped rather than slowing analyses down when the
+// collector can't keep up.
+func (s *SyslogSink) Subscribe(bus *events.Bus) {
+	bus.Subscribe("syslog", events.SubscribeOptions{
+		Types:        []string{events.AnalysisCompleted, events.ReputationChanged},
+		Buffer:       1024,
+		DropWhenFull: true,
+	}, func(e events.Event) {
+		_ = s.Dispatch(e.Context(), e)
+	})
+}
+
+// Dispatch writes the event. Connection failures are retried once on a fresh
+// connection, then logged and dropped.
+func (s *SyslogSink) Dispatch(ctx context.Context, event events.Event) error {
+	msg := s.message(event, time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = s.write(msg); err == nil {
+			return nil
+		}
+		s.closeConn()
+	}
+	syslogLog.ErrorContext(ctx, "failed to send syslog message", "address", s.opts.Address, "event", event.Type, "error", err)
+	return err
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeConn()
+}
+
+func (s *SyslogSink) write(msg string) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: syslogTimeout}
+		var err error
+		if s.opts.Network == "tls" {
+			s.conn, err = tls.DialWithDialer(dialer, "tcp", s.opts.Address, s.tls)
+		} else {
+			s.conn, err = dialer.Dial(s.opts.Network, s.opts.Address)
+		}
+		if err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+
+	if s.opts.Network != "udp" {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout)); err != nil {
+		return err
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *SyslogSink) closeConn() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// syslogSeverity maps ATIA severities onto RFC 5424 severities.
+var syslogSeverity = map[string]int{
+	"critical": 2, // critical
+	"high":     3, // error
+	"medium":   4, // warning
+	"low":      6, // informational
+}
+
+// message renders the full RFC 5424 line:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG
+func (s *SyslogSink) message(event events.Event, now time.Time) string {
+	severity := scoring.Severity(event.Threat.RiskScore)
+	pri := s.opts.Facility*8 + syslogSeverity[severity]
+
+	var body string
+	if s.opts.Format == "leef" {
+		body = leefMessage(event, now)
+	} else {
+		body = cefMessage(event, now)
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		pri, now.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, s.opts.AppName, os.Getpid(), notificationType(event.Type), body)
+}
+
+// eventName is the human-readable event name used in CEF and LEEF.
+func eventName(eventType string) string {
+	switch eventType {
+	case events.AnalysisCompleted:
+		return "Threat analyzed"
+	case events.ReputationChanged:
+		return "Threat reputation changed"
+	}
+	return eventType
+}
+
+// indicatorField picks the standard key for the indicator value; CEF and
+// LEEF share these names.
+func indicatorField(t *models.ThreatIndicator) string {
+	switch t.Type {
+	case "ip":
+		return "dst"
+	case "domain":
+		return "dhost"
+	case "url":
+		return "request"
+	case "hash":
+		return "fileHash"
+	}
+	return "msg"
+}
+
+func cefSeverity(riskScore float64) int {
+	return int(math.Min(10, math.Round(riskScore/10)))
+}
+
+// cefExtensionEscape escapes extension values; header fields are constants.
+var cefExtensionEscape = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+
+// cefMessage renders ArcSight CEF:0. Indicator details use the standard
+// keys (dst, dhost, request, fileHash, cat, rt); the rest go into labelled
+// custom fields.
+func cefMessage(event events.Event, now time.Time) string {
+	t := event.Threat
+	ext := [][2]string{
+		{"rt", strconv.FormatInt(now.UnixMilli(), 10)},
+		{indicatorField(t), t.Indicator},
+		{"cat", t.Reputation},
+		{"cs1Label", "indicatorType"}, {"cs1", t.Type},
+		{"cs2Label", "sources"}, {"cs2", sourceVerdicts(t)},
+		{"cs3Label", "tags"}, {"cs3", strings.Join(t.Tags, ",")},
+		{"cs4Label", "tenant"}, {"cs4", t.TenantID},
+		{"cn1Label", "riskScore"}, {"cn1", strconv.FormatFloat(t.RiskScore, 'f', 1, 64)},
+	}
+	if event.PreviousReputation != "" {
+		ext = append(ext, [2]string{"cs5Label", "previousReputation"}, [2]string{"cs5", event.PreviousReputation})
+	}
+
+	parts := make([]string, 0, len(ext))
+	for _, kv := range ext {
+		parts = append(parts, kv[0]+"="+cefExtensionEscape.Replace(kv[1]))
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		syslogVendor, syslogProduct, syslogVersion,
+		notificationType(event.Type), eventName(event.Type),
+		cefSeverity(t.RiskScore), strings.Join(parts, " "))
+}
+
+// leefValueEscape keeps values free of the tab delimiter and line breaks.
+var leefValueEscape = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// leefMessage renders IBM QRadar LEEF:2.0 with tab-delimited attributes.
+func leefMessage(event events.Event, now time.Time) string {
+	t := event.Threat
+	attrs := [][2]string{
+		{"devTime", now.UTC().Format("Jan 02 2006 15:04:05.000 UTC")},
+		{"devTimeFormat", "MMM dd yyyy HH:mm:ss.SSS z"},
+		{"cat", t.Reputation},
+		{"sev", strconv.Itoa(max(1, cefSeverity(t.RiskScore)))},
+		{indicatorField(t), t.Indicator},
+		{"indicator", t.Indicator},
+		{"indicatorType", t.Type},
+		{"riskScore", strconv.FormatFloat(t.RiskScore, 'f', 1, 64)},
+		{"reputation", t.Reputation},
+		{"sources", sourceVerdicts(t)},
+		{"tags", strings.Join(t.Tags, ",")},
+		{"tenant", t.TenantID},
+	}
+	if event.PreviousReputation != "" {
+		attrs = append(attrs, [2]string{"previousReputation", event.PreviousReputation})
+	}
+
+	parts := make([]string, 0, len(attrs))
+	for _, kv := range attrs {
+		parts = append(parts, kv[0]+"="+leefValueEscape.Replace(kv[1]))
+	}
+
+	return fmt.Sprintf("LEEF:2.0|%s|%s|%s|%s|x09|%s",
+		syslogVendor, syslogProduct, syslogVersion, notificationType(event.Type), strings.Join(parts, "\t"))
+}
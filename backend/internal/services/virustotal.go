@@ -1,9 +1,8 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,7 +14,7 @@ type VirusTotalService struct {
 	client *http.Client
 }
 
-func (v *VirusTotalService) AnalyzeURL(urlToCheck string) (map[string]interface{}, error) {
+func (v *VirusTotalService) AnalyzeURL(ctx context.Context, urlToCheck string) (map[string]interface{}, error) {
 	// VirusTotal URL analysis endpoint
 	endpoint := "https://www.virustotal.com/api/v3/urls"
 
@@ -24,7 +23,7 @@ func (v *VirusTotalService) AnalyzeURL(urlToCheck string) (map[string]interface{
 	formData.Set("url", urlToCheck)
 	body := strings.NewReader(formData.Encode())
 
-	req, err := http.NewRequest("POST", endpoint, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
 	if err != nil {
 		return nil, err
 	}
@@ -32,23 +31,7 @@ func (v *VirusTotalService) AnalyzeURL(urlToCheck string) (map[string]interface{
 	req.Header.Add("x-apikey", v.apiKey)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := v.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(responseBody, &result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return fetchJSON(v.client, providerVirusTotal, req)
 }
 
 func NewVirusTotalService(apiKey string) *VirusTotalService {
@@ -58,44 +41,39 @@ func NewVirusTotalService(apiKey string) *VirusTotalService {
 	}
 }
 
-func (v *VirusTotalService) AnalyzeIP(ip string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("https://www.virustotal.com/api/v3/ip_addresses/%s", ip)
-	return v.makeRequest(url)
+// withAPIKey returns a copy using apiKey, sharing the HTTP client. An empty
+// key returns the receiver unchanged.
+func (v *VirusTotalService) withAPIKey(apiKey string) *VirusTotalService {
+	if apiKey == "" {
+		return v
+	}
+	clone := *v
+	clone.apiKey = apiKey
+	return &clone
 }
 
-func (v *VirusTotalService) AnalyzeDomain(domain string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("https://www.virustotal.com/api/v3/domains/%s", domain)
-	return v.makeRequest(url)
+func (v *VirusTotalService) AnalyzeIP(ctx context.Context, ip string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("https://www.virustotal.com/api/v3/ip_addresses/%s?relationships=%s", ip, vtRelationships["ip"])
+	return v.makeRequest(ctx, url)
 }
 
-func (v *VirusTotalService) AnalyzeHash(hash string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("https://www.virustotal.com/api/v3/files/%s", hash)
-	return v.makeRequest(url)
+func (v *VirusTotalService) AnalyzeDomain(ctx context.Context, domain string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("https://www.virustotal.com/api/v3/domains/%s?relationships=%s", domain, vtRelationships["domain"])
+	return v.makeRequest(ctx, url)
 }
 
-func (v *VirusTotalService) makeRequest(url string) (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Add("x-apikey", v.apiKey)
-
-	resp, err := v.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (v *VirusTotalService) AnalyzeHash(ctx context.Context, hash string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("https://www.virustotal.com/api/v3/files/%s?relationships=%s", hash, vtRelationships["hash"])
+	return v.makeRequest(ctx, url)
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (v *VirusTotalService) makeRequest(ctx context.Context, url string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
+	req.Header.Add("x-apikey", v.apiKey)
 
-	return result, nil
+	return fetchJSON(v.client, providerVirusTotal, req)
 }
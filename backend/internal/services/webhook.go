@@ -2,49 +2,216 @@ package services
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/AEX0TIC/ATIA/backend/internal/database"
+	"github.com/AEX0TIC/ATIA/backend/internal/egress"
+	"github.com/AEX0TIC/ATIA/backend/internal/events"
+	"github.com/AEX0TIC/ATIA/backend/internal/logging"
+	"github.com/AEX0TIC/ATIA/backend/internal/metrics"
 	"github.com/AEX0TIC/ATIA/backend/internal/models"
+	"github.com/AEX0TIC/ATIA/backend/internal/scoring"
+	"github.com/AEX0TIC/ATIA/backend/internal/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var webhookLog = logging.For("webhook")
+
+// Headers set on every delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret, prefixed with
+// "sha256=".
+const (
+	HeaderWebhookEvent     = "X-ATIA-Event"
+	HeaderWebhookDelivery  = "X-ATIA-Delivery"
+	HeaderWebhookTimestamp = "X-ATIA-Timestamp"
+	HeaderWebhookSignature = "X-ATIA-Signature"
 )
 
+const (
+	// deliveryLease must outlast one HTTP attempt (client timeout).
+	deliveryLease = time.Minute
+	pollInterval  = 2 * time.Second
+)
+
+// DeliveryOptions tunes the delivery queue. Zero fields take defaults.
+type DeliveryOptions struct {
+	Workers     int
+	MaxAttempts int
+	// Attempt n waits RetryBase * 2^(n-1), capped at RetryMax, with jitter.
+	RetryBase time.Duration
+	RetryMax  time.Duration
+	// SigningSecret signs deliveries to the default webhook URL and to
+	// subscriptions without a secret of their own. Empty leaves them unsigned.
+	SigningSecret string
+}
+
+// WebhookService queues events for a tenant's stored subscriptions in Mongo
+// and delivers them from a pool of workers, retrying with backoff. A
+// tenant's WebhookURL, or failing that the deployment-wide n8n URL, acts as
+// an extra subscription that receives every event.
 type WebhookService struct {
+	db            *database.MongoDB
 	n8nWebhookURL string
 	client        *http.Client
+	// guarded sends to tenant-supplied URLs, which may only reach public
+	// addresses; the operator's n8n URL uses client.
+	guarded *http.Client
+	opts    DeliveryOptions
+
+	wake   chan struct{}
+	stop   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 type WebhookPayload struct {
-	EventType     string                  `json:"event_type"`
-	Timestamp     time.Time               `json:"timestamp"`
-	Threat        *models.ThreatIndicator `json:"threat"`
-	RiskSeverity  string                  `json:"risk_severity"`
-	SourcesCount  int                     `json:"sources_count"`
-	MaliciousVote int                     `json:"malicious_vote"`
+	EventType      string                  `json:"event_type"`
+	Timestamp      time.Time               `json:"timestamp"`
+	SubscriptionID string                  `json:"subscription_id,omitempty"`
+	Threat         *models.ThreatIndicator `json:"threat"`
+	RiskSeverity   string                  `json:"risk_severity"`
+	SourcesCount   int                     `json:"sources_count"`
+	MaliciousVote  int                     `json:"malicious_vote"`
+	// PreviousReputation is set on reputation_changed events.
+	PreviousReputation string `json:"previous_reputation,omitempty"`
+	// Sighting is set on indicator_sighted events.
+	Sighting *models.Sighting `json:"sighting,omitempty"`
 }
 
-func NewWebhookService(n8nWebhookURL string) *WebhookService {
-	return &WebhookService{
+func NewWebhookService(db *database.MongoDB, n8nWebhookURL string) *WebhookService {
+	w := &WebhookService{
+		db:            db,
 		n8nWebhookURL: n8nWebhookURL,
-		client:        &http.Client{Timeout: 10 * time.Second},
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		guarded: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: otelhttp.NewTransport(egress.Transport()),
+		},
+		wake: make(chan struct{}, 1),
+	}
+	w.setOptions(DeliveryOptions{})
+	return w
+}
+
+func (w *WebhookService) setOptions(opts DeliveryOptions) {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
 	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = 30 * time.Second
+	}
+	if opts.RetryMax <= 0 {
+		opts.RetryMax = time.Hour
+	}
+	w.opts = opts
 }
 
-func (w *WebhookService) TriggerThreatAnalysis(threat *models.ThreatIndicator) error {
-	if w.n8nWebhookURL == "" {
-		return nil // Skip if no webhook configured
+// Start launches the delivery workers. Events dispatched before Start stay
+// queued until it runs.
+func (w *WebhookService) Start(opts DeliveryOptions) {
+	w.setOptions(opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.stop = make(chan struct{})
+	for i := 0; i < w.opts.Workers; i++ {
+		w.wg.Add(1)
+		go w.work(ctx)
 	}
+	webhookLog.Info("webhook delivery started", "workers", w.opts.Workers, "max_attempts", w.opts.MaxAttempts)
+}
+
+// Shutdown stops claiming deliveries and waits for in-flight attempts until
+// ctx is done. Attempts still running then are aborted; their leases expire
+// and they are retried after the next start.
+func (w *WebhookService) Shutdown(ctx context.Context) error {
+	if w.stop == nil {
+		return nil
+	}
+	close(w.stop)
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
 
-	// Determine severity
-	severity := "low"
-	if threat.RiskScore > 70 {
-		severity = "critical"
-	} else if threat.RiskScore > 50 {
-		severity = "high"
-	} else if threat.RiskScore > 30 {
-		severity = "medium"
+	select {
+	case <-done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return ctx.Err()
 	}
+}
+
+// Subscribe queues deliveries for completed analyses, reputation changes
+// and sightings published on bus. The subscription applies backpressure rather than drop
+// events, since deliveries are meant to be reliable.
+func (w *WebhookService) Subscribe(bus *events.Bus) {
+	bus.Subscribe("webhooks", events.SubscribeOptions{
+		Types: []string{events.AnalysisCompleted, events.ReputationChanged, events.IndicatorSighted},
+	}, func(e events.Event) {
+		_ = w.Dispatch(e.Context(), e)
+	})
+}
+
+// notificationType maps bus events onto the event names used in webhook
+// payloads and syslog messages.
+func notificationType(eventType string) string {
+	if eventType == events.AnalysisCompleted {
+		return models.EventThreatAnalyzed
+	}
+	return eventType
+}
+
+// Dispatch queues the event for every matching subscription of the tenant.
+// It only fails if the queue can't be written; delivery happens later.
+func (w *WebhookService) Dispatch(ctx context.Context, event events.Event) error {
+	eventType, threat := notificationType(event.Type), event.Threat
+	tenant, err := loadTenant(ctx, w.db, event.TenantID)
+	if err != nil {
+		webhookLog.ErrorContext(ctx, "failed to load tenant for webhooks", "error", err)
+		return err
+	}
+
+	subs, err := w.db.ListWebhooks(ctx, tenant.ID, true)
+	if err != nil {
+		webhookLog.ErrorContext(ctx, "failed to load webhook subscriptions", "error", err)
+		return fmt.Errorf("load webhook subscriptions: %w", err)
+	}
+
+	fallbackURL := tenant.WebhookURL
+	if fallbackURL == "" {
+		fallbackURL = w.n8nWebhookURL
+	}
+	if fallbackURL != "" {
+		subs = append(subs, models.WebhookSubscription{Name: "default", URL: fallbackURL, Enabled: true})
+	}
+
+	severity := scoring.Severity(threat.RiskScore)
 
 	// Count malicious verdicts
 	maliciousCount := 0
@@ -54,35 +221,261 @@ func (w *WebhookService) TriggerThreatAnalysis(threat *models.ThreatIndicator) e
 		}
 	}
 
-	payload := WebhookPayload{
-		EventType:     "threat_analyzed",
-		Timestamp:     time.Now(),
-		Threat:        threat,
-		RiskSeverity:  severity,
-		SourcesCount:  len(threat.Sources),
-		MaliciousVote: maliciousCount,
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, sub := range subs {
+		if !subscriptionMatches(&sub, eventType, severity, threat) {
+			continue
+		}
+
+		payload := WebhookPayload{
+			EventType:          eventType,
+			Timestamp:          now,
+			Threat:             threat,
+			RiskSeverity:       severity,
+			SourcesCount:       len(threat.Sources),
+			MaliciousVote:      maliciousCount,
+			PreviousReputation: event.PreviousReputation,
+			Sighting:           event.Sighting,
+		}
+		if !sub.ID.IsZero() {
+			payload.SubscriptionID = sub.ID.Hex()
+		}
+		body, contentType, err := renderPayload(&sub, &payload)
+		if err != nil {
+			// A broken template only affects its own subscription.
+			webhookLog.ErrorContext(ctx, "failed to render webhook payload",
+				"subscription", sub.Name, "format", sub.Format, "error", err)
+			continue
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			TenantID:         tenant.ID,
+			SubscriptionID:   sub.ID,
+			SubscriptionName: sub.Name,
+			URL:              sub.URL,
+			EventType:        eventType,
+			ContentType:      contentType,
+			Payload:          string(body),
+			RequestID:        logging.RequestID(ctx),
+			Status:           models.DeliveryPending,
+			MaxAttempts:      w.opts.MaxAttempts,
+			NextAttemptAt:    now,
+			Log:              []models.DeliveryAttempt{},
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		})
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
+	if err := w.db.EnqueueWebhookDeliveries(ctx, deliveries); err != nil {
+		webhookLog.ErrorContext(ctx, "failed to queue webhook deliveries", "event", eventType, "error", err)
+		return fmt.Errorf("queue webhook deliveries: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", w.n8nWebhookURL, bytes.NewReader(body))
+	if len(deliveries) > 0 {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func subscriptionMatches(sub *models.WebhookSubscription, eventType, severity string, threat *models.ThreatIndicator) bool {
+	if len(sub.EventTypes) > 0 && !contains(sub.EventTypes, eventType) {
+		return false
+	}
+	if !scoring.SeverityAtLeast(severity, sub.MinSeverity) {
+		return false
+	}
+	if len(sub.IndicatorTypes) > 0 && !contains(sub.IndicatorTypes, threat.Type) {
+		return false
+	}
+	if len(sub.Tags) > 0 {
+		for _, tag := range threat.Tags {
+			if contains(sub.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *WebhookService) work(ctx context.Context) {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		delivery, err := w.db.ClaimWebhookDelivery(ctx, time.Now(), deliveryLease)
+		if err == nil {
+			w.attempt(ctx, delivery)
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) && ctx.Err() == nil {
+			webhookLog.Error("failed to claim webhook delivery", "error", err)
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-w.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// attempt makes one delivery attempt and records its outcome.
+func (w *WebhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	start := time.Now()
+	statusCode, retry, err := w.send(ctx, delivery)
+	record := models.DeliveryAttempt{
+		At:         time.Now(),
+		StatusCode: statusCode,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+
+	status := models.DeliveryDelivered
+	next := record.At
+	logger := webhookLog.With(
+		"delivery", delivery.ID.Hex(),
+		"subscription", delivery.SubscriptionName,
+		"attempt", delivery.Attempts+1,
+	)
+	switch {
+	case err == nil:
+		metrics.RecordWebhookDelivery(true)
+		logger.Debug("webhook delivered", "status", statusCode)
+	case retry && delivery.Attempts+1 < delivery.MaxAttempts:
+		metrics.RecordWebhookDelivery(false)
+		record.Error = err.Error()
+		status = models.DeliveryPending
+		next = record.At.Add(w.backoff(delivery.Attempts + 1))
+		logger.Warn("webhook delivery failed, will retry", "error", err, "next_attempt_at", next)
+	default:
+		metrics.RecordWebhookDelivery(false)
+		metrics.RecordWebhookDeadLetter()
+		record.Error = err.Error()
+		status = models.DeliveryDead
+		logger.Error("webhook delivery failed permanently", "error", err)
+	}
+
+	// Record even if shutdown aborted the attempt, so it isn't lost.
+	recordCtx := context.WithoutCancel(ctx)
+	if err := w.db.RecordWebhookAttempt(recordCtx, delivery.ID, record, status, next); err != nil {
+		logger.Error("failed to record webhook attempt", "error", err)
+	}
+}
+
+// backoff returns the delay before the given retry, with up to 20% jitter so
+// failed deliveries don't retry in lockstep.
+func (w *WebhookService) backoff(attempt int) time.Duration {
+	delay := w.opts.RetryMax
+	if attempt < 32 {
+		if d := w.opts.RetryBase << (attempt - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+	return delay - time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+// send posts the stored payload. It reports whether a failure is worth
+// retrying: client errors other than 408 and 429 are not.
+func (w *WebhookService) send(ctx context.Context, delivery *models.WebhookDelivery) (statusCode int, retry bool, err error) {
+	ctx, span := tracing.Start(ctx, "webhook deliver",
+		attribute.String("webhook.event", delivery.EventType),
+		attribute.String("webhook.subscription", delivery.SubscriptionName),
+		attribute.Int("webhook.attempt", delivery.Attempts+1),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	// Stored subscriptions are re-read so edits to the URL, headers and
+	// secret apply to queued deliveries.
+	url, secret, headers := delivery.URL, w.opts.SigningSecret, map[string]string(nil)
+	if !delivery.SubscriptionID.IsZero() {
+		sub, err := w.db.GetWebhook(ctx, delivery.TenantID, delivery.SubscriptionID.Hex())
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, false, errors.New("subscription was deleted")
+		}
+		if err != nil {
+			return 0, true, err
+		}
+		if !sub.Enabled {
+			return 0, false, errors.New("subscription is disabled")
+		}
+		url, headers = sub.URL, sub.Headers
+		if sub.Secret != "" {
+			secret = sub.Secret
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader([]byte(delivery.Payload)))
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	contentType := delivery.ContentType
+	if contentType == "" {
+		contentType = contentTypeJSON
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(HeaderWebhookEvent, delivery.EventType)
+	req.Header.Set(HeaderWebhookDelivery, delivery.ID.Hex())
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	if delivery.RequestID != "" {
+		req.Header.Set(logging.RequestIDHeader, delivery.RequestID)
+	}
+	if secret != "" {
+		req.Header.Set(HeaderWebhookSignature, SignWebhook(secret, timestamp, []byte(delivery.Payload)))
+	}
 
-	resp, err := w.client.Do(req)
+	client := w.guarded
+	if url == w.n8nWebhookURL {
+		client = w.client
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, egress.ErrBlocked) {
+		return 0, false, err
+	}
 	if err != nil {
-		return err
+		return 0, true, err
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	// Read response to prevent resource leak
-	io.ReadAll(resp.Body)
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
 
-	return nil
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+		return resp.StatusCode, retry, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, false, nil
+}
+
+// SignWebhook returns the X-ATIA-Signature value for a delivery body.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
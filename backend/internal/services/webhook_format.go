@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	// maxTemplateOutput bounds what a subscription template may render.
+	maxTemplateOutput = 256 << 10
+)
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// parseTemplate parses a subscription template. Templates run over a
+// WebhookPayload, e.g. {{.Threat.Indicator}} or {{json .Threat.Tags}}.
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// CheckWebhookFormat validates a subscription's format settings. Templates
+// are rendered against a sample event so field typos surface up front.
+func CheckWebhookFormat(sub *models.WebhookSubscription) error {
+	switch sub.Format {
+	case models.FormatTemplate:
+		if sub.Template == "" {
+			return errors.New("template is required for the template format")
+		}
+		sample := &WebhookPayload{
+			EventType:    models.EventThreatAnalyzed,
+			Threat:       &models.ThreatIndicator{Indicator: "203.0.113.7", Type: "ip", Tags: []string{}},
+			RiskSeverity: "high",
+		}
+		if _, _, err := renderTemplate(sub, sample); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	case models.FormatPagerDuty:
+		if sub.RoutingKey == "" {
+			return errors.New("routing_key is required for the pagerduty format")
+		}
+	}
+	return nil
+}
+
+// renderPayload builds the request body and content type for the
+// subscription's format.
+func renderPayload(sub *models.WebhookSubscription, payload *WebhookPayload) ([]byte, string, error) {
+	var message interface{}
+	switch sub.Format {
+	case "", models.FormatJSON:
+		message = payload
+	case models.FormatSlack:
+		message = slackMessage(payload)
+	case models.FormatTeams:
+		message = teamsMessage(payload)
+	case models.FormatPagerDuty:
+		message = pagerDutyEvent(sub.RoutingKey, payload)
+	case models.FormatTemplate:
+		return renderTemplate(sub, payload)
+	default:
+		return nil, "", fmt.Errorf("unknown webhook format %q", sub.Format)
+	}
+
+	body, err := json.Marshal(message)
+	return body, contentTypeJSON, err
+}
+
+func renderTemplate(sub *models.WebhookSubscription, payload *WebhookPayload) ([]byte, string, error) {
+	tmpl, err := parseTemplate(sub.Template)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, "", err
+	}
+	if buf.Len() > maxTemplateOutput {
+		return nil, "", errors.New("rendered template exceeds 256 KiB")
+	}
+
+	contentType := sub.ContentType
+	if contentType == "" {
+		contentType = contentTypeJSON
+	}
+	if contentType == contentTypeJSON && !json.Valid(buf.Bytes()) {
+		return nil, "", errors.New("rendered template is not valid JSON")
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+func summary(p *WebhookPayload) string {
+	s := fmt.Sprintf("%s risk %s %s: score %.1f (%s)",
+		strings.ToUpper(p.RiskSeverity), p.Threat.Type, p.Threat.Indicator, p.Threat.RiskScore, p.Threat.Reputation)
+	if p.Sighting != nil {
+		s += " sighted in " + p.Sighting.Source
+		if p.Sighting.Host != "" {
+			s += " on " + p.Sighting.Host
+		}
+	}
+	return s
+}
+
+// sourceVerdicts lists each source as "Name: verdict (score)".
+func sourceVerdicts(t *models.ThreatIndicator) string {
+	parts := make([]string, 0, len(t.Sources))
+	for _, s := range t.Sources {
+		parts = append(parts, fmt.Sprintf("%s: %s (%.0f)", s.Name, s.Verdict, s.Score))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// slackEscape escapes the characters Slack's mrkdwn treats as control
+// sequences.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage renders a Block Kit message for an incoming webhook.
+func slackMessage(p *WebhookPayload) map[string]interface{} {
+	t := p.Threat
+	field := func(name, value string) map[string]interface{} {
+		return map[string]interface{}{"type": "mrkdwn", "text": "*" + name + "*\n" + slackEscape.Replace(value)}
+	}
+
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": strings.ToUpper(p.RiskSeverity) + " risk " + t.Type + " detected"},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"fields": []interface{}{
+				field("Indicator", "`"+t.Indicator+"`"),
+				field("Type", t.Type),
+				field("Risk Score", fmt.Sprintf("%.1f", t.RiskScore)),
+				field("Reputation", t.Reputation),
+				field("Severity", p.RiskSeverity),
+				field("Malicious Votes", fmt.Sprintf("%d of %d", p.MaliciousVote, p.SourcesCount)),
+			},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": "*Sources*\n" + slackEscape.Replace(sourceVerdicts(t))},
+		},
+	}
+	if len(t.Tags) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "context",
+			"elements": []interface{}{
+				map[string]interface{}{"type": "mrkdwn", "text": "Tags: " + slackEscape.Replace(strings.Join(t.Tags, ", "))},
+			},
+		})
+	}
+
+	return map[string]interface{}{"text": summary(p), "blocks": blocks}
+}
+
+// teamsMessage renders an Adaptive Card for a Teams workflow or incoming
+// webhook.
+func teamsMessage(p *WebhookPayload) map[string]interface{} {
+	t := p.Threat
+	color := "Default"
+	switch p.RiskSeverity {
+	case "critical", "high":
+		color = "Attention"
+	case "medium":
+		color = "Warning"
+	}
+	fact := func(title, value string) map[string]interface{} {
+		return map[string]interface{}{"title": title, "value": value}
+	}
+	facts := []interface{}{
+		fact("Indicator", t.Indicator),
+		fact("Type", t.Type),
+		fact("Risk Score", fmt.Sprintf("%.1f", t.RiskScore)),
+		fact("Reputation", t.Reputation),
+		fact("Malicious Votes", fmt.Sprintf("%d of %d", p.MaliciousVote, p.SourcesCount)),
+		fact("Sources", sourceVerdicts(t)),
+	}
+	if len(t.Tags) > 0 {
+		facts = append(facts, fact("Tags", strings.Join(t.Tags, ", ")))
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []interface{}{
+			map[string]interface{}{
+				"type":   "TextBlock",
+				"size":   "Large",
+				"weight": "Bolder",
+				"color":  color,
+				"wrap":   true,
+				"text":   strings.ToUpper(p.RiskSeverity) + " risk " + t.Type + " detected",
+			},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		},
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content":     card,
+			},
+		},
+	}
+}
+
+// pagerDutySeverity maps ATIA severities onto the Events v2 levels.
+var pagerDutySeverity = map[string]string{
+	"critical": "critical",
+	"high":     "error",
+	"medium":   "warning",
+	"low":      "info",
+}
+
+// pagerDutyEvent renders an Events v2 trigger. The dedup key is stable per
+// indicator so re-analyses update the open incident instead of paging again.
+func pagerDutyEvent(routingKey string, p *WebhookPayload) map[string]interface{} {
+	t := p.Threat
+	return map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    "atia:" + t.Type + ":" + t.Indicator,
+		"payload": map[string]interface{}{
+			"summary":   summary(p),
+			"source":    "atia",
+			"severity":  pagerDutySeverity[p.RiskSeverity],
+			"timestamp": p.Timestamp,
+			"component": t.Indicator,
+			"group":     t.Type,
+			"class":     p.EventType,
+			"custom_details": map[string]interface{}{
+				"risk_score":     t.RiskScore,
+				"reputation":     t.Reputation,
+				"sources":        sourceVerdicts(t),
+				"malicious_vote": p.MaliciousVote,
+				"tags":           t.Tags,
+			},
+		},
+	}
+}
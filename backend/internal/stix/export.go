@@ -0,0 +1,304 @@
+package stix
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnsupported is returned for indicators that have no STIX pattern, such
+// as hashes of an unknown length.
+var ErrUnsupported = errors.New("stix: unsupported indicator")
+
+var (
+	// observableNamespace is the namespace the spec mandates for
+	// deterministic cyber-observable IDs.
+	observableNamespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+	// atiaNamespace derives stable IDs for the objects ATIA creates, so
+	// exporting the same analysis twice yields the same objects.
+	atiaNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/AEX0TIC/ATIA"))
+
+	identityCreated = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// IdentityID is the identity every exported object is created by.
+var IdentityID = "identity--" + uuid.NewSHA1(atiaNamespace, []byte("identity")).String()
+
+// ATIAIdentity describes ATIA as the producer of the exported objects.
+func ATIAIdentity() *Identity {
+	return &Identity{
+		common: common{
+			Type:        "identity",
+			SpecVersion: SpecVersion,
+			ID:          IdentityID,
+			Created:     Timestamp(identityCreated),
+			Modified:    Timestamp(identityCreated),
+		},
+		Name:          "ATIA",
+		Description:   "Automated Threat Intelligence Aggregator",
+		IdentityClass: "system",
+	}
+}
+
+// hashAlgorithms maps hex digest lengths to STIX hash algorithm names.
+var hashAlgorithms = map[int]string{
+	32:  "MD5",
+	40:  "SHA-1",
+	64:  "SHA-256",
+	128: "SHA-512",
+}
+
+// HashAlgorithm names the algorithm of a hex digest by its length.
+func HashAlgorithm(hash string) (string, bool) {
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+	name, ok := hashAlgorithms[len(hash)]
+	return name, ok
+}
+
+// observable builds the cyber-observable object for an indicator, with the
+// ID derived from its value as the spec requires.
+func observable(t *models.ThreatIndicator) (*Observable, error) {
+	o := &Observable{SpecVersion: SpecVersion}
+	switch t.Type {
+	case "ip":
+		ip := net.ParseIP(t.Indicator)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: invalid IP %q", ErrUnsupported, t.Indicator)
+		}
+		o.Type = "ipv6-addr"
+		if ip.To4() != nil {
+			o.Type = "ipv4-addr"
+		}
+		o.Value = t.Indicator
+	case "domain":
+		o.Type, o.Value = "domain-name", strings.ToLower(t.Indicator)
+	case "url":
+		o.Type, o.Value = "url", t.Indicator
+	case "hash":
+		hash := strings.ToLower(t.Indicator)
+		algorithm, ok := HashAlgorithm(hash)
+		if !ok {
+			return nil, fmt.Errorf("%w: unrecognized hash %q", ErrUnsupported, t.Indicator)
+		}
+		o.Type, o.Hashes = "file", map[string]string{algorithm: hash}
+	default:
+		return nil, fmt.Errorf("%w: type %q", ErrUnsupported, t.Type)
+	}
+
+	var contributing interface{} = map[string]string{"value": o.Value}
+	if o.Hashes != nil {
+		contributing = map[string]interface{}{"hashes": o.Hashes}
+	}
+	name, err := canonicalJSON(contributing)
+	if err != nil {
+		return nil, err
+	}
+	o.ID = o.Type + "--" + uuid.NewSHA1(observableNamespace, name).String()
+	return o, nil
+}
+
+// canonicalJSON encodes the contributing properties in the JCS form the
+// spec hashes: like json.Marshal (sorted keys, no whitespace) but without
+// escaping &, < and >.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// patternEscape escapes a value for a single-quoted pattern string literal.
+var patternEscape = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// Pattern returns the STIX pattern matching the indicator.
+func Pattern(t *models.ThreatIndicator) (string, error) {
+	o, err := observable(t)
+	if err != nil {
+		return "", err
+	}
+	if o.Type == "file" {
+		for algorithm, hash := range o.Hashes {
+			return fmt.Sprintf("[file:hashes.'%s' = '%s']", algorithm, hash), nil
+		}
+	}
+	return fmt.Sprintf("[%s:value = '%s']", o.Type, patternEscape.Replace(o.Value)), nil
+}
+
+// indicatorTypes maps ATIA reputations onto the indicator-type vocabulary.
+var indicatorTypes = map[string]string{
+	"malicious":  "malicious-activity",
+	"suspicious": "anomalous-activity",
+	"clean":      "benign",
+}
+
+// Confidence maps a risk score onto STIX confidence. Risk scores are already
+// 0-100 and ATIA's reputation thresholds line up with the spec's
+// None/Low/Med/High scale (High is 70 and up, like malicious).
+func Confidence(riskScore float64) int {
+	return int(math.Max(0, math.Min(100, math.Round(riskScore))))
+}
+
+func objectID(objectType string, parts ...string) string {
+	name := objectType + "|" + strings.Join(parts, "|")
+	return objectType + "--" + uuid.NewSHA1(atiaNamespace, []byte(name)).String()
+}
+
+// Objects converts one analysis into its STIX objects: the observable, an
+// observed-data wrapping it, an indicator based on that observation and a
+// note per source verdict (plus one for analyst notes). The ATIA identity
+// they reference is not included.
+func Objects(t *models.ThreatIndicator) ([]interface{}, error) {
+	obs, err := observable(t)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := Pattern(t)
+	if err != nil {
+		return nil, err
+	}
+
+	created, modified := Timestamp(t.FirstSeen), Timestamp(t.LastUpdated)
+	newCommon := func(objectType string, parts ...string) common {
+		return common{
+			Type:         objectType,
+			SpecVersion:  SpecVersion,
+			ID:           objectID(objectType, append([]string{t.TenantID, t.Indicator}, parts...)...),
+			Created:      created,
+			Modified:     modified,
+			CreatedByRef: IdentityID,
+		}
+	}
+
+	indicatorType, ok := indicatorTypes[t.Reputation]
+	if !ok {
+		indicatorType = "unknown"
+	}
+	confidence := Confidence(t.RiskScore)
+	indicator := &Indicator{
+		common:         newCommon("indicator"),
+		Name:           t.Type + " " + t.Indicator,
+		Description:    fmt.Sprintf("ATIA risk score %.1f (%s) from %d sources.", t.RiskScore, t.Reputation, len(t.Sources)),
+		IndicatorTypes: []string{indicatorType},
+		Pattern:        pattern,
+		PatternType:    "stix",
+		ValidFrom:      created,
+	}
+	indicator.Confidence = &confidence
+	indicator.Labels = t.Tags
+
+	observed := &ObservedData{
+		common:         newCommon("observed-data"),
+		FirstObserved:  created,
+		LastObserved:   modified,
+		NumberObserved: 1,
+		ObjectRefs:     []string{obs.ID},
+	}
+
+	objects := []interface{}{obs, observed, indicator, &Relationship{
+		common:           newCommon("relationship", "based-on"),
+		RelationshipType: "based-on",
+		SourceRef:        indicator.ID,
+		TargetRef:        observed.ID,
+	}}
+
+	for _, source := range t.Sources {
+		objects = append(objects, &Note{
+			common:     newCommon("note", source.Name),
+			Abstract:   fmt.Sprintf("%s: %s", source.Name, source.Verdict),
+			Content:    fmt.Sprintf("%s rated %s %s with a score of %.1f.", source.Name, t.Indicator, source.Verdict, source.Score),
+			Authors:    []string{source.Name},
+			ObjectRefs: []string{indicator.ID},
+		})
+	}
+	if t.Notes != "" {
+		objects = append(objects, &Note{
+			common:     newCommon("note", "analyst"),
+			Abstract:   "Analyst notes",
+			Content:    t.Notes,
+			ObjectRefs: []string{indicator.ID},
+		})
+	}
+	return objects, nil
+}
+
+// ObjectIDs returns the IDs of the objects Objects creates for the analysis,
+// plus that of its analyst note, which is stored apart from analyses and so
+// may be missing from t. It is empty for unsupported indicators.
+func ObjectIDs(t *models.ThreatIndicator) []string {
+	objects, err := Objects(t)
+	if err != nil {
+		return []string{}
+	}
+	ids := make([]string, 0, len(objects)+1)
+	for _, o := range objects {
+		ids = append(ids, o.(interface{ ObjectID() string }).ObjectID())
+	}
+	if t.Notes == "" {
+		ids = append(ids, objectID("note", t.TenantID, t.Indicator, "analyst"))
+	}
+	return ids
+}
+
+// Builder collects analyses into a bundle, adding the ATIA identity once.
+type Builder struct {
+	objects []json.RawMessage
+	seen    map[string]bool
+}
+
+func NewBuilder() *Builder {
+	b := &Builder{seen: make(map[string]bool)}
+	_ = b.add(IdentityID, ATIAIdentity())
+	return b
+}
+
+// Add appends the analysis' objects. Observables shared by several analyses
+// are included once.
+func (b *Builder) Add(t *models.ThreatIndicator) error {
+	objects, err := Objects(t)
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		if err := b.add(o.(interface{ ObjectID() string }).ObjectID(), o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) add(id string, o interface{}) error {
+	if b.seen[id] {
+		return nil
+	}
+	raw, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	b.seen[id] = true
+	b.objects = append(b.objects, raw)
+	return nil
+}
+
+// Bundle returns a bundle with a fresh ID holding everything added so far.
+func (b *Builder) Bundle() *Bundle {
+	return &Bundle{
+		Type:    "bundle",
+		ID:      "bundle--" + uuid.NewString(),
+		Objects: b.objects,
+	}
+}
@@ -0,0 +1,246 @@
+package stix
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AEX0TIC/ATIA/backend/internal/models"
+)
+
+func threat(indicatorType, indicator string) *models.ThreatIndicator {
+	return &models.ThreatIndicator{
+		TenantID:    "default",
+		Indicator:   indicator,
+		Type:        indicatorType,
+		RiskScore:   82.4,
+		Reputation:  "malicious",
+		FirstSeen:   time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		LastUpdated: time.Date(2025, 3, 2, 12, 30, 0, 0, time.UTC),
+		Sources: []models.SourceData{
+			{Name: "virustotal", Verdict: "malicious", Score: 90},
+			{Name: "abuseipdb", Verdict: "suspicious", Score: 60},
+		},
+		Tags: []string{"botnet"},
+	}
+}
+
+// Vectors are uuid5(observable namespace, JCS of the contributing
+// properties), as the spec defines deterministic SCO IDs.
+func TestObservableIDs(t *testing.T) {
+	tests := []struct {
+		indicatorType, indicator, want string
+	}{
+		{"ip", "198.51.100.3", "ipv4-addr--28bb3599-77cd-5a82-a950-b5bc3caf07c4"},
+		{"ip", "2001:db8::1", "ipv6-addr--6469e3a9-b053-5e34-a025-9396ae051d26"},
+		{"domain", "Example.com", "domain-name--bedb4899-d24b-5401-bc86-8f6b4cc18ec7"},
+		{"url", "http://a.com/?x=1&y=2", "url--66bcc60b-0bbe-51fb-9560-849a80921c12"},
+		{"url", "http://a.com/<script>", "url--ab70fa3e-6867-5066-8b52-569f50950c9d"},
+		{"hash", "D41D8CD98F00B204E9800998ECF8427E", "file--02fff920-f614-527c-81d1-6353633a6d21"},
+		{"hash", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "file--22f8ff52-8f62-5f03-a53a-6f50f54fd74c"},
+	}
+	for _, tt := range tests {
+		o, err := observable(threat(tt.indicatorType, tt.indicator))
+		if err != nil {
+			t.Errorf("observable(%q): %v", tt.indicator, err)
+			continue
+		}
+		if o.ID != tt.want {
+			t.Errorf("observable(%q).ID = %s, want %s", tt.indicator, o.ID, tt.want)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		indicatorType, indicator, want string
+	}{
+		{"ip", "198.51.100.3", "[ipv4-addr:value = '198.51.100.3']"},
+		{"ip", "2001:db8::1", "[ipv6-addr:value = '2001:db8::1']"},
+		{"domain", "Evil.Example.com", "[domain-name:value = 'evil.example.com']"},
+		{"url", `http://a.com/it's\here`, `[url:value = 'http://a.com/it\'s\\here']`},
+		{"hash", "d41d8cd98f00b204e9800998ecf8427e", "[file:hashes.'MD5' = 'd41d8cd98f00b204e9800998ecf8427e']"},
+		{"hash", "da39a3ee5e6b4b0d3255bfef95601890afd80709", "[file:hashes.'SHA-1' = 'da39a3ee5e6b4b0d3255bfef95601890afd80709']"},
+		{"hash", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "[file:hashes.'SHA-256' = 'e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855']"},
+	}
+	for _, tt := range tests {
+		got, err := Pattern(threat(tt.indicatorType, tt.indicator))
+		if err != nil {
+			t.Errorf("Pattern(%q): %v", tt.indicator, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Pattern(%q) = %s, want %s", tt.indicator, got, tt.want)
+		}
+	}
+}
+
+func TestPatternUnsupported(t *testing.T) {
+	for _, th := range []*models.ThreatIndicator{
+		threat("hash", "abc123"),
+		threat("hash", strings.Repeat("z", 32)),
+		threat("ip", "not-an-ip"),
+		threat("email", "a@example.com"),
+	} {
+		if _, err := Pattern(th); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Pattern(%s %q) error = %v, want ErrUnsupported", th.Type, th.Indicator, err)
+		}
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  int
+	}{
+		{-5, 0}, {0, 0}, {29.4, 29}, {29.5, 30}, {69.9, 70}, {70, 70}, {100, 100}, {130, 100},
+	}
+	for _, tt := range tests {
+		if got := Confidence(tt.score); got != tt.want {
+			t.Errorf("Confidence(%v) = %d, want %d", tt.score, got, tt.want)
+		}
+	}
+}
+
+var (
+	idPattern        = regexp.MustCompile(`^[a-z0-9-]+--[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+)
+
+func TestBundle(t *testing.T) {
+	b := NewBuilder()
+	for _, th := range []*models.ThreatIndicator{
+		threat("ip", "198.51.100.3"),
+		threat("domain", "example.com"),
+		threat("ip", "198.51.100.3"), // added twice, kept once
+	} {
+		if err := b.Add(th); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := json.Marshal(b.Bundle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle struct {
+		Type        string                   `json:"type"`
+		ID          string                   `json:"id"`
+		SpecVersion *string                  `json:"spec_version"`
+		Objects     []map[string]interface{} `json:"objects"`
+	}
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Type != "bundle" || !strings.HasPrefix(bundle.ID, "bundle--") || !idPattern.MatchString(bundle.ID) {
+		t.Errorf("bundle type %q id %q", bundle.Type, bundle.ID)
+	}
+	if bundle.SpecVersion != nil {
+		t.Error("STIX 2.1 bundles have no spec_version")
+	}
+
+	// identity + 2 × (observable, observed-data, indicator, relationship, 2 notes)
+	if len(bundle.Objects) != 13 {
+		t.Fatalf("got %d objects, want 13", len(bundle.Objects))
+	}
+	ids := map[string]bool{}
+	counts := map[string]int{}
+	for _, o := range bundle.Objects {
+		objectType, _ := o["type"].(string)
+		id, _ := o["id"].(string)
+		counts[objectType]++
+		if !idPattern.MatchString(id) || !strings.HasPrefix(id, objectType+"--") {
+			t.Errorf("%s has id %q", objectType, id)
+		}
+		if ids[id] {
+			t.Errorf("duplicate id %s", id)
+		}
+		ids[id] = true
+		if o["spec_version"] != SpecVersion {
+			t.Errorf("%s spec_version = %v", id, o["spec_version"])
+		}
+		for _, key := range []string{"created", "modified", "valid_from", "first_observed", "last_observed"} {
+			if v, ok := o[key]; ok && !timestampPattern.MatchString(v.(string)) {
+				t.Errorf("%s %s = %v, not a STIX timestamp", id, key, v)
+			}
+		}
+		if objectType == "indicator" {
+			if o["pattern_type"] != "stix" || o["confidence"] != 82.0 || o["created_by_ref"] != IdentityID {
+				t.Errorf("indicator %s: %v", id, o)
+			}
+			if types, _ := o["indicator_types"].([]interface{}); len(types) != 1 || types[0] != "malicious-activity" {
+				t.Errorf("indicator %s indicator_types = %v", id, o["indicator_types"])
+			}
+		}
+	}
+	want := map[string]int{"identity": 1, "ipv4-addr": 1, "domain-name": 1, "observed-data": 2, "indicator": 2, "relationship": 2, "note": 4}
+	for objectType, n := range want {
+		if counts[objectType] != n {
+			t.Errorf("got %d %s objects, want %d", counts[objectType], objectType, n)
+		}
+	}
+
+	// Every reference resolves within the bundle.
+	for _, o := range bundle.Objects {
+		refs := []interface{}{o["created_by_ref"], o["source_ref"], o["target_ref"]}
+		if list, ok := o["object_refs"].([]interface{}); ok {
+			refs = append(refs, list...)
+		}
+		for _, ref := range refs {
+			if ref, ok := ref.(string); ok && !ids[ref] {
+				t.Errorf("%s references %s, which is not in the bundle", o["id"], ref)
+			}
+		}
+	}
+}
+
+func TestObjectsStable(t *testing.T) {
+	first, err := Objects(threat("url", "http://a.com/?x=1&y=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Objects(threat("url", "http://a.com/?x=1&y=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range first {
+		a := first[i].(interface{ ObjectID() string }).ObjectID()
+		b := second[i].(interface{ ObjectID() string }).ObjectID()
+		if a != b {
+			t.Errorf("object %d id changed between exports: %s, %s", i, a, b)
+		}
+	}
+}
+
+func TestObjectIDs(t *testing.T) {
+	th := threat("domain", "example.com")
+	objects, err := Objects(th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := ObjectIDs(th)
+	for _, o := range objects {
+		if id := o.(interface{ ObjectID() string }).ObjectID(); !slices.Contains(ids, id) {
+			t.Errorf("ObjectIDs is missing %s", id)
+		}
+	}
+
+	// The analyst note's ID is there before notes are added.
+	th.Notes = "seen in phishing"
+	withNotes, err := Objects(th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := withNotes[len(withNotes)-1].(*Note)
+	if !slices.Contains(ids, note.ID) {
+		t.Errorf("ObjectIDs is missing the analyst note %s", note.ID)
+	}
+
+	if ids := ObjectIDs(threat("hash", "abc")); len(ids) != 0 {
+		t.Errorf("ObjectIDs of an unsupported indicator = %v", ids)
+	}
+}
@@ -0,0 +1,107 @@
+// Package stix renders stored analyses as STIX 2.1 bundles.
+package stix
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	SpecVersion = "2.1"
+	// MediaType is the content type for STIX 2.1 JSON.
+	MediaType = "application/stix+json;version=2.1"
+)
+
+// Timestamp marshals as a STIX timestamp: UTC with millisecond precision.
+type Timestamp time.Time
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(t).UTC().Format("2006-01-02T15:04:05.000Z"))
+}
+
+func (t Timestamp) Time() time.Time { return time.Time(t) }
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	var parsed time.Time
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		return err
+	}
+	*t = Timestamp(parsed)
+	return nil
+}
+
+// Bundle is a collection of STIX objects. Objects are kept as raw JSON so
+// bundles can be built from any of the object types below or read back
+// without knowing every type.
+type Bundle struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id"`
+	Objects []json.RawMessage `json:"objects"`
+}
+
+// common holds the properties shared by STIX domain and relationship
+// objects.
+type common struct {
+	Type         string    `json:"type"`
+	SpecVersion  string    `json:"spec_version"`
+	ID           string    `json:"id"`
+	Created      Timestamp `json:"created"`
+	Modified     Timestamp `json:"modified"`
+	CreatedByRef string    `json:"created_by_ref,omitempty"`
+	Labels       []string  `json:"labels,omitempty"`
+	Confidence   *int      `json:"confidence,omitempty"`
+}
+
+func (c common) ObjectID() string { return c.ID }
+
+type Identity struct {
+	common
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	IdentityClass string `json:"identity_class"`
+}
+
+type Indicator struct {
+	common
+	Name           string    `json:"name"`
+	Description    string    `json:"description,omitempty"`
+	IndicatorTypes []string  `json:"indicator_types"`
+	Pattern        string    `json:"pattern"`
+	PatternType    string    `json:"pattern_type"`
+	ValidFrom      Timestamp `json:"valid_from"`
+}
+
+type ObservedData struct {
+	common
+	FirstObserved  Timestamp `json:"first_observed"`
+	LastObserved   Timestamp `json:"last_observed"`
+	NumberObserved int       `json:"number_observed"`
+	ObjectRefs     []string  `json:"object_refs"`
+}
+
+type Note struct {
+	common
+	Abstract   string   `json:"abstract"`
+	Content    string   `json:"content"`
+	Authors    []string `json:"authors,omitempty"`
+	ObjectRefs []string `json:"object_refs"`
+}
+
+type Relationship struct {
+	common
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+// Observable is a cyber-observable object: an address, domain, URL or file.
+// Only one of Value and Hashes is set.
+type Observable struct {
+	Type        string            `json:"type"`
+	SpecVersion string            `json:"spec_version"`
+	ID          string            `json:"id"`
+	Value       string            `json:"value,omitempty"`
+	Hashes      map[string]string `json:"hashes,omitempty"`
+}
+
+func (o *Observable) ObjectID() string { return o.ID }
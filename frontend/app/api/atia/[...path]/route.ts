@@ -0,0 +1,70 @@
+import { NextRequest } from 'next/server';
+import { backendUrl, callerHeaders, sessionKey, unauthorized } from '../backend';
+
+export const dynamic = 'force-dynamic';
+
+// The backend calls the dashboard makes; anything else is a 404 here, so the
+// proxy can't be used to reach the admin, write or export API.
+const routes: { method: string; path: RegExp }[] = [
+  { method: 'GET', path: /^health$/ },
+  { method: 'GET', path: /^api\/v1\/threats$/ },
+  { method: 'GET', path: /^api\/v1\/threats\/[^/]+$/ },
+  { method: 'GET', path: /^api\/v1\/threats\/[^/]+\/history$/ },
+  { method: 'POST', path: /^api\/v1\/analyze$/ },
+  { method: 'GET', path: /^api\/v1\/analyze\/stream$/ },
+];
+
+// Headers passed through in each direction. Bodies are streamed, so
+// Server-Sent Events arrive as they are sent.
+const requestHeaders = ['accept', 'content-type'];
+const responseHeaders = [
+  'content-type',
+  'cache-control',
+  'retry-after',
+  'x-ratelimit-limit',
+  'x-ratelimit-remaining',
+  'x-ratelimit-reset',
+  'x-request-id',
+];
+
+async function proxy(req: NextRequest): Promise<Response> {
+  // The raw pathname keeps indicators such as URLs percent-encoded.
+  const path = req.nextUrl.pathname.replace(/^\/api\/atia\//, '');
+  if (!routes.some((r) => r.method === req.method && r.path.test(path))) {
+    return Response.json({ error: 'Not found' }, { status: 404 });
+  }
+
+  let headers = new Headers();
+  if (path !== 'health') {
+    const key = sessionKey(req);
+    if (!key) return unauthorized();
+    headers = callerHeaders(req, key);
+  }
+  requestHeaders.forEach((name) => {
+    const value = req.headers.get(name);
+    if (value) headers.set(name, value);
+  });
+
+  let upstream: Response;
+  try {
+    upstream = await fetch(`${backendUrl}/${path}${req.nextUrl.search}`, {
+      method: req.method,
+      headers,
+      body: req.method === 'POST' ? await req.arrayBuffer() : undefined,
+      cache: 'no-store',
+      signal: req.signal,
+    });
+  } catch {
+    return Response.json({ error: `Cannot connect to backend at ${backendUrl}` }, { status: 502 });
+  }
+
+  const out = new Headers();
+  responseHeaders.forEach((name) => {
+    const value = upstream.headers.get(name);
+    if (value) out.set(name, value);
+  });
+  return new Response(upstream.body, { status: upstream.status, headers: out });
+}
+
+export const GET = proxy;
+export const POST = proxy;
@@ -0,0 +1,27 @@
+import { NextRequest } from 'next/server';
+
+// The dashboard reaches the backend only through these routes. Each user
+// signs in with their own API key, which is kept in an httpOnly cookie and
+// added on the server, so it never reaches page scripts and every user keeps
+// their own scopes and rate-limit budget.
+export const backendUrl = process.env.ATIA_API_URL || 'http://localhost:8080';
+
+export const sessionCookie = 'atia_api_key';
+
+export function sessionKey(req: NextRequest): string | undefined {
+  return req.cookies.get(sessionCookie)?.value || undefined;
+}
+
+// Headers identifying the caller to the backend: their key and their
+// address, which the backend uses for per-IP limits once the dashboard is
+// listed in its TRUSTED_PROXIES.
+export function callerHeaders(req: NextRequest, key: string): Headers {
+  const headers = new Headers({ 'X-API-Key': key });
+  const forwarded = req.headers.get('x-forwarded-for') || req.ip;
+  if (forwarded) headers.set('X-Forwarded-For', forwarded);
+  return headers;
+}
+
+export function unauthorized(): Response {
+  return Response.json({ error: 'Sign in with an API key' }, { status: 401 });
+}
@@ -0,0 +1,47 @@
+import { NextRequest } from 'next/server';
+import { backendUrl, sessionKey, unauthorized } from '../backend';
+
+export const dynamic = 'force-dynamic';
+
+// Relays the backend's live threat WebSocket to the browser as Server-Sent
+// Events, each message's data being the JSON the backend sent. The backend
+// takes the signed-in user's key from the query string on WebSocket requests.
+export async function GET(req: NextRequest): Promise<Response> {
+  const key = sessionKey(req);
+  if (!key) return unauthorized();
+  const url = new URL('/api/v1/live/threats', backendUrl.replace(/^http/, 'ws'));
+  url.searchParams.set('api_key', key);
+
+  const encoder = new TextEncoder();
+  let socket: WebSocket;
+  let open = true;
+  const stream = new ReadableStream<Uint8Array>({
+    start(controller) {
+      socket = new WebSocket(url);
+      const close = () => {
+        if (!open) return;
+        open = false;
+        socket.close();
+        controller.close();
+      };
+      socket.onopen = () => controller.enqueue(encoder.encode(': connected\n\n'));
+      socket.onmessage = (e) => {
+        if (open) controller.enqueue(encoder.encode(`data: ${e.data}\n\n`));
+      };
+      socket.onerror = close;
+      socket.onclose = close;
+      req.signal.addEventListener('abort', close);
+    },
+    cancel() {
+      open = false;
+      socket.close();
+    },
+  });
+
+  return new Response(stream, {
+    headers: {
+      'Content-Type': 'text/event-stream',
+      'Cache-Control': 'no-cache, no-transform',
+    },
+  });
+}
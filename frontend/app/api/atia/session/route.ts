@@ -0,0 +1,50 @@
+import { NextRequest, NextResponse } from 'next/server';
+import { backendUrl, callerHeaders, sessionCookie, sessionKey } from '../backend';
+
+export const dynamic = 'force-dynamic';
+
+export async function GET(req: NextRequest): Promise<Response> {
+  return Response.json({ signed_in: Boolean(sessionKey(req)) });
+}
+
+// Signs in with an API key, checked against the backend first so a wrong
+// key is reported here rather than on the next request.
+export async function POST(req: NextRequest): Promise<Response> {
+  let key = '';
+  try {
+    key = String((await req.json()).api_key || '').trim();
+  } catch {
+    // handled below
+  }
+  if (!key) return Response.json({ error: 'api_key is required' }, { status: 400 });
+
+  let check: Response;
+  try {
+    check = await fetch(`${backendUrl}/api/v1/threats?limit=1`, {
+      headers: callerHeaders(req, key),
+      cache: 'no-store',
+    });
+  } catch {
+    return Response.json({ error: `Cannot connect to backend at ${backendUrl}` }, { status: 502 });
+  }
+  if (!check.ok) {
+    const status = check.status === 401 || check.status === 403 ? 401 : check.status;
+    return Response.json({ error: 'The backend rejected this key' }, { status });
+  }
+
+  const res = NextResponse.json({ signed_in: true });
+  res.cookies.set(sessionCookie, key, {
+    httpOnly: true,
+    sameSite: 'strict',
+    secure: req.nextUrl.protocol === 'https:',
+    path: '/api/atia',
+    maxAge: 12 * 60 * 60,
+  });
+  return res;
+}
+
+export async function DELETE(): Promise<Response> {
+  const res = NextResponse.json({ signed_in: false });
+  res.cookies.set(sessionCookie, '', { path: '/api/atia', maxAge: 0 });
+  return res;
+}
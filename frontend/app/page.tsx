@@ -30,16 +30,28 @@ interface HealthResponse {
   status: string;
 }
 
-// API Client
-const apiBaseUrl = typeof window !== 'undefined' 
-  ? process.env.NEXT_PUBLIC_API_BASE_URL || 'http://localhost:8080'
-  : 'http://backend:8080';
+// API Client. Requests go through the dashboard's own /api/atia routes,
+// which add the signed-in user's API key on the server.
+const apiBaseUrl = '/api/atia';
 
 const api = axios.create({
   baseURL: apiBaseUrl,
   timeout: 30000,
 });
 
+async function getSession(): Promise<boolean> {
+  const response = await api.get('/session');
+  return Boolean(response.data?.signed_in);
+}
+
+async function signIn(apiKey: string): Promise<void> {
+  await api.post('/session', { api_key: apiKey });
+}
+
+async function signOut(): Promise<void> {
+  await api.delete('/session');
+}
+
 async function getHealth(): Promise<HealthResponse> {
   const response = await api.get('/health');
   return response.data;
@@ -50,13 +62,58 @@ async function analyzeThreat(indicator: string, type: string): Promise<ThreatInd
   return response.data?.data || response.data;
 }
 
+interface SourceUpdate {
+  name: string;
+  source?: SourceData;
+  error?: string;
+}
+
+interface LiveMessage {
+  type: 'analysis_completed' | 'indicator_deleted';
+  threat: ThreatIndicator;
+}
+
+function streamUrl(path: string, params: Record<string, string> = {}): string {
+  return `${apiBaseUrl}${path}?${new URLSearchParams(params)}`;
+}
+
+// Runs an analysis over Server-Sent Events, reporting each source as it
+// completes, and resolves with the final result.
+function streamAnalysis(
+  indicator: string,
+  type: string,
+  onSource: (update: SourceUpdate) => void,
+): Promise<ThreatIndicator> {
+  return new Promise((resolve, reject) => {
+    const source = new EventSource(streamUrl('/api/v1/analyze/stream', { indicator, type }));
+    source.addEventListener('source', (e) => onSource(JSON.parse((e as MessageEvent).data)));
+    source.addEventListener('result', (e) => {
+      source.close();
+      resolve(JSON.parse((e as MessageEvent).data).data);
+    });
+    source.addEventListener('error', (e) => {
+      source.close();
+      const data = (e as MessageEvent).data;
+      reject(new Error(data ? `Backend Error: ${JSON.parse(data).error}` : 'Network Error: Cannot connect to the ATIA backend'));
+    });
+  });
+}
+
 async function getAllThreats(limit: number = 50): Promise<ThreatIndicator[]> {
   const response = await api.get('/api/v1/threats', { params: { limit } });
   return Array.isArray(response.data) ? response.data : response.data?.data || [];
 }
 
 // Components
-function Header({ currentTab, onTabChange }: { currentTab: string; onTabChange: (tab: string) => void }) {
+function Header({
+  currentTab,
+  onTabChange,
+  onSignOut,
+}: {
+  currentTab: string;
+  onTabChange: (tab: string) => void;
+  onSignOut?: () => void;
+}) {
   const [health, setHealth] = useState<HealthResponse | null>(null);
 
   useEffect(() => {
@@ -112,6 +169,14 @@ function Header({ currentTab, onTabChange }: { currentTab: string; onTabChange:
                 <Zap size={16} />
                 n8n
               </a>
+              {onSignOut && (
+                <button
+                  onClick={onSignOut}
+                  className="px-4 py-2 bg-gray-700 hover:bg-gray-600 rounded-lg text-sm"
+                >
+                  Sign out
+                </button>
+              )}
             </div>
           </div>
         </div>
@@ -166,17 +231,69 @@ function Header({ currentTab, onTabChange }: { currentTab: string; onTabChange:
   );
 }
 
+function SignInForm({ onSignedIn }: { onSignedIn: () => void }) {
+  const [apiKey, setApiKey] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setError('');
+    setLoading(true);
+    try {
+      await signIn(apiKey.trim());
+      setApiKey('');
+      onSignedIn();
+    } catch (err) {
+      const data = axios.isAxiosError(err) ? (err as AxiosError<{ error?: string }>).response?.data : undefined;
+      setError(data?.error || 'Sign in failed');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="max-w-md mx-auto px-4 py-12">
+      <div className="bg-white rounded-lg shadow-md p-6">
+        <h2 className="text-2xl font-bold text-gray-900 mb-2">Sign In</h2>
+        <p className="text-sm text-gray-600 mb-6">Use an ATIA API key with the read and analyze scopes.</p>
+        <form onSubmit={handleSubmit} className="space-y-4">
+          <input
+            type="password"
+            title="API key"
+            value={apiKey}
+            onChange={(e) => setApiKey(e.target.value)}
+            placeholder="API key"
+            autoComplete="off"
+            className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:border-blue-500"
+          />
+          <button
+            type="submit"
+            disabled={loading || !apiKey.trim()}
+            className="w-full bg-blue-600 hover:bg-blue-700 disabled:bg-gray-400 text-white font-semibold py-2 px-4 rounded-lg transition-colors"
+          >
+            {loading ? 'Signing in...' : 'Sign In'}
+          </button>
+        </form>
+        {error && <div className="mt-4 p-4 bg-red-50 border border-red-200 text-red-700 rounded-lg flex items-start gap-3"><AlertCircle size={20} className="flex-shrink-0 mt-0.5" /><div>{error}</div></div>}
+      </div>
+    </div>
+  );
+}
+
 function AnalyzerForm({ onResultsUpdate }: { onResultsUpdate: () => void }) {
   const [indicator, setIndicator] = useState('');
   const [type, setType] = useState('ip');
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
   const [success, setSuccess] = useState('');
+  const [progress, setProgress] = useState<SourceUpdate[]>([]);
 
   const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
     e.preventDefault();
     setError('');
     setSuccess('');
+    setProgress([]);
 
     if (!indicator.trim()) {
       setError('Please enter an indicator');
@@ -185,7 +302,11 @@ function AnalyzerForm({ onResultsUpdate }: { onResultsUpdate: () => void }) {
 
     setLoading(true);
     try {
-      await analyzeThreat(indicator.trim(), type);
+      if (typeof EventSource !== 'undefined') {
+        await streamAnalysis(indicator.trim(), type, (update) => setProgress((p) => [...p, update]));
+      } else {
+        await analyzeThreat(indicator.trim(), type);
+      }
       setSuccess(`Analysis completed for ${indicator}`);
       setIndicator('');
       onResultsUpdate();
@@ -195,7 +316,7 @@ function AnalyzerForm({ onResultsUpdate }: { onResultsUpdate: () => void }) {
       if (axios.isAxiosError(err)) {
         const axiosErr = err as AxiosError<{error?: string}>;
         if (!axiosErr.response) {
-          msg = `Network Error: Cannot connect to backend at ${apiBaseUrl}. Make sure the backend service is running.`;
+          msg = 'Network Error: Cannot connect to the ATIA backend. Make sure the backend service is running.';
         } else if (axiosErr.response.data?.error) {
           msg = `Backend Error: ${axiosErr.response.data.error}`;
         } else if (axiosErr.response.statusText) {
@@ -256,6 +377,21 @@ function AnalyzerForm({ onResultsUpdate }: { onResultsUpdate: () => void }) {
         </button>
       </form>
 
+      {progress.length > 0 && (
+        <ul className="mt-4 space-y-2">
+          {progress.map((update) => (
+            <li key={update.name} className="flex justify-between text-sm px-4 py-2 bg-gray-50 rounded-lg">
+              <span className="font-medium text-gray-700">{update.name}</span>
+              {update.source ? (
+                <span className="text-gray-600">{update.source.verdict} ({update.source.score.toFixed(0)})</span>
+              ) : (
+                <span className="text-gray-400">{update.error}</span>
+              )}
+            </li>
+          ))}
+        </ul>
+      )}
+
       {error && <div className="mt-4 p-4 bg-red-50 border border-red-200 text-red-700 rounded-lg flex items-start gap-3"><AlertCircle size={20} className="flex-shrink-0 mt-0.5" /><div>{error}</div></div>}
       {success && <div className="mt-4 p-4 bg-green-50 border border-green-200 text-green-700 rounded-lg flex items-start gap-3"><CheckCircle size={20} className="flex-shrink-0 mt-0.5" /><div>{success}</div></div>}
     </div>
@@ -720,6 +856,23 @@ export default function Home() {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
   const [currentTab, setCurrentTab] = useState('dashboard');
+  // null until the session has been checked.
+  const [signedIn, setSignedIn] = useState<boolean | null>(null);
+
+  useEffect(() => {
+    getSession()
+      .then(setSignedIn)
+      .catch(() => setSignedIn(false));
+  }, []);
+
+  const handleSignOut = async () => {
+    try {
+      await signOut();
+    } finally {
+      setThreats([]);
+      setSignedIn(false);
+    }
+  };
 
   const loadThreats = useCallback(async () => {
     try {
@@ -728,21 +881,65 @@ export default function Home() {
       setThreats(data);
     } catch (err) {
       console.error('Error loading threats:', err);
-      setError('Failed to connect to backend');
+      if (axios.isAxiosError(err) && err.response?.status === 401) {
+        setSignedIn(false);
+      } else {
+        setError('Failed to connect to backend');
+      }
     } finally {
       setLoading(false);
     }
   }, []);
 
+  // Live updates, relayed from the backend's WebSocket as Server-Sent
+  // Events; poll every 60s only while they're down.
   useEffect(() => {
+    if (!signedIn) return;
     loadThreats();
-    const interval = setInterval(loadThreats, 60000);
-    return () => clearInterval(interval);
-  }, [loadThreats]);
+
+    let source: EventSource | null = null;
+    let retry: ReturnType<typeof setTimeout>;
+    let closed = false;
+    const connect = () => {
+      source = new EventSource(streamUrl('/live'));
+      source.onmessage = (e) => {
+        const msg: LiveMessage = JSON.parse(e.data);
+        setThreats((current) => {
+          const rest = current.filter((t) => t.indicator !== msg.threat.indicator);
+          if (msg.type === 'indicator_deleted') return rest;
+          return [msg.threat, ...rest];
+        });
+      };
+      // EventSource retries on its own unless the relay refused outright.
+      source.onerror = () => {
+        if (source?.readyState === EventSource.CLOSED && !closed) retry = setTimeout(connect, 5000);
+      };
+    };
+    connect();
+
+    const interval = setInterval(() => {
+      if (source?.readyState !== EventSource.OPEN) loadThreats();
+    }, 60000);
+    return () => {
+      closed = true;
+      clearTimeout(retry);
+      clearInterval(interval);
+      source?.close();
+    };
+  }, [loadThreats, signedIn]);
+
+  if (signedIn === false) {
+    return (
+      <>
+        <Header currentTab={currentTab} onTabChange={setCurrentTab} />
+        <SignInForm onSignedIn={() => setSignedIn(true)} />
+      </>
+    );
+  }
 
   return (
     <>
-      <Header currentTab={currentTab} onTabChange={setCurrentTab} />
+      <Header currentTab={currentTab} onTabChange={setCurrentTab} onSignOut={signedIn ? handleSignOut : undefined} />
 
       <main>
         {currentTab === 'dashboard' && (